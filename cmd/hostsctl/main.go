@@ -18,6 +18,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 
@@ -25,12 +27,16 @@ import (
 )
 
 // main is the entry point for the hostsctl application.
-// It creates a CLI instance and executes the user's command.
+// It creates a CLI instance and executes the user's command. Commands
+// interrupted by SIGINT or SIGTERM exit with status 130, as shells do.
 func main() {
 	app := cli.NewCLI()
 
 	if err := app.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if errors.Is(err, context.Canceled) {
+			os.Exit(130)
+		}
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,542 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vaxvhbe/hostsctl/internal/hosts"
+	"github.com/vaxvhbe/hostsctl/pkg"
+)
+
+// buildBackupListCommand creates the backup list command.
+func (c *CLI) buildBackupListCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List automatic backups of the hosts file",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.runBackupList()
+		},
+	}
+
+	return cmd
+}
+
+// buildBackupShowCommand creates the backup show command.
+func (c *CLI) buildBackupShowCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "show <id>",
+		Short: "Show the entries of a backup",
+		Long: `Show the entries of a backup without restoring it.
+
+The backup is identified by the ID shown by 'hostsctl backup list' or by any
+unambiguous prefix of its SHA-256 checksum. Its checksum is verified first.
+
+Examples:
+  hostsctl backup list
+  hostsctl backup show 3f2a9c81d04e`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.runBackupShow(args[0])
+		},
+	}
+
+	return cmd
+}
+
+// buildBackupDiffCommand creates the backup diff command.
+func (c *CLI) buildBackupDiffCommand() *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "diff <a> [<b>]",
+		Short: "Compare two backups, or a backup with the hosts file",
+		Long: `Compare the entries of backup <a> with those of backup <b>, or with the
+current hosts file if <b> is omitted.
+
+Backups are identified like for 'hostsctl backup show' and their checksum is
+verified first. The text format lists the entries added, removed and modified
+from <a> to <b>; the unified format shows the line changes like diff -u.
+
+Examples:
+  hostsctl backup diff 3f2a9c81d04e                   # Changes since the backup
+  hostsctl backup diff 3f2a9c81d04e 9b1e07d2c5aa      # Changes between two backups
+  hostsctl backup diff 3f2a9c81d04e --format unified`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			to := ""
+			if len(args) == 2 {
+				to = args[1]
+			}
+			return c.runBackupDiff(cmd.Context(), args[0], to, format)
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "text", "Output format (text|unified|json)")
+
+	return cmd
+}
+
+// buildBackupPruneCommand creates the backup prune command.
+func (c *CLI) buildBackupPruneCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove automatic backups outside the retention policy",
+		Long: `Remove automatic backups that fall outside the retention policy.
+
+The same policy is applied automatically after every change to the hosts file.
+The most recent backup is always kept.
+
+Examples:
+  hostsctl backup prune --dry-run                     # Show what would be removed
+  hostsctl backup prune --backup-keep 5               # Keep the 5 most recent backups
+  hostsctl backup prune --backup-max-age 720h         # Remove backups older than 30 days
+  hostsctl backup prune --backup-max-size 10M         # Keep at most 10 MiB of backups`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.runBackupPrune(cmd.Context(), c.dryRun)
+		},
+	}
+
+	return cmd
+}
+
+func (c *CLI) runBackupPrune(ctx context.Context, dryRun bool) error {
+	return c.withLock(ctx, c.hostsFile, func() error {
+		store := c.newStore(false)
+
+		removed, err := store.PruneBackups(dryRun)
+		if err != nil {
+			return fmt.Errorf("failed to prune backups: %w", err)
+		}
+
+		if c.jsonOutput {
+			return json.NewEncoder(os.Stdout).Encode(removed)
+		}
+
+		verb := "Removed"
+		if dryRun {
+			verb = "Would remove"
+		}
+		for _, backup := range removed {
+			fmt.Printf("%s %s (%d bytes, %s)\n", verb, backup.Path, backup.Size, backup.CreatedAt.Format(time.RFC3339))
+		}
+		fmt.Printf("%s %d backup(s)\n", verb, len(removed))
+		return nil
+	})
+}
+
+func (c *CLI) runBackupList() error {
+	store := c.newStore(false)
+
+	backups, err := store.ListBackups()
+	if err != nil {
+		return err
+	}
+	store.CountBackupEntries(backups)
+
+	if c.jsonOutput {
+		return json.NewEncoder(os.Stdout).Encode(backups)
+	}
+
+	if len(backups) == 0 {
+		fmt.Printf("No backups found in %s\n", store.BackupDir())
+		return nil
+	}
+
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tCREATED\tAGE\tSIZE\tENTRIES\tCOMMAND")
+	_, _ = fmt.Fprintln(w, "--\t-------\t---\t----\t-------\t-------")
+
+	for _, backup := range backups {
+		command := backup.Command
+		if len(command) > 40 {
+			command = command[:37] + "..."
+		}
+
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\n",
+			backup.ID,
+			backup.CreatedAt.Format("2006-01-02 15:04:05"),
+			formatAge(now.Sub(backup.CreatedAt)),
+			backup.Size,
+			backup.EntryCount,
+			command)
+	}
+
+	_ = w.Flush()
+	return nil
+}
+
+func (c *CLI) runBackupShow(id string) error {
+	store := c.newStore(false)
+
+	backup, err := store.FindBackup(id)
+	if err != nil {
+		return err
+	}
+
+	hostsFile, err := store.LoadBackup(backup.Path)
+	if err != nil {
+		return err
+	}
+	backup.EntryCount = len(hostsFile.Entries)
+
+	if c.jsonOutput {
+		return json.NewEncoder(os.Stdout).Encode(map[string]interface{}{
+			"backup":  backup,
+			"entries": hostsFile.Entries,
+		})
+	}
+
+	fmt.Printf("Backup: %s\n", backup.ID)
+	fmt.Printf("Path: %s\n", backup.Path)
+	fmt.Printf("Created: %s\n", backup.CreatedAt.Format(time.RFC3339))
+	fmt.Printf("Size: %d bytes\n", backup.Size)
+	if backup.SHA256 != "" {
+		fmt.Printf("SHA-256: %s\n", backup.SHA256)
+	}
+	if backup.Command != "" {
+		fmt.Printf("Command: %s\n", backup.Command)
+	}
+	fmt.Printf("Entries: %d\n\n", len(hostsFile.Entries))
+
+	c.printEntriesFiltered(hostsFile.Entries, ListFilters{ShowAll: true})
+	return nil
+}
+
+// backupDiffResult is the JSON output of backup diff.
+type backupDiffResult struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	*DiffResult
+}
+
+// runBackupDiff compares backup from with backup to, or with the hosts file
+// if to is empty.
+func (c *CLI) runBackupDiff(ctx context.Context, from, to, format string) error {
+	if c.jsonOutput {
+		format = "json"
+	}
+	if format != "text" && format != "unified" && format != "json" {
+		return fmt.Errorf("unsupported diff format: %s (supported: text, unified, json)", format)
+	}
+
+	store := c.newStore(false)
+
+	fromLabel, fromData, err := c.readDiffSource(ctx, store, from)
+	if err != nil {
+		return err
+	}
+	toLabel, toData, err := c.readDiffSource(ctx, store, to)
+	if err != nil {
+		return err
+	}
+
+	if format == "unified" {
+		fmt.Print(hosts.UnifiedDiff(fromLabel, toLabel, string(fromData), string(toData)))
+		return nil
+	}
+
+	parser := hosts.NewParser(false)
+	fromFile, err := parser.Parse(bytes.NewReader(fromData))
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", fromLabel, err)
+	}
+	toFile, err := parser.Parse(bytes.NewReader(toData))
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", toLabel, err)
+	}
+
+	diff := c.calculateDiff(fromFile.Entries, toFile.Entries)
+
+	if format == "json" {
+		return json.NewEncoder(os.Stdout).Encode(backupDiffResult{From: fromLabel, To: toLabel, DiffResult: diff})
+	}
+
+	c.printDiff(diff, fromLabel, toLabel)
+	return nil
+}
+
+// readDiffSource returns a label and the content of the backup with the
+// given ID, or of the hosts file if id is empty.
+func (c *CLI) readDiffSource(ctx context.Context, store *hosts.Store, id string) (string, []byte, error) {
+	if id == "" {
+		var data []byte
+		err := c.withReadLock(ctx, c.hostsFile, func() error {
+			var err error
+			data, err = os.ReadFile(c.hostsFile) // #nosec G304 -- configured hosts file
+			return err
+		})
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read hosts file: %w", err)
+		}
+		return c.hostsFile, data, nil
+	}
+
+	backup, err := store.FindBackup(id)
+	if err != nil {
+		return "", nil, err
+	}
+
+	data, err := store.ReadBackup(backup.Path)
+	if err != nil {
+		return "", nil, err
+	}
+	return "backup " + backup.ID, data, nil
+}
+
+// formatAge renders a duration as a short, human-readable age such as "5m",
+// "3h" or "12d".
+func formatAge(age time.Duration) string {
+	switch {
+	case age < time.Minute:
+		return "now"
+	case age < time.Hour:
+		return fmt.Sprintf("%dm", int(age.Minutes()))
+	case age < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(age.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(age.Hours()/24))
+	}
+}
+
+// loadRetentionPolicy builds the backup retention policy from the command line flags.
+func (c *CLI) loadRetentionPolicy() error {
+	maxSize, err := parseByteSize(c.backupMaxSize)
+	if err != nil {
+		return fmt.Errorf("invalid --backup-max-size: %w", err)
+	}
+	if c.backupKeep < 0 || c.backupMaxAge < 0 {
+		return fmt.Errorf("backup retention limits cannot be negative")
+	}
+
+	c.retention = hosts.RetentionPolicy{
+		KeepLast:     c.backupKeep,
+		MaxAge:       c.backupMaxAge,
+		MaxTotalSize: maxSize,
+	}
+	return nil
+}
+
+// parseByteSize parses a size such as "512", "64K", "10M" or "1G" (binary
+// multiples) into bytes. An empty string means no limit and returns 0.
+func parseByteSize(size string) (int64, error) {
+	size = strings.ToUpper(strings.TrimSpace(size))
+	if size == "" {
+		return 0, nil
+	}
+
+	multiplier := int64(1)
+	size = strings.TrimSuffix(size, "B")
+	switch {
+	case strings.HasSuffix(size, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(size, "M"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(size, "G"):
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		size = size[:len(size)-1]
+	}
+
+	value, err := strconv.ParseInt(size, 10, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("expected a size such as 512K or 10M")
+	}
+	return value * multiplier, nil
+}
+
+// runSelectiveRestore restores only some entries of a backup: those with one
+// of the given hostnames, or those chosen interactively. Entries of the
+// backup that were deleted or modified since are candidates; everything else
+// in the hosts file is kept.
+func (c *CLI) runSelectiveRestore(ctx context.Context, file, id string, names []string, interactive bool) error {
+	wanted := make([]string, 0, len(names))
+	for _, name := range names {
+		ascii, err := pkg.ToASCII(name)
+		if err != nil {
+			return err
+		}
+		wanted = append(wanted, ascii)
+	}
+
+	store := c.newStore(false)
+
+	file, err := resolveBackup(store, file, id)
+	if err != nil {
+		return err
+	}
+
+	backup, err := store.LoadBackup(file)
+	if err != nil {
+		return err
+	}
+
+	// The entries are chosen without holding the exclusive lock, which would
+	// block every other hostsctl command while the user answers. Update
+	// applies the choice to the hosts file as it is once the lock is taken.
+	current, err := c.loadShared(ctx, store)
+	if err != nil {
+		return fmt.Errorf("failed to load hosts file: %w", err)
+	}
+
+	candidates := restoreCandidates(c.calculateDiff(current.Entries, backup.Entries))
+
+	var selected []hosts.Entry
+	if len(wanted) > 0 {
+		if selected, err = selectByName(candidates, wanted); err != nil {
+			return err
+		}
+	}
+	if interactive {
+		if len(wanted) == 0 {
+			selected = candidates
+		}
+		if selected, err = c.promptEntries(selected); err != nil {
+			return err
+		}
+	}
+
+	if len(selected) == 0 {
+		c.reportChange("No entries to restore from %s", file)
+		return c.reportSaved(store)
+	}
+
+	return c.withLock(ctx, c.hostsFile, func() error {
+		err := c.updateHostsFile(ctx, store, func(hostsFile *hosts.HostsFile) error {
+			for _, entry := range selected {
+				if err := restoreEntry(hostsFile, entry); err != nil {
+					return err
+				}
+				c.reportChange("Restored entry: %s -> %s", entry.IP, c.displayNames(entry.Names))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return c.reportSaved(store)
+	})
+}
+
+// restoreCandidates returns the backup's version of the entries that were
+// deleted or modified since the backup was taken.
+func restoreCandidates(diff *DiffResult) []hosts.Entry {
+	candidates := make([]hosts.Entry, 0, len(diff.Added)+len(diff.Modified))
+	candidates = append(candidates, diff.Added...)
+	for _, mod := range diff.Modified {
+		candidates = append(candidates, mod.New)
+	}
+	return candidates
+}
+
+// selectByName returns the candidates that carry one of the hostnames,
+// failing for hostnames that match no candidate.
+func selectByName(candidates []hosts.Entry, names []string) ([]hosts.Entry, error) {
+	var selected []hosts.Entry
+	for _, name := range names {
+		found := false
+		for _, entry := range candidates {
+			if slices.ContainsFunc(entry.Names, func(n string) bool { return strings.EqualFold(n, name) }) {
+				found = true
+				if !slices.ContainsFunc(selected, func(e hosts.Entry) bool { return e.ID == entry.ID }) {
+					selected = append(selected, entry)
+				}
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no entry for %s in the backup differs from the hosts file", name)
+		}
+	}
+	return selected, nil
+}
+
+// promptEntries asks for each entry whether it should be restored and
+// returns the accepted ones. Anything but "y" or "yes" declines.
+func (c *CLI) promptEntries(entries []hosts.Entry) ([]hosts.Entry, error) {
+	var selected []hosts.Entry
+	scanner := bufio.NewScanner(c.in)
+
+	for _, entry := range entries {
+		fmt.Printf("Restore %s %s", entry.IP, c.displayNames(entry.Names))
+		if entry.FullComment() != "" {
+			fmt.Printf(" # %s", entry.FullComment())
+		}
+		fmt.Print("? [y/N] ")
+
+		if !scanner.Scan() {
+			fmt.Println()
+			break
+		}
+
+		switch strings.ToLower(strings.TrimSpace(scanner.Text())) {
+		case "y", "yes":
+			selected = append(selected, entry)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read answer: %w", err)
+	}
+	return selected, nil
+}
+
+// restoreEntry puts the backup's version of entry back into the hosts file.
+// An existing entry with the same IP address and hostnames takes over its
+// status, comment and annotations. A managed entry with the same hostnames
+// but another IP address is replaced by it, and any other entry sharing one
+// of its hostnames makes the restore fail rather than map the hostname twice.
+// Otherwise the entry is added to the default managed block.
+func restoreEntry(hostsFile *hosts.HostsFile, entry hosts.Entry) error {
+	for _, existing := range hostsFile.FindByName(entry.Names[0]) {
+		if !pkg.SameIP(existing.IP, entry.IP) || !slices.Equal(existing.Names, entry.Names) {
+			continue
+		}
+
+		if err := requireManaged(existing); err != nil {
+			return err
+		}
+		existing.Comment = entry.Comment
+		existing.Disabled = entry.Disabled
+		existing.Tags = entry.Tags
+		existing.Owner = entry.Owner
+		existing.Expires = entry.Expires
+		return nil
+	}
+
+	var replaced []int
+	for _, name := range entry.Names {
+		for _, existing := range hostsFile.FindByName(name) {
+			if slices.Contains(replaced, existing.ID) {
+				continue
+			}
+			if !slices.Equal(existing.Names, entry.Names) || !existing.IsManaged() {
+				return fmt.Errorf("cannot restore %s -> %s: entry %d already maps %s to %s (remove it first)",
+					entry.IP, strings.Join(entry.Names, ", "), existing.ID, name, existing.IP)
+			}
+			if !entry.IsManaged() {
+				entry.Block = existing.Block
+			}
+			replaced = append(replaced, existing.ID)
+		}
+	}
+	for _, id := range replaced {
+		hostsFile.RemoveEntry(id)
+	}
+
+	if !entry.IsManaged() {
+		entry.Block = hosts.DefaultBlock
+	}
+	hostsFile.AddEntry(entry)
+	return nil
+}
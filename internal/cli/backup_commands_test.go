@@ -0,0 +1,291 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vaxvhbe/hostsctl/internal/lock"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"512", 512, false},
+		{"64K", 64 << 10, false},
+		{"10M", 10 << 20, false},
+		{"10mb", 10 << 20, false},
+		{"1G", 1 << 30, false},
+		{"ten", 0, true},
+		{"-1M", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseByteSize(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseByteSize(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseByteSize(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatAge(t *testing.T) {
+	tests := []struct {
+		age  time.Duration
+		want string
+	}{
+		{10 * time.Second, "now"},
+		{5 * time.Minute, "5m"},
+		{3 * time.Hour, "3h"},
+		{47 * time.Hour, "47h"},
+		{12 * 24 * time.Hour, "12d"},
+	}
+
+	for _, tt := range tests {
+		if got := formatAge(tt.age); got != tt.want {
+			t.Errorf("formatAge(%v) = %q, want %q", tt.age, got, tt.want)
+		}
+	}
+}
+
+func TestCLI_runSelectiveRestore(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	backupFile := filepath.Join(tmpDir, "hosts.backup")
+	backup := "127.0.0.1\tlocalhost\n\n# BEGIN hostsctl\n10.0.0.1\tapi.local\n10.0.0.2\tweb.local\n10.0.0.3\tdb.local\t# primary\n# END hostsctl\n"
+	current := "127.0.0.1\tlocalhost\n\n# BEGIN hostsctl\n10.0.0.3\tdb.local\t# replica\n10.0.0.4\tnew.local\n# END hostsctl\n"
+
+	if err := os.WriteFile(backupFile, []byte(backup), 0644); err != nil {
+		t.Fatalf("Failed to write backup file: %v", err)
+	}
+	if err := os.WriteFile(hostsFile, []byte(current), 0644); err != nil {
+		t.Fatalf("Failed to write hosts file: %v", err)
+	}
+
+	cli := NewCLI()
+	cli.hostsFile = hostsFile
+
+	if err := cli.runSelectiveRestore(context.Background(), backupFile, "", []string{"missing.local"}, false); err == nil {
+		t.Error("runSelectiveRestore() should fail for a hostname that is not in the backup")
+	}
+
+	if err := cli.runSelectiveRestore(context.Background(), backupFile, "", []string{"api.local", "db.local"}, false); err != nil {
+		t.Fatalf("runSelectiveRestore() error = %v", err)
+	}
+
+	data, err := os.ReadFile(hostsFile)
+	if err != nil {
+		t.Fatalf("Failed to read hosts file: %v", err)
+	}
+	content := string(data)
+
+	for _, want := range []string{"10.0.0.1\tapi.local", "10.0.0.3\tdb.local\t# primary", "10.0.0.4\tnew.local"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("Expected %q in restored hosts file, got %q", want, content)
+		}
+	}
+	if strings.Contains(content, "web.local") {
+		t.Errorf("Entries that were not chosen should not be restored, got %q", content)
+	}
+
+	// Only web.local still differs from the backup and is offered interactively
+	cli = NewCLI()
+	cli.hostsFile = hostsFile
+	cli.in = strings.NewReader("y\n")
+
+	if err := cli.runSelectiveRestore(context.Background(), backupFile, "", nil, true); err != nil {
+		t.Fatalf("runSelectiveRestore() error = %v", err)
+	}
+
+	data, err = os.ReadFile(hostsFile)
+	if err != nil {
+		t.Fatalf("Failed to read hosts file: %v", err)
+	}
+	if !strings.Contains(string(data), "10.0.0.2\tweb.local") {
+		t.Errorf("Expected web.local to be restored interactively, got %q", string(data))
+	}
+}
+
+func TestCLI_runSelectiveRestoreChangedIP(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	backupFile := filepath.Join(tmpDir, "hosts.backup")
+	if err := os.WriteFile(backupFile, []byte("127.0.0.1\tlocalhost\n\n# BEGIN hostsctl\n10.0.0.1\tapi.local\n10.0.0.2\tweb.local\n# END hostsctl\n"), 0644); err != nil {
+		t.Fatalf("Failed to write backup file: %v", err)
+	}
+	current := "127.0.0.1\tlocalhost\n10.0.0.8\tweb.local\n\n# BEGIN hostsctl\n10.0.0.9\tapi.local\n# END hostsctl\n"
+	if err := os.WriteFile(hostsFile, []byte(current), 0644); err != nil {
+		t.Fatalf("Failed to write hosts file: %v", err)
+	}
+
+	cli := NewCLI()
+	cli.hostsFile = hostsFile
+
+	// The managed entry whose IP address changed is replaced, not duplicated
+	if err := cli.runSelectiveRestore(context.Background(), backupFile, "", []string{"api.local"}, false); err != nil {
+		t.Fatalf("runSelectiveRestore() error = %v", err)
+	}
+
+	data, err := os.ReadFile(hostsFile)
+	if err != nil {
+		t.Fatalf("Failed to read hosts file: %v", err)
+	}
+	content := string(data)
+	if !strings.Contains(content, "10.0.0.1\tapi.local") || strings.Contains(content, "10.0.0.9") {
+		t.Errorf("Expected api.local to map to 10.0.0.1 only, got %q", content)
+	}
+
+	// An unmanaged entry is not replaced behind the user's back
+	err = cli.runSelectiveRestore(context.Background(), backupFile, "", []string{"web.local"}, false)
+	if err == nil || !strings.Contains(err.Error(), "10.0.0.8") {
+		t.Errorf("runSelectiveRestore() error = %v, want a conflict with 10.0.0.8", err)
+	}
+
+	data, err = os.ReadFile(hostsFile)
+	if err != nil {
+		t.Fatalf("Failed to read hosts file: %v", err)
+	}
+	if strings.Contains(string(data), "10.0.0.2") {
+		t.Errorf("A conflicting entry should not be restored, got %q", string(data))
+	}
+}
+
+// lockCheckingReader answers a prompt after checking that the exclusive lock
+// of the hosts file is free.
+type lockCheckingReader struct {
+	t      *testing.T
+	lock   *lock.FileLock
+	answer *strings.Reader
+}
+
+func (r *lockCheckingReader) Read(p []byte) (int, error) {
+	if err := r.lock.TryLock(); err != nil {
+		r.t.Errorf("The hosts file should not be locked while prompting: %v", err)
+	} else {
+		_ = r.lock.Unlock()
+	}
+	return r.answer.Read(p)
+}
+
+func TestCLI_runSelectiveRestorePromptsWithoutLock(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	backupFile := filepath.Join(tmpDir, "hosts.backup")
+	if err := os.WriteFile(backupFile, []byte("127.0.0.1\tlocalhost\n\n# BEGIN hostsctl\n10.0.0.1\tapi.local\n# END hostsctl\n"), 0644); err != nil {
+		t.Fatalf("Failed to write backup file: %v", err)
+	}
+	if err := os.WriteFile(hostsFile, []byte("127.0.0.1\tlocalhost\n"), 0644); err != nil {
+		t.Fatalf("Failed to write hosts file: %v", err)
+	}
+
+	cli := NewCLI()
+	cli.hostsFile = hostsFile
+	cli.in = &lockCheckingReader{t: t, lock: cli.lockOptionsFor(hostsFile).NewFileLock(hostsFile), answer: strings.NewReader("y\n")}
+
+	if err := cli.runSelectiveRestore(context.Background(), backupFile, "", nil, true); err != nil {
+		t.Fatalf("runSelectiveRestore() error = %v", err)
+	}
+
+	data, err := os.ReadFile(hostsFile)
+	if err != nil {
+		t.Fatalf("Failed to read hosts file: %v", err)
+	}
+	if !strings.Contains(string(data), "10.0.0.1\tapi.local") {
+		t.Errorf("Expected api.local to be restored, got %q", string(data))
+	}
+}
+
+func TestCLI_runBackupDiff(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	if err := os.WriteFile(hostsFile, []byte("127.0.0.1\tlocalhost\n"), 0644); err != nil {
+		t.Fatalf("Failed to write hosts file: %v", err)
+	}
+
+	cli := NewCLI()
+	cli.hostsFile = hostsFile
+
+	if err := cli.runAdd(context.Background(), "10.0.0.1", []string{"api.local"}, "", Annotations{}); err != nil {
+		t.Fatalf("runAdd() error = %v", err)
+	}
+	if err := cli.runAdd(context.Background(), "10.0.0.2", []string{"web.local"}, "", Annotations{}); err != nil {
+		t.Fatalf("runAdd() error = %v", err)
+	}
+
+	backups, err := cli.newStore(false).ListBackups()
+	if err != nil {
+		t.Fatalf("ListBackups() error = %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups, got %d", len(backups))
+	}
+	// Both backups may share a modification time: tell them apart by content
+	older, newer := backups[0].ID, backups[1].ID
+	if backups[0].EntryCount > backups[1].EntryCount {
+		older, newer = newer, older
+	}
+
+	store := cli.newStore(false)
+	label, data, err := cli.readDiffSource(context.Background(), store, "")
+	if err != nil {
+		t.Fatalf("readDiffSource() error = %v", err)
+	}
+	if label != hostsFile || !strings.Contains(string(data), "web.local") {
+		t.Errorf("readDiffSource(\"\") = %q, %q, want the hosts file", label, string(data))
+	}
+
+	label, data, err = cli.readDiffSource(context.Background(), store, older)
+	if err != nil {
+		t.Fatalf("readDiffSource() error = %v", err)
+	}
+	if label != "backup "+older || strings.Contains(string(data), "api.local") {
+		t.Errorf("readDiffSource(%q) = %q, %q, want the content before the first add", older, label, string(data))
+	}
+
+	for _, format := range []string{"text", "unified", "json"} {
+		if err := cli.runBackupDiff(context.Background(), older, newer, format); err != nil {
+			t.Errorf("runBackupDiff(%s) error = %v", format, err)
+		}
+		if err := cli.runBackupDiff(context.Background(), older, "", format); err != nil {
+			t.Errorf("runBackupDiff(%s) against the hosts file error = %v", format, err)
+		}
+	}
+
+	if err := cli.runBackupDiff(context.Background(), older, newer, "html"); err == nil {
+		t.Error("runBackupDiff() should fail for an unsupported format")
+	}
+	if err := cli.runBackupDiff(context.Background(), "missing", "", "text"); err == nil {
+		t.Error("runBackupDiff() should fail for an unknown backup")
+	}
+}
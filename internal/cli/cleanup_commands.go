@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vaxvhbe/hostsctl/internal/hosts"
+	"github.com/vaxvhbe/hostsctl/internal/lock"
+)
+
+// cleanupResult is the JSON output of the cleanup command.
+type cleanupResult struct {
+	DryRun    bool             `json:"dry_run"`
+	LockFile  *lock.Status     `json:"lock_file,omitempty"` // Orphaned lock file, if any
+	TempFiles []hosts.TempFile `json:"temp_files"`
+}
+
+// buildCleanupCommand creates the cleanup command.
+func (c *CLI) buildCleanupCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cleanup",
+		Short: "Remove temporary and lock files left behind by interrupted commands",
+		Long: `Remove the temporary files and the lock file left behind by hostsctl
+commands that were killed while changing the hosts file.
+
+Temporary files are looked for next to the hosts file (or the file it links
+to) and in the backup directory. They are removed under the lock of the hosts
+file, so that the files of a command that is still running are left alone.
+A lock file is only removed if no running process holds it.
+
+Examples:
+  hostsctl cleanup --dry-run       # Show what would be removed
+  sudo hostsctl cleanup`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.runCleanup(cmd.Context())
+		},
+	}
+
+	return cmd
+}
+
+func (c *CLI) runCleanup(ctx context.Context) error {
+	status, err := c.lockOptions.Inspect(c.hostsFile)
+	if err != nil {
+		return err
+	}
+
+	result := cleanupResult{DryRun: c.dryRun}
+	// Nobody holds the lock file: taking and releasing the lock below removes it
+	if status.Mode == lock.ModeSidecar && status.Exists && !status.Held {
+		result.LockFile = status
+	}
+
+	return c.withLock(ctx, c.hostsFile, func() error {
+		store := c.newStore(false)
+
+		removed, err := store.RemoveTempFiles(c.dryRun)
+		if err != nil {
+			return err
+		}
+		result.TempFiles = removed
+
+		return c.reportCleanup(result)
+	})
+}
+
+// reportCleanup prints the files removed by the cleanup command.
+func (c *CLI) reportCleanup(result cleanupResult) error {
+	if c.jsonOutput {
+		if result.TempFiles == nil {
+			result.TempFiles = []hosts.TempFile{}
+		}
+		return json.NewEncoder(os.Stdout).Encode(result)
+	}
+
+	verb := "Removed"
+	if result.DryRun {
+		verb = "Would remove"
+	}
+
+	count := len(result.TempFiles)
+	if result.LockFile != nil {
+		count++
+		fmt.Printf("%s %s lock file %s\n", verb, lockState(result.LockFile), result.LockFile.Path)
+		if result.LockFile.Holder != nil {
+			c.printHolder(result.LockFile.Holder)
+		}
+	}
+	for _, file := range result.TempFiles {
+		fmt.Printf("%s %s (%d bytes, %s)\n", verb, file.Path, file.Size, file.ModTime.Format(time.RFC3339))
+	}
+
+	if count == 0 {
+		fmt.Println("Nothing to clean up")
+		return nil
+	}
+	fmt.Printf("%s %d file(s)\n", verb, count)
+	return nil
+}
@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCLI_runCleanup(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	tempFile := filepath.Join(tmpDir, ".hosts.hostsctl-123.tmp")
+	lockFile := hostsFile + ".lock"
+	for _, path := range []string{hostsFile, tempFile} {
+		if err := os.WriteFile(path, []byte("127.0.0.1\tlocalhost\n"), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", path, err)
+		}
+	}
+	// Left behind by a holder killed before recording itself
+	if err := os.WriteFile(lockFile, nil, 0644); err != nil {
+		t.Fatalf("Failed to create lock file: %v", err)
+	}
+
+	cli := NewCLI()
+	cli.hostsFile = hostsFile
+
+	cli.dryRun = true
+	if err := cli.runCleanup(context.Background()); err != nil {
+		t.Fatalf("runCleanup(dry run) error = %v", err)
+	}
+	for _, path := range []string{tempFile, lockFile} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Dry run should not remove %s: %v", path, err)
+		}
+	}
+
+	cli.dryRun = false
+	if err := cli.runCleanup(context.Background()); err != nil {
+		t.Fatalf("runCleanup() error = %v", err)
+	}
+	for _, path := range []string{tempFile, lockFile} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", path)
+		}
+	}
+	if _, err := os.Stat(hostsFile); err != nil {
+		t.Errorf("Hosts file should be kept: %v", err)
+	}
+}
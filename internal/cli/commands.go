@@ -1,11 +1,15 @@
 package cli
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"regexp"
 	"strings"
+	"syscall"
 	"text/tabwriter"
 	"time"
 
@@ -17,9 +21,43 @@ import (
 )
 
 type CLI struct {
-	hostsFile  string
-	noColor    bool
-	jsonOutput bool
+	hostsFile      string
+	fragmentDir    string
+	noColor        bool
+	jsonOutput     bool
+	strict         bool
+	punycode       bool
+	backupDir      string
+	backupCompress bool
+	backupKeep     int
+	backupMaxAge   time.Duration
+	backupMaxSize  string
+	retention      hosts.RetentionPolicy
+	command        string
+	operation      string
+	auditLog       string
+	arguments      []string
+	dryRun         bool
+	changes        []string
+	in             io.Reader
+	configPath     string
+	lockTimeout    time.Duration
+	lockOptions    lock.Options
+	linter         *hosts.Linter
+}
+
+// changeResult is the --json output of commands that modify the hosts file.
+type changeResult struct {
+	HostsFile     string              `json:"hosts_file"`
+	WriteStrategy hosts.WriteStrategy `json:"write_strategy"`
+	Changes       []string            `json:"changes"`
+}
+
+// dryRunResult is the --json output of commands run with --dry-run.
+type dryRunResult struct {
+	DryRun  bool     `json:"dry_run"`
+	Changes []string `json:"changes"`
+	*hosts.Preview
 }
 
 // ListFilters contains filtering options for the list command.
@@ -29,17 +67,40 @@ type ListFilters struct {
 	CommentFilter string
 	NameFilter    string
 	StatusFilter  string
+	TagFilter     string
+	OwnerFilter   string
+	ExpiredOnly   bool
 }
 
 func NewCLI() *CLI {
 	return &CLI{
-		hostsFile: "/etc/hosts",
+		hostsFile:   "/etc/hosts",
+		fragmentDir: "/etc/hosts.d",
+		backupKeep:  hosts.DefaultRetentionPolicy.KeepLast,
+		retention:   hosts.DefaultRetentionPolicy,
+		in:          os.Stdin,
+		lockTimeout: lock.QuickTimeout,
+		lockOptions: lock.Options{Timeout: lock.QuickTimeout},
+		linter:      hosts.NewLinter(),
 	}
 }
 
+// Execute runs the command given on the command line. SIGINT and SIGTERM
+// cancel the context of the command, which stops waiting for locks and
+// abandons a change that is not written yet, removing its temporary files.
+// A second signal terminates the process immediately.
 func (c *CLI) Execute() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		// Restore the default behavior so that a second signal is not ignored
+		<-ctx.Done()
+		stop()
+	}()
+
 	rootCmd := c.buildRootCommand()
-	return rootCmd.Execute()
+	return rootCmd.ExecuteContext(ctx)
 }
 
 func (c *CLI) buildRootCommand() *cobra.Command {
@@ -47,11 +108,35 @@ func (c *CLI) buildRootCommand() *cobra.Command {
 		Use:   "hostsctl",
 		Short: "A CLI manager for /etc/hosts",
 		Long:  "hostsctl is a command-line tool for safely managing entries in /etc/hosts files.",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			c.command = strings.Join(append([]string{cmd.Root().Name()}, os.Args[1:]...), " ")
+			c.operation = strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+			c.arguments = os.Args[1:]
+			if c.auditLog == "" {
+				c.auditLog = hosts.DefaultAuditLogPath()
+			}
+			if err := c.loadConfig(cmd); err != nil {
+				return err
+			}
+			return c.loadRetentionPolicy()
+		},
 	}
 
 	rootCmd.PersistentFlags().StringVar(&c.hostsFile, "hosts-file", "/etc/hosts", "Path to hosts file")
+	rootCmd.PersistentFlags().StringVar(&c.fragmentDir, "fragment-dir", "/etc/hosts.d", "Directory of hosts file fragments compiled by 'compile'")
 	rootCmd.PersistentFlags().BoolVar(&c.noColor, "no-color", false, "Disable colored output")
 	rootCmd.PersistentFlags().BoolVar(&c.jsonOutput, "json", false, "Output in JSON format")
+	rootCmd.PersistentFlags().BoolVar(&c.punycode, "punycode", false, "Show internationalized hostnames in punycode form as stored in the file")
+	rootCmd.PersistentFlags().BoolVar(&c.strict, "strict", false, "Abort instead of writing when the hosts file has parse problems")
+	rootCmd.PersistentFlags().StringVar(&c.backupDir, "backup-dir", "", "Directory for automatic backups (default: next to the hosts file)")
+	rootCmd.PersistentFlags().BoolVar(&c.backupCompress, "backup-compress", false, "Compress automatic backups with gzip")
+	rootCmd.PersistentFlags().IntVar(&c.backupKeep, "backup-keep", hosts.DefaultRetentionPolicy.KeepLast, "Number of automatic backups to keep (0 for no limit)")
+	rootCmd.PersistentFlags().DurationVar(&c.backupMaxAge, "backup-max-age", 0, "Remove automatic backups older than this, e.g. 720h (0 for no limit)")
+	rootCmd.PersistentFlags().StringVar(&c.auditLog, "audit-log", "", "Append-only log of all changes (default: /var/log/hostsctl/audit.log as root, else in ~/.local/state/hostsctl)")
+	rootCmd.PersistentFlags().BoolVar(&c.dryRun, "dry-run", false, "Show the changes as a diff without writing the hosts file, backups or locks")
+	rootCmd.PersistentFlags().StringVar(&c.backupMaxSize, "backup-max-size", "", "Maximum total size of automatic backups, e.g. 10M (empty for no limit)")
+	rootCmd.PersistentFlags().StringVar(&c.configPath, "config", "", "Configuration file (default: /etc/hostsctl/config.yaml as root, else in ~/.config/hostsctl)")
+	rootCmd.PersistentFlags().DurationVar(&c.lockTimeout, "lock-timeout", lock.QuickTimeout, "How long to wait for the lock of the hosts file")
 
 	rootCmd.AddCommand(c.buildListCommand())
 	rootCmd.AddCommand(c.buildAddCommand())
@@ -63,6 +148,13 @@ func (c *CLI) buildRootCommand() *cobra.Command {
 	rootCmd.AddCommand(c.buildImportCommand())
 	rootCmd.AddCommand(c.buildExportCommand())
 	rootCmd.AddCommand(c.buildVerifyCommand())
+	rootCmd.AddCommand(c.buildAdoptCommand())
+	rootCmd.AddCommand(c.buildCompileCommand())
+	rootCmd.AddCommand(c.buildUndoCommand())
+	rootCmd.AddCommand(c.buildRedoCommand())
+	rootCmd.AddCommand(c.buildHistoryCommand())
+	rootCmd.AddCommand(c.buildLockCommand())
+	rootCmd.AddCommand(c.buildCleanupCommand())
 	rootCmd.AddCommand(c.buildProfileCommand())
 	rootCmd.AddCommand(c.buildSearchCommand())
 	rootCmd.AddCommand(c.buildCompletionCommand())
@@ -75,7 +167,8 @@ func (c *CLI) buildRootCommand() *cobra.Command {
 
 func (c *CLI) buildListCommand() *cobra.Command {
 	var showAll bool
-	var filterIP, filterComment, filterName, filterStatus string
+	var filterIP, filterComment, filterName, filterStatus, filterTag, filterOwner string
+	var expiredOnly bool
 
 	cmd := &cobra.Command{
 		Use:   "list",
@@ -87,12 +180,17 @@ Filters can be used to narrow down the results:
   --name-filter   Show entries matching hostname pattern
   --comment-filter Show entries matching comment pattern
   --status-filter Show entries with specific status (enabled|disabled)
+  --tag           Show entries carrying a tag (from "@tags=" annotations)
+  --owner         Show entries owned by someone (from "@owner=" annotations)
+  --expired       Show only entries whose "@expires=" date has passed
 
 Examples:
   hostsctl list --all                    # Show all entries
   hostsctl list --ip-filter "192.168.*" # Show local network entries
   hostsctl list --name-filter "*.local" # Show .local domains
-  hostsctl list --status enabled        # Show only enabled entries`,
+  hostsctl list --status enabled        # Show only enabled entries
+  hostsctl list --tag payments --all    # Show entries tagged "payments"
+  hostsctl list --expired --all         # Show expired entries`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			filters := ListFilters{
 				ShowAll:       showAll,
@@ -100,8 +198,11 @@ Examples:
 				CommentFilter: filterComment,
 				NameFilter:    filterName,
 				StatusFilter:  filterStatus,
+				TagFilter:     filterTag,
+				OwnerFilter:   filterOwner,
+				ExpiredOnly:   expiredOnly,
 			}
-			return c.runListWithFilters(filters)
+			return c.runListWithFilters(cmd.Context(), filters)
 		},
 	}
 
@@ -110,25 +211,36 @@ Examples:
 	cmd.Flags().StringVar(&filterComment, "comment-filter", "", "Filter by comment pattern")
 	cmd.Flags().StringVar(&filterName, "name-filter", "", "Filter by hostname pattern")
 	cmd.Flags().StringVar(&filterStatus, "status-filter", "", "Filter by status (enabled|disabled)")
+	cmd.Flags().StringVar(&filterTag, "tag", "", "Filter by tag")
+	cmd.Flags().StringVar(&filterOwner, "owner", "", "Filter by owner")
+	cmd.Flags().BoolVar(&expiredOnly, "expired", false, "Show only expired entries")
 
 	return cmd
 }
 
 func (c *CLI) buildAddCommand() *cobra.Command {
-	var ip, comment string
-	var names []string
+	var ip, comment, owner, expires, fragment string
+	var names, tags []string
 
 	cmd := &cobra.Command{
 		Use:   "add",
 		Short: "Add a new hosts entry",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return c.runAdd(ip, names, comment)
+			annotations := Annotations{Tags: tags, Owner: owner, Expires: expires}
+			if fragment != "" {
+				return c.runFragmentAdd(cmd.Context(), fragment, ip, names, comment, annotations)
+			}
+			return c.runAdd(cmd.Context(), ip, names, comment, annotations)
 		},
 	}
 
 	cmd.Flags().StringVar(&ip, "ip", "", "IP address (required)")
 	cmd.Flags().StringSliceVar(&names, "name", []string{}, "Hostname(s) (required, can be specified multiple times)")
 	cmd.Flags().StringVar(&comment, "comment", "", "Comment for the entry")
+	cmd.Flags().StringSliceVar(&tags, "tag", []string{}, "Tag(s) for the entry (can be specified multiple times)")
+	cmd.Flags().StringVar(&owner, "owner", "", "Owner of the entry")
+	cmd.Flags().StringVar(&expires, "expires", "", "Expiry date of the entry (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&fragment, "fragment", "", "Add the entry to a fragment in the fragment directory instead")
 	_ = cmd.MarkFlagRequired("ip")
 	_ = cmd.MarkFlagRequired("name")
 
@@ -136,19 +248,23 @@ func (c *CLI) buildAddCommand() *cobra.Command {
 }
 
 func (c *CLI) buildRemoveCommand() *cobra.Command {
-	var name string
+	var name, fragment string
 	var id int
 
 	cmd := &cobra.Command{
 		Use:   "rm",
 		Short: "Remove hosts entry",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return c.runRemove(id, name)
+			if fragment != "" {
+				return c.runFragmentRemove(cmd.Context(), fragment, id, name)
+			}
+			return c.runRemove(cmd.Context(), id, name)
 		},
 	}
 
 	cmd.Flags().StringVar(&name, "name", "", "Remove by hostname")
 	cmd.Flags().IntVar(&id, "id", 0, "Remove by entry ID")
+	cmd.Flags().StringVar(&fragment, "fragment", "", "Remove the entry from a fragment in the fragment directory instead")
 
 	return cmd
 }
@@ -161,7 +277,7 @@ func (c *CLI) buildEnableCommand() *cobra.Command {
 		Use:   "enable",
 		Short: "Enable hosts entry",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return c.runEnable(id, name)
+			return c.runEnable(cmd.Context(), id, name)
 		},
 	}
 
@@ -179,7 +295,7 @@ func (c *CLI) buildDisableCommand() *cobra.Command {
 		Use:   "disable",
 		Short: "Disable hosts entry",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return c.runDisable(id, name)
+			return c.runDisable(cmd.Context(), id, name)
 		},
 	}
 
@@ -201,22 +317,51 @@ func (c *CLI) buildBackupCommand() *cobra.Command {
 	}
 
 	cmd.Flags().StringVar(&output, "out", "", "Output path for backup")
+
+	cmd.AddCommand(c.buildBackupListCommand())
+	cmd.AddCommand(c.buildBackupShowCommand())
+	cmd.AddCommand(c.buildBackupDiffCommand())
+	cmd.AddCommand(c.buildBackupPruneCommand())
+
 	return cmd
 }
 
 func (c *CLI) buildRestoreCommand() *cobra.Command {
-	var file string
+	var file, id string
+	var names []string
+	var interactive bool
 
 	cmd := &cobra.Command{
 		Use:   "restore",
 		Short: "Restore hosts file from backup",
+		Long: `Restore the hosts file from a backup file or from an automatic backup.
+
+Automatic backups are checked against their SHA-256 checksum and are not
+restored if they were modified or corrupted.
+
+With --name or --interactive, only the chosen entries are restored: entries of
+the backup that were deleted or modified since are put back, and everything
+else in the hosts file is kept as it is.
+
+Examples:
+  hostsctl restore --id 3f2a9c81d04e                 # ID from 'hostsctl backup list'
+  hostsctl restore --file /tmp/hosts.backup
+  hostsctl restore --id 3f2a9c81d04e --name foo.local --name bar.local
+  hostsctl restore --id 3f2a9c81d04e --interactive   # Choose entries one by one`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return c.runRestore(file)
+			if len(names) > 0 || interactive {
+				return c.runSelectiveRestore(cmd.Context(), file, id, names, interactive)
+			}
+			return c.runRestore(cmd.Context(), file, id)
 		},
 	}
 
-	cmd.Flags().StringVar(&file, "file", "", "Backup file to restore from (required)")
-	_ = cmd.MarkFlagRequired("file")
+	cmd.Flags().StringVar(&file, "file", "", "Backup file to restore from")
+	cmd.Flags().StringVar(&id, "id", "", "ID of the automatic backup to restore from")
+	cmd.Flags().StringSliceVar(&names, "name", nil, "Only restore the entries with this hostname (repeatable)")
+	cmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "Choose the entries to restore one by one")
+	cmd.MarkFlagsOneRequired("file", "id")
+	cmd.MarkFlagsMutuallyExclusive("file", "id")
 
 	return cmd
 }
@@ -228,7 +373,7 @@ func (c *CLI) buildImportCommand() *cobra.Command {
 		Use:   "import",
 		Short: "Import hosts entries from file",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return c.runImport(file, format)
+			return c.runImport(cmd.Context(), file, format)
 		},
 	}
 
@@ -246,7 +391,7 @@ func (c *CLI) buildExportCommand() *cobra.Command {
 		Use:   "export",
 		Short: "Export hosts entries to file",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return c.runExport(file, format)
+			return c.runExport(cmd.Context(), file, format)
 		},
 	}
 
@@ -257,22 +402,90 @@ func (c *CLI) buildExportCommand() *cobra.Command {
 	return cmd
 }
 
-func (c *CLI) buildVerifyCommand() *cobra.Command {
+func (c *CLI) buildAdoptCommand() *cobra.Command {
+	var name, block string
+	var id int
+	var all bool
+
 	cmd := &cobra.Command{
-		Use:   "verify",
-		Short: "Verify hosts file syntax and check for issues",
+		Use:   "adopt",
+		Short: "Move existing entries into the hostsctl managed block",
+		Long: `Move entries that are not managed by hostsctl into a managed block.
+
+hostsctl only modifies lines between "# BEGIN hostsctl" and "# END hostsctl"
+markers. Use adopt to hand over existing entries so that they can be removed,
+enabled or disabled with hostsctl.
+
+Examples:
+  hostsctl adopt --name app.local        # Adopt entries for a hostname
+  hostsctl adopt --id 4                  # Adopt a single entry
+  hostsctl adopt --all --block dev       # Adopt everything into the "dev" block`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return c.runVerify()
+			return c.runAdopt(cmd.Context(), id, name, all, block)
 		},
 	}
 
+	cmd.Flags().StringVar(&name, "name", "", "Adopt by hostname")
+	cmd.Flags().IntVar(&id, "id", 0, "Adopt by entry ID")
+	cmd.Flags().BoolVar(&all, "all", false, "Adopt all unmanaged entries except localhost defaults")
+	cmd.Flags().StringVar(&block, "block", hosts.DefaultBlock, "Managed block to move the entries into")
+
 	return cmd
 }
 
-func (c *CLI) runListWithFilters(filters ListFilters) error {
-	store := hosts.NewStore(c.hostsFile, false)
+// newStore creates a Store for the hosts file with the configured backup
+// directory, compression and retention policy. Backups record the command
+// line that triggered them.
+func (c *CLI) newStore(strict bool) *hosts.Store {
+	store := hosts.NewStore(c.hostsFile, strict)
+	store.SetBackupDir(c.backupDir)
+	store.SetBackupCompression(c.backupCompress)
+	store.SetRetention(c.retention)
+	store.SetCommand(c.command)
+	store.SetOperation(c.operation, c.arguments)
+	store.SetDryRun(c.dryRun)
+	store.SetHostsFileLocked(c.lockOptions.Mode == lock.ModeHostsFile && !c.dryRun)
+	store.SetLockTimeout(c.lockOptions.Timeout)
+	if c.auditLog != "" {
+		store.SetAuditLog(hosts.NewAuditLog(c.auditLog))
+	}
+	return store
+}
+
+// withLock runs fn while holding the lock of path. Dry runs write nothing and
+// run fn without taking the lock.
+func (c *CLI) withLock(ctx context.Context, path string, fn func() error) error {
+	if c.dryRun {
+		return fn()
+	}
+	return c.lockOptionsFor(path).WithLock(ctx, path, fn)
+}
+
+// withReadLock runs fn while holding the shared lock of path, so that it does
+// not read the hosts file while another hostsctl process changes it. Readers
+// do not block each other.
+func (c *CLI) withReadLock(ctx context.Context, path string, fn func() error) error {
+	if c.dryRun {
+		return fn()
+	}
+	return c.lockOptionsFor(path).WithReadLock(ctx, path, fn)
+}
 
-	hostsFile, err := store.Load()
+// loadShared loads the hosts file under the shared lock.
+func (c *CLI) loadShared(ctx context.Context, store *hosts.Store) (*hosts.HostsFile, error) {
+	var hostsFile *hosts.HostsFile
+	err := c.withReadLock(ctx, c.hostsFile, func() error {
+		var err error
+		hostsFile, err = store.LoadContext(ctx)
+		return err
+	})
+	return hostsFile, err
+}
+
+func (c *CLI) runListWithFilters(ctx context.Context, filters ListFilters) error {
+	store := c.newStore(false)
+
+	hostsFile, err := c.loadShared(ctx, store)
 	if err != nil {
 		return fmt.Errorf("failed to load hosts file: %w", err)
 	}
@@ -288,150 +501,190 @@ func (c *CLI) runListWithFilters(filters ListFilters) error {
 	return nil
 }
 
-func (c *CLI) runAdd(ip string, names []string, comment string) error {
+func (c *CLI) runAdd(ctx context.Context, ip string, names []string, comment string, annotations Annotations) error {
+	entry, err := newEntry(ip, names, comment, annotations)
+	if err != nil {
+		return err
+	}
+
+	return c.withLock(ctx, c.hostsFile, func() error {
+		store := c.newStore(c.strict)
+
+		err := c.updateHostsFile(ctx, store, func(hostsFile *hosts.HostsFile) error {
+			hostsFile.AddEntry(entry)
+
+			c.reportChange("Added entry: %s -> %s", entry.IP, c.displayNames(entry.Names))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return c.reportSaved(store)
+	})
+}
+
+// newEntry validates the values given on the command line and builds the entry
+// they describe, with hostnames converted to punycode.
+func newEntry(ip string, names []string, comment string, annotations Annotations) (hosts.Entry, error) {
 	if err := pkg.ValidateIP(ip); err != nil {
-		return fmt.Errorf("invalid IP: %s", err.Message)
+		return hosts.Entry{}, fmt.Errorf("invalid IP: %s", err.Message)
 	}
 
 	if errs := pkg.ValidateHostnames(names); len(errs) > 0 {
-		return fmt.Errorf("invalid hostnames: %s", errs[0].Message)
+		return hosts.Entry{}, fmt.Errorf("invalid hostnames: %s", errs[0].Message)
 	}
 
 	if comment != "" {
 		if err := pkg.ValidateComment(comment); err != nil {
-			return fmt.Errorf("invalid comment: %s", err.Message)
+			return hosts.Entry{}, fmt.Errorf("invalid comment: %s", err.Message)
 		}
 	}
 
-	return lock.WithQuickLock(c.hostsFile, func() error {
-		store := hosts.NewStore(c.hostsFile, false)
-
-		hostsFile, err := store.Load()
-		if err != nil {
-			return fmt.Errorf("failed to load hosts file: %w", err)
-		}
-
-		entry := hosts.Entry{
-			IP:      pkg.NormalizeIP(ip),
-			Names:   names,
-			Comment: comment,
-		}
+	entry := hosts.Entry{
+		IP:      pkg.NormalizeIP(ip),
+		Names:   names,
+		Comment: comment,
+		Block:   hosts.DefaultBlock,
+	}
 
-		hostsFile.AddEntry(entry)
+	if err := entry.ToASCIINames(); err != nil {
+		return hosts.Entry{}, fmt.Errorf("invalid hostnames: %w", err)
+	}
 
-		if err := store.Save(hostsFile); err != nil {
-			return fmt.Errorf("failed to save hosts file: %w", err)
-		}
+	if err := annotations.apply(&entry); err != nil {
+		return hosts.Entry{}, err
+	}
 
-		fmt.Printf("Added entry: %s -> %s\n", entry.IP, strings.Join(entry.Names, ", "))
-		return nil
-	})
+	return entry, nil
 }
 
-func (c *CLI) runRemove(id int, name string) error {
+func (c *CLI) runRemove(ctx context.Context, id int, name string) error {
 	if id == 0 && name == "" {
 		return fmt.Errorf("either --id or --name must be specified")
 	}
 
-	return lock.WithQuickLock(c.hostsFile, func() error {
-		store := hosts.NewStore(c.hostsFile, false)
+	return c.withLock(ctx, c.hostsFile, func() error {
+		store := c.newStore(c.strict)
 
-		hostsFile, err := store.Load()
-		if err != nil {
-			return fmt.Errorf("failed to load hosts file: %w", err)
-		}
+		err := c.updateHostsFile(ctx, store, func(hostsFile *hosts.HostsFile) error {
+			if id != 0 {
+				entry := hostsFile.FindByID(id)
+				if entry == nil {
+					return fmt.Errorf("entry with ID %d not found", id)
+				}
+				if err := requireManaged(entry); err != nil {
+					return err
+				}
+				hostsFile.RemoveEntry(id)
+				c.reportChange("Removed entry with ID %d", id)
+			} else {
+				ids, err := managedIDsByName(hostsFile, name)
+				if err != nil {
+					return err
+				}
 
-		if id != 0 {
-			if !hostsFile.RemoveEntry(id) {
-				return fmt.Errorf("entry with ID %d not found", id)
-			}
-			fmt.Printf("Removed entry with ID %d\n", id)
-		} else {
-			entries := hostsFile.FindByName(name)
-			if len(entries) == 0 {
-				return fmt.Errorf("no entries found with hostname %s", name)
+				for _, entryID := range ids {
+					hostsFile.RemoveEntry(entryID)
+					c.reportChange("Removed entry with ID %d (%s)", entryID, name)
+				}
 			}
 
-			for _, entry := range entries {
-				hostsFile.RemoveEntry(entry.ID)
-				fmt.Printf("Removed entry with ID %d (%s)\n", entry.ID, name)
-			}
+			return nil
+		})
+		if err != nil {
+			return err
 		}
-
-		return store.Save(hostsFile)
+		return c.reportSaved(store)
 	})
 }
 
-func (c *CLI) runEnable(id int, name string) error {
+func (c *CLI) runEnable(ctx context.Context, id int, name string) error {
 	if id == 0 && name == "" {
 		return fmt.Errorf("either --id or --name must be specified")
 	}
 
-	return lock.WithQuickLock(c.hostsFile, func() error {
-		store := hosts.NewStore(c.hostsFile, false)
+	return c.withLock(ctx, c.hostsFile, func() error {
+		store := c.newStore(c.strict)
 
-		hostsFile, err := store.Load()
-		if err != nil {
-			return fmt.Errorf("failed to load hosts file: %w", err)
-		}
+		err := c.updateHostsFile(ctx, store, func(hostsFile *hosts.HostsFile) error {
+			if id != 0 {
+				entry := hostsFile.FindByID(id)
+				if entry == nil {
+					return fmt.Errorf("entry with ID %d not found", id)
+				}
+				if err := requireManaged(entry); err != nil {
+					return err
+				}
+				hostsFile.EnableEntry(id)
+				c.reportChange("Enabled entry with ID %d", id)
+			} else {
+				ids, err := managedIDsByName(hostsFile, name)
+				if err != nil {
+					return err
+				}
 
-		if id != 0 {
-			if !hostsFile.EnableEntry(id) {
-				return fmt.Errorf("entry with ID %d not found", id)
-			}
-			fmt.Printf("Enabled entry with ID %d\n", id)
-		} else {
-			entries := hostsFile.FindByName(name)
-			if len(entries) == 0 {
-				return fmt.Errorf("no entries found with hostname %s", name)
+				for _, entryID := range ids {
+					hostsFile.EnableEntry(entryID)
+					c.reportChange("Enabled entry with ID %d (%s)", entryID, name)
+				}
 			}
 
-			for _, entry := range entries {
-				hostsFile.EnableEntry(entry.ID)
-				fmt.Printf("Enabled entry with ID %d (%s)\n", entry.ID, name)
-			}
+			return nil
+		})
+		if err != nil {
+			return err
 		}
-
-		return store.Save(hostsFile)
+		return c.reportSaved(store)
 	})
 }
 
-func (c *CLI) runDisable(id int, name string) error {
+func (c *CLI) runDisable(ctx context.Context, id int, name string) error {
 	if id == 0 && name == "" {
 		return fmt.Errorf("either --id or --name must be specified")
 	}
 
-	return lock.WithQuickLock(c.hostsFile, func() error {
-		store := hosts.NewStore(c.hostsFile, false)
+	return c.withLock(ctx, c.hostsFile, func() error {
+		store := c.newStore(c.strict)
 
-		hostsFile, err := store.Load()
-		if err != nil {
-			return fmt.Errorf("failed to load hosts file: %w", err)
-		}
+		err := c.updateHostsFile(ctx, store, func(hostsFile *hosts.HostsFile) error {
+			if id != 0 {
+				entry := hostsFile.FindByID(id)
+				if entry == nil {
+					return fmt.Errorf("entry with ID %d not found", id)
+				}
+				if err := requireManaged(entry); err != nil {
+					return err
+				}
+				hostsFile.DisableEntry(id)
+				c.reportChange("Disabled entry with ID %d", id)
+			} else {
+				ids, err := managedIDsByName(hostsFile, name)
+				if err != nil {
+					return err
+				}
 
-		if id != 0 {
-			if !hostsFile.DisableEntry(id) {
-				return fmt.Errorf("entry with ID %d not found", id)
-			}
-			fmt.Printf("Disabled entry with ID %d\n", id)
-		} else {
-			entries := hostsFile.FindByName(name)
-			if len(entries) == 0 {
-				return fmt.Errorf("no entries found with hostname %s", name)
+				for _, entryID := range ids {
+					hostsFile.DisableEntry(entryID)
+					c.reportChange("Disabled entry with ID %d (%s)", entryID, name)
+				}
 			}
 
-			for _, entry := range entries {
-				hostsFile.DisableEntry(entry.ID)
-				fmt.Printf("Disabled entry with ID %d (%s)\n", entry.ID, name)
-			}
+			return nil
+		})
+		if err != nil {
+			return err
 		}
-
-		return store.Save(hostsFile)
+		return c.reportSaved(store)
 	})
 }
 
 func (c *CLI) runBackup(output string) error {
-	store := hosts.NewStore(c.hostsFile, false)
+	if c.dryRun {
+		fmt.Printf("Dry run: no backup of %s was created\n", c.hostsFile)
+		return nil
+	}
+
+	store := c.newStore(false)
 
 	backup, err := store.Backup(output)
 	if err != nil {
@@ -448,20 +701,38 @@ func (c *CLI) runBackup(output string) error {
 	return nil
 }
 
-func (c *CLI) runRestore(file string) error {
-	return lock.WithQuickLock(c.hostsFile, func() error {
-		store := hosts.NewStore(c.hostsFile, false)
+func (c *CLI) runRestore(ctx context.Context, file, id string) error {
+	return c.withLock(ctx, c.hostsFile, func() error {
+		store := c.newStore(false)
+
+		file, err := resolveBackup(store, file, id)
+		if err != nil {
+			return err
+		}
 
-		if err := store.Restore(file); err != nil {
+		if err := store.RestoreContext(ctx, file); err != nil {
 			return fmt.Errorf("failed to restore from backup: %w", err)
 		}
 
-		fmt.Printf("Restored hosts file from: %s\n", file)
-		return nil
+		c.reportChange("Restored hosts file from: %s", file)
+		return c.reportSaved(store)
 	})
 }
 
-func (c *CLI) runImport(file, format string) error {
+// resolveBackup returns the path of the backup given by --file or --id.
+func resolveBackup(store *hosts.Store, file, id string) (string, error) {
+	if id == "" {
+		return file, nil
+	}
+
+	backup, err := store.FindBackup(id)
+	if err != nil {
+		return "", err
+	}
+	return backup.Path, nil
+}
+
+func (c *CLI) runImport(ctx context.Context, file, format string) error {
 	// Validate file path to prevent directory traversal
 	if err := pkg.ValidateSecurePath(file); err != nil {
 		return fmt.Errorf("invalid file path: %w", err)
@@ -486,31 +757,97 @@ func (c *CLI) runImport(file, format string) error {
 		return fmt.Errorf("failed to parse import file: %w", err)
 	}
 
-	return lock.WithQuickLock(c.hostsFile, func() error {
-		store := hosts.NewStore(c.hostsFile, false)
+	for i := range profile.Entries {
+		if err := profile.Entries[i].ToASCIINames(); err != nil {
+			return fmt.Errorf("invalid entry %d in import file: %w", i+1, err)
+		}
+	}
 
-		hostsFile, err := store.Load()
+	return c.withLock(ctx, c.hostsFile, func() error {
+		store := c.newStore(c.strict)
+
+		err := c.updateHostsFile(ctx, store, func(hostsFile *hosts.HostsFile) error {
+			for _, entry := range profile.Entries {
+				if !entry.IsManaged() {
+					entry.Block = hosts.DefaultBlock
+				}
+				hostsFile.AddEntry(entry)
+			}
+
+			c.reportChange("Imported %d entries from %s", len(profile.Entries), file)
+			return nil
+		})
 		if err != nil {
-			return fmt.Errorf("failed to load hosts file: %w", err)
+			return err
 		}
+		return c.reportSaved(store)
+	})
+}
 
-		for _, entry := range profile.Entries {
-			hostsFile.AddEntry(entry)
-		}
+func (c *CLI) runAdopt(ctx context.Context, id int, name string, all bool, block string) error {
+	if id == 0 && name == "" && !all {
+		return fmt.Errorf("one of --id, --name or --all must be specified")
+	}
 
-		if err := store.Save(hostsFile); err != nil {
-			return fmt.Errorf("failed to save hosts file: %w", err)
-		}
+	if block == "" {
+		return fmt.Errorf("block name cannot be empty")
+	}
 
-		fmt.Printf("Imported %d entries from %s\n", len(profile.Entries), file)
-		return nil
+	return c.withLock(ctx, c.hostsFile, func() error {
+		store := c.newStore(c.strict)
+
+		err := c.updateHostsFile(ctx, store, func(hostsFile *hosts.HostsFile) error {
+			var candidates []*hosts.Entry
+			switch {
+			case id != 0:
+				entry := hostsFile.FindByID(id)
+				if entry == nil {
+					return fmt.Errorf("entry with ID %d not found", id)
+				}
+				candidates = append(candidates, entry)
+			case name != "":
+				var err error
+				candidates, err = findByName(hostsFile, name)
+				if err != nil {
+					return err
+				}
+				if len(candidates) == 0 {
+					return fmt.Errorf("no entries found with hostname %s", name)
+				}
+			default:
+				for i := range hostsFile.Entries {
+					if !isSystemEntry(hostsFile.Entries[i]) {
+						candidates = append(candidates, &hostsFile.Entries[i])
+					}
+				}
+			}
+
+			adopted := 0
+			for _, entry := range candidates {
+				if hostsFile.AdoptEntry(entry.ID, block) {
+					adopted++
+					c.reportChange("Adopted entry with ID %d (%s) into block '%s'", entry.ID, c.displayNames(entry.Names), block)
+				}
+			}
+
+			if adopted == 0 {
+				c.reportChange("No unmanaged entries to adopt")
+				return hosts.ErrNoChanges
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return c.reportSaved(store)
 	})
 }
 
-func (c *CLI) runExport(file, format string) error {
-	store := hosts.NewStore(c.hostsFile, false)
+func (c *CLI) runExport(ctx context.Context, file, format string) error {
+	store := c.newStore(false)
 
-	hostsFile, err := store.Load()
+	hostsFile, err := c.loadShared(ctx, store)
 	if err != nil {
 		return fmt.Errorf("failed to load hosts file: %w", err)
 	}
@@ -545,33 +882,104 @@ func (c *CLI) runExport(file, format string) error {
 	return nil
 }
 
-func (c *CLI) runVerify() error {
-	store := hosts.NewStore(c.hostsFile, true)
+// updateHostsFile applies fn to the hosts file through store.Update, after
+// reporting any parse problems. fn is applied again if another program
+// modifies the file concurrently, so the changes it reported are reset first.
+func (c *CLI) updateHostsFile(ctx context.Context, store *hosts.Store, fn func(*hosts.HostsFile) error) error {
+	return store.UpdateContext(ctx, func(hostsFile *hosts.HostsFile) error {
+		c.changes = nil
 
-	issues, err := store.Verify()
-	if err != nil {
-		return fmt.Errorf("failed to verify hosts file: %w", err)
+		if err := c.reportDiagnostics(hostsFile); err != nil {
+			return err
+		}
+		return fn(hostsFile)
+	})
+}
+
+// reportChange records a line describing a change to the hosts file, to be
+// printed by reportSaved once the change is saved.
+func (c *CLI) reportChange(format string, args ...interface{}) {
+	c.changes = append(c.changes, fmt.Sprintf(format, args...))
+}
+
+// reportSaved finishes a command that modified the hosts file by printing the
+// recorded changes. With --json it also reports the strategy used to write
+// the file. With --dry-run it prints the previewed change instead.
+func (c *CLI) reportSaved(store *hosts.Store) error {
+	if c.dryRun {
+		return c.reportPreview(store.Preview(), c.hostsFile)
 	}
 
-	if c.jsonOutput {
-		result := map[string]interface{}{
-			"valid":  len(issues) == 0,
-			"issues": issues,
+	if !c.jsonOutput {
+		for _, change := range c.changes {
+			fmt.Println(change)
 		}
-		return json.NewEncoder(os.Stdout).Encode(result)
+		return nil
+	}
+
+	changes := c.changes
+	if changes == nil {
+		changes = []string{}
+	}
+	return json.NewEncoder(os.Stdout).Encode(changeResult{
+		HostsFile:     c.hostsFile,
+		WriteStrategy: store.WriteStrategy(),
+		Changes:       changes,
+	})
+}
+
+// reportPreview prints the change a dry run would have made to path, as a
+// unified diff or, with --json, as a change set. preview is nil if the command
+// had nothing to write.
+func (c *CLI) reportPreview(preview *hosts.Preview, path string) error {
+	if preview == nil {
+		preview = &hosts.Preview{Path: path, Added: []string{}, Removed: []string{}}
+	}
+
+	changes := c.changes
+	if changes == nil {
+		changes = []string{}
 	}
 
-	if len(issues) == 0 {
-		fmt.Println("✓ Hosts file is valid")
+	if c.jsonOutput {
+		return json.NewEncoder(os.Stdout).Encode(dryRunResult{
+			DryRun:  true,
+			Changes: changes,
+			Preview: preview,
+		})
+	}
+
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+
+	if !preview.HasChanges() {
+		fmt.Printf("Dry run: %s would not change\n", preview.Path)
+		return nil
+	}
+
+	fmt.Print(preview.Diff)
+	fmt.Printf("Dry run: %s was not modified (+%d -%d lines)\n", preview.Path, len(preview.Added), len(preview.Removed))
+	return nil
+}
+
+// reportDiagnostics prints a summary of the parse problems found in the hosts file
+// to stderr. Lines that could not be parsed are written back unchanged.
+// In strict mode any problem aborts the command instead.
+func (c *CLI) reportDiagnostics(hostsFile *hosts.HostsFile) error {
+	if len(hostsFile.Diagnostics) == 0 {
 		return nil
 	}
 
-	fmt.Printf("✗ Found %d issue(s):\n\n", len(issues))
-	for i, issue := range issues {
-		fmt.Printf("%d. %s\n", i+1, issue)
+	if c.strict {
+		return fmt.Errorf("hosts file has %d parse problem(s), first at %s", len(hostsFile.Diagnostics), hostsFile.Diagnostics[0])
 	}
 
-	return fmt.Errorf("hosts file has validation issues")
+	fmt.Fprintf(os.Stderr, "Warning: %d line(s) of %s have problems and will be left unchanged:\n", len(hostsFile.Diagnostics), c.hostsFile)
+	for _, diagnostic := range hostsFile.Diagnostics {
+		fmt.Fprintf(os.Stderr, "  %s\n", diagnostic)
+	}
+	return nil
 }
 
 func (c *CLI) printEntriesFiltered(entries []hosts.Entry, filters ListFilters) {
@@ -585,16 +993,117 @@ func (c *CLI) printEntriesFiltered(entries []hosts.Entry, filters ListFilters) {
 			status = "disabled"
 		}
 
-		hostnames := strings.Join(entry.Names, ", ")
-		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", entry.ID, status, entry.IP, hostnames, entry.Comment)
+		hostnames := c.displayNames(entry.Names)
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", entry.ID, status, entry.IP, hostnames, entry.FullComment())
 	}
 
 	_ = w.Flush()
 }
 
+// displayNames formats hostnames for human-readable output. Internationalized
+// names are shown in Unicode unless --punycode was given.
+func (c *CLI) displayNames(names []string) string {
+	if c.punycode {
+		return strings.Join(names, ", ")
+	}
+
+	display := make([]string, len(names))
+	for i, name := range names {
+		display[i] = pkg.ToUnicode(name)
+	}
+	return strings.Join(display, ", ")
+}
+
+// Annotations holds the structured annotations given on the command line.
+type Annotations struct {
+	Tags    []string
+	Owner   string
+	Expires string
+}
+
+// apply validates the annotations and stores them on the entry.
+func (a Annotations) apply(entry *hosts.Entry) error {
+	for _, tag := range a.Tags {
+		if tag == "" || strings.ContainsAny(tag, ", \t#") {
+			return fmt.Errorf("invalid tag: %q", tag)
+		}
+	}
+	entry.Tags = a.Tags
+
+	if strings.ContainsAny(a.Owner, " \t#") {
+		return fmt.Errorf("invalid owner: %q", a.Owner)
+	}
+	entry.Owner = a.Owner
+
+	if a.Expires != "" {
+		expires, err := time.ParseInLocation(hosts.ExpiresLayout, a.Expires, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid expiry date %q (expected YYYY-MM-DD)", a.Expires)
+		}
+		entry.Expires = expires
+	}
+
+	return nil
+}
+
+// requireManaged returns an error if the entry lives outside the hostsctl managed blocks.
+func requireManaged(entry *hosts.Entry) error {
+	if !entry.IsManaged() {
+		return fmt.Errorf("entry %d (%s) is not managed by hostsctl (run 'hostsctl adopt --id %d' first)",
+			entry.ID, strings.Join(entry.Names, ", "), entry.ID)
+	}
+	return nil
+}
+
+// findByName returns the entries with the given hostname, which may be given in
+// its Unicode form: it is converted like the hostnames of added entries.
+func findByName(hostsFile *hosts.HostsFile, name string) ([]*hosts.Entry, error) {
+	ascii, err := pkg.ToASCII(name)
+	if err != nil {
+		return nil, err
+	}
+	return hostsFile.FindByName(ascii), nil
+}
+
+// managedIDsByName returns the IDs of all entries with the given hostname,
+// failing if none exist or if any of them is not managed by hostsctl.
+func managedIDsByName(hostsFile *hosts.HostsFile, name string) ([]int, error) {
+	entries, err := findByName(hostsFile, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no entries found with hostname %s", name)
+	}
+
+	ids := make([]int, 0, len(entries))
+	for _, entry := range entries {
+		if err := requireManaged(entry); err != nil {
+			return nil, err
+		}
+		ids = append(ids, entry.ID)
+	}
+
+	return ids, nil
+}
+
+// isSystemEntry reports whether an entry is one of the loopback defaults
+// shipped by distributions, which adopt --all leaves alone.
+func isSystemEntry(entry hosts.Entry) bool {
+	for _, name := range entry.Names {
+		switch name {
+		case "localhost", "localhost.localdomain", "ip6-localhost", "ip6-loopback",
+			"ip6-localnet", "ip6-mcastprefix", "ip6-allnodes", "ip6-allrouters":
+			return true
+		}
+	}
+	return false
+}
+
 // applyListFilters applies all specified filters to the entries list.
 func (c *CLI) applyListFilters(entries []hosts.Entry, filters ListFilters) []hosts.Entry {
 	var filtered []hosts.Entry
+	now := time.Now()
 
 	for _, entry := range entries {
 		// Filter by disabled status
@@ -616,7 +1125,20 @@ func (c *CLI) applyListFilters(entries []hosts.Entry, filters ListFilters) []hos
 		}
 
 		// Filter by comment
-		if filters.CommentFilter != "" && !c.matchesPattern(entry.Comment, filters.CommentFilter) {
+		if filters.CommentFilter != "" && !c.matchesPattern(entry.FullComment(), filters.CommentFilter) {
+			continue
+		}
+
+		// Filter by annotations
+		if filters.TagFilter != "" && !entry.HasTag(filters.TagFilter) {
+			continue
+		}
+
+		if filters.OwnerFilter != "" && !strings.EqualFold(entry.Owner, filters.OwnerFilter) {
+			continue
+		}
+
+		if filters.ExpiredOnly && !entry.IsExpired(now) {
 			continue
 		}
 
@@ -624,7 +1146,7 @@ func (c *CLI) applyListFilters(entries []hosts.Entry, filters ListFilters) []hos
 		if filters.NameFilter != "" {
 			nameMatches := false
 			for _, name := range entry.Names {
-				if c.matchesPattern(name, filters.NameFilter) {
+				if c.matchesPattern(name, filters.NameFilter) || c.matchesPattern(pkg.ToUnicode(name), filters.NameFilter) {
 					nameMatches = true
 					break
 				}
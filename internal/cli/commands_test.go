@@ -1,10 +1,14 @@
 package cli
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/vaxvhbe/hostsctl/internal/hosts"
 )
@@ -191,7 +195,7 @@ func TestCLI_runListWithFilters(t *testing.T) {
 
 	// Test with empty filters
 	filters := ListFilters{ShowAll: true}
-	err = cli.runListWithFilters(filters)
+	err = cli.runListWithFilters(context.Background(), filters)
 	if err != nil {
 		t.Errorf("runListWithFilters() error = %v", err)
 	}
@@ -221,6 +225,7 @@ func TestCLI_buildCommands(t *testing.T) {
 		{"import", func() interface{} { return cli.buildImportCommand() }},
 		{"export", func() interface{} { return cli.buildExportCommand() }},
 		{"verify", func() interface{} { return cli.buildVerifyCommand() }},
+		{"adopt", func() interface{} { return cli.buildAdoptCommand() }},
 		{"profile", func() interface{} { return cli.buildProfileCommand() }},
 		{"search", func() interface{} { return cli.buildSearchCommand() }},
 		{"completion", func() interface{} { return cli.buildCompletionCommand() }},
@@ -274,7 +279,7 @@ func TestCLI_runVerify(t *testing.T) {
 			cli.hostsFile = hostsFile
 			cli.jsonOutput = true
 
-			err := cli.runVerify()
+			err := cli.runVerify(context.Background())
 			if (err != nil) != tt.shouldError {
 				t.Errorf("runVerify() error = %v, shouldError %v", err, tt.shouldError)
 			}
@@ -351,7 +356,7 @@ func TestCLI_runExport(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			exportFile := filepath.Join(tmpDir, "export."+tt.format)
-			err := cli.runExport(exportFile, tt.format)
+			err := cli.runExport(context.Background(), exportFile, tt.format)
 
 			if tt.valid && err != nil {
 				t.Errorf("runExport() error = %v", err)
@@ -381,3 +386,259 @@ func TestCLI_runExport(t *testing.T) {
 		})
 	}
 }
+
+func TestCLI_runAdopt(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	content := "127.0.0.1\tlocalhost\n192.168.1.100\tserver.local\t# Test server\n"
+
+	if err := os.WriteFile(hostsFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write hosts file: %v", err)
+	}
+
+	cli := NewCLI()
+	cli.hostsFile = hostsFile
+
+	// Unmanaged entries cannot be removed
+	if err := cli.runRemove(context.Background(), 0, "server.local"); err == nil {
+		t.Error("runRemove() should refuse to remove an unmanaged entry")
+	}
+
+	if err := cli.runAdopt(context.Background(), 0, "", false, hosts.DefaultBlock); err == nil {
+		t.Error("runAdopt() should require --id, --name or --all")
+	}
+
+	if err := cli.runAdopt(context.Background(), 0, "", true, hosts.DefaultBlock); err != nil {
+		t.Fatalf("runAdopt() error = %v", err)
+	}
+
+	data, err := os.ReadFile(hostsFile)
+	if err != nil {
+		t.Fatalf("Failed to read hosts file: %v", err)
+	}
+
+	want := "127.0.0.1\tlocalhost\n\n# BEGIN hostsctl\n192.168.1.100\tserver.local\t# Test server\n# END hostsctl\n"
+	if string(data) != want {
+		t.Errorf("hosts file after adopt = %q, want %q", string(data), want)
+	}
+
+	// Once adopted, the entry can be removed
+	if err := cli.runRemove(context.Background(), 0, "server.local"); err != nil {
+		t.Errorf("runRemove() error = %v", err)
+	}
+}
+
+func TestCLI_runRemoveUnicodeName(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	content := "127.0.0.1\tlocalhost\n"
+
+	if err := os.WriteFile(hostsFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write hosts file: %v", err)
+	}
+
+	cli := NewCLI()
+	cli.hostsFile = hostsFile
+
+	if err := cli.runAdd(context.Background(), "10.0.0.1", []string{"café.dev"}, "", Annotations{}); err != nil {
+		t.Fatalf("runAdd() error = %v", err)
+	}
+
+	// The decomposed spelling names the same entry
+	if err := cli.runDisable(context.Background(), 0, "cafe\u0301.dev"); err != nil {
+		t.Errorf("runDisable() error = %v", err)
+	}
+	if err := cli.runRemove(context.Background(), 0, "café.dev"); err != nil {
+		t.Fatalf("runRemove() error = %v", err)
+	}
+
+	data, err := os.ReadFile(hostsFile)
+	if err != nil {
+		t.Fatalf("Failed to read hosts file: %v", err)
+	}
+	if strings.Contains(string(data), "xn--caf-dma.dev") {
+		t.Errorf("Entry should be removed, got %q", string(data))
+	}
+}
+
+func TestCLI_runAddWithParseProblems(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	content := "127.0.0.1\tlocalhost\n999.1.1.1\tbroken.local\n"
+
+	if err := os.WriteFile(hostsFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write hosts file: %v", err)
+	}
+
+	cli := NewCLI()
+	cli.hostsFile = hostsFile
+	cli.strict = true
+
+	if err := cli.runAdd(context.Background(), "10.0.0.1", []string{"app.local"}, "", Annotations{}); err == nil {
+		t.Error("runAdd() should abort in strict mode when the hosts file has parse problems")
+	}
+
+	cli.strict = false
+	if err := cli.runAdd(context.Background(), "10.0.0.1", []string{"app.local"}, "", Annotations{}); err != nil {
+		t.Fatalf("runAdd() error = %v", err)
+	}
+
+	data, err := os.ReadFile(hostsFile)
+	if err != nil {
+		t.Fatalf("Failed to read hosts file: %v", err)
+	}
+
+	if !strings.Contains(string(data), "999.1.1.1\tbroken.local\n") {
+		t.Errorf("Unparsable line should be preserved, got %q", string(data))
+	}
+}
+
+func TestCLI_runAddDryRun(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	content := "127.0.0.1\tlocalhost\n"
+
+	if err := os.WriteFile(hostsFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write hosts file: %v", err)
+	}
+
+	cli := NewCLI()
+	cli.hostsFile = hostsFile
+	cli.dryRun = true
+
+	if err := cli.runAdd(context.Background(), "10.0.0.1", []string{"app.local"}, "", Annotations{}); err != nil {
+		t.Fatalf("runAdd() error = %v", err)
+	}
+	if err := cli.runRestore(context.Background(), hostsFile, ""); err != nil {
+		t.Fatalf("runRestore() error = %v", err)
+	}
+
+	data, err := os.ReadFile(hostsFile)
+	if err != nil {
+		t.Fatalf("Failed to read hosts file: %v", err)
+	}
+	if string(data) != content {
+		t.Errorf("Dry run should not modify the hosts file, got %q", string(data))
+	}
+
+	// Neither backups nor lock files may be created
+	files, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to read temp dir: %v", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("Dry run should only leave the hosts file, found %d files", len(files))
+	}
+}
+
+func TestCLI_runAddInternationalized(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	if err := os.WriteFile(hostsFile, []byte("127.0.0.1\tlocalhost\n"), 0644); err != nil {
+		t.Fatalf("Failed to write hosts file: %v", err)
+	}
+
+	cli := NewCLI()
+	cli.hostsFile = hostsFile
+
+	if err := cli.runAdd(context.Background(), "10.0.0.1", []string{"bücher.example"}, "", Annotations{}); err != nil {
+		t.Fatalf("runAdd() error = %v", err)
+	}
+
+	data, err := os.ReadFile(hostsFile)
+	if err != nil {
+		t.Fatalf("Failed to read hosts file: %v", err)
+	}
+	if !strings.Contains(string(data), "10.0.0.1\txn--bcher-kva.example") {
+		t.Errorf("Hostname should be stored in punycode, got %q", string(data))
+	}
+
+	names := []string{"xn--bcher-kva.example", "plain.local"}
+	if got := cli.displayNames(names); got != "bücher.example, plain.local" {
+		t.Errorf("displayNames() = %q, want Unicode form", got)
+	}
+
+	cli.punycode = true
+	if got := cli.displayNames(names); got != "xn--bcher-kva.example, plain.local" {
+		t.Errorf("displayNames() with --punycode = %q, want stored form", got)
+	}
+}
+
+func TestCLI_applyListFiltersAnnotations(t *testing.T) {
+	cli := NewCLI()
+
+	entries := []hosts.Entry{
+		{ID: 1, IP: "10.0.0.1", Names: []string{"api.local"}, Tags: []string{"dev", "payments"}, Owner: "alice"},
+		{ID: 2, IP: "10.0.0.2", Names: []string{"old.local"}, Tags: []string{"dev"}, Expires: time.Now().AddDate(0, 0, -2)},
+		{ID: 3, IP: "10.0.0.3", Names: []string{"new.local"}, Owner: "bob", Expires: time.Now().AddDate(0, 1, 0)},
+	}
+
+	tests := []struct {
+		name    string
+		filters ListFilters
+		wantIDs []int
+	}{
+		{"filter by tag", ListFilters{ShowAll: true, TagFilter: "dev"}, []int{1, 2}},
+		{"filter by owner", ListFilters{ShowAll: true, OwnerFilter: "Alice"}, []int{1}},
+		{"filter expired", ListFilters{ShowAll: true, ExpiredOnly: true}, []int{2}},
+		{"tag and owner", ListFilters{ShowAll: true, TagFilter: "payments", OwnerFilter: "bob"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cli.applyListFilters(entries, tt.filters)
+
+			var gotIDs []int
+			for _, entry := range got {
+				gotIDs = append(gotIDs, entry.ID)
+			}
+
+			if fmt.Sprint(gotIDs) != fmt.Sprint(tt.wantIDs) {
+				t.Errorf("applyListFilters() IDs = %v, want %v", gotIDs, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestAnnotations_apply(t *testing.T) {
+	entry := hosts.Entry{IP: "10.0.0.1", Names: []string{"api.local"}}
+
+	if err := (Annotations{Tags: []string{"dev"}, Owner: "alice", Expires: "2026-12-01"}).apply(&entry); err != nil {
+		t.Fatalf("apply() error = %v", err)
+	}
+	if entry.Annotations() != "@tags=dev @owner=alice @expires=2026-12-01" {
+		t.Errorf("Annotations() = %q", entry.Annotations())
+	}
+
+	if err := (Annotations{Expires: "12/01/2026"}).apply(&entry); err == nil {
+		t.Error("apply() should reject a malformed expiry date")
+	}
+	if err := (Annotations{Tags: []string{"a,b"}}).apply(&entry); err == nil {
+		t.Error("apply() should reject a tag containing a comma")
+	}
+}
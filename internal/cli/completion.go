@@ -197,6 +197,33 @@ func (c *CLI) setupCompletions(rootCmd *cobra.Command) {
 		registerFlagCompletion(profileCmd, "export", "format", formatCompletion)
 	}
 
+	// Setup backup ID completion for backup show/diff
+	backupCompletion := func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		backups, err := c.newStore(false).ListBackups()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		var ids []string
+		for _, backup := range backups {
+			ids = append(ids, backup.ID)
+		}
+
+		return ids, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	if backupCmd := findCommand(rootCmd, "backup"); backupCmd != nil {
+		if showCmd := findCommand(backupCmd, "show"); showCmd != nil {
+			showCmd.ValidArgsFunction = backupCompletion
+		}
+		if diffCmd := findCommand(backupCmd, "diff"); diffCmd != nil {
+			diffCmd.ValidArgsFunction = backupCompletion
+		}
+		registerFlagCompletion(backupCmd, "diff", "format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return []string{"text", "unified", "json"}, cobra.ShellCompDirectiveNoFileComp
+		})
+	}
+
 	// Setup status filter completion
 	statusCompletion := func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		return []string{"enabled", "disabled"}, cobra.ShellCompDirectiveNoFileComp
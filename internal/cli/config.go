@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/vaxvhbe/hostsctl/internal/config"
+	"github.com/vaxvhbe/hostsctl/internal/hosts"
+	"github.com/vaxvhbe/hostsctl/internal/lock"
+)
+
+// loadConfig applies the configuration file: the one given with --config, or
+// the default one if it exists. Command line flags take precedence over it.
+func (c *CLI) loadConfig(cmd *cobra.Command) error {
+	path := c.configPath
+	if path == "" {
+		path = config.DefaultPath()
+	}
+
+	cfg, err := config.Load(path)
+	if errors.Is(err, os.ErrNotExist) && c.configPath == "" {
+		cfg, err = &config.Config{}, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	mode, err := lock.ParseMode(cfg.Lock.Mode)
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	timeout := c.lockTimeout
+	if cfg.Lock.Timeout > 0 && !cmd.Flag("lock-timeout").Changed {
+		timeout = cfg.Lock.Timeout
+	}
+	if timeout < 0 {
+		return fmt.Errorf("--lock-timeout cannot be negative")
+	}
+
+	c.lockOptions = lock.Options{
+		Mode:    mode,
+		Dir:     cfg.Lock.Dir,
+		Timeout: timeout,
+	}
+
+	if c.linter, err = newLinter(cfg.Lint); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// newLinter creates the linter of the verify command, with the default rules
+// enabled, disabled or given another severity as configured.
+func newLinter(cfg config.LintConfig) (*hosts.Linter, error) {
+	linter := hosts.NewLinter()
+
+	ids := make([]string, 0, len(cfg.Rules))
+	for id := range cfg.Rules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		if _, ok := linter.Rule(id); !ok {
+			return nil, fmt.Errorf("unknown lint rule '%s'", id)
+		}
+
+		rule := cfg.Rules[id]
+		if rule.Enabled != nil {
+			if err := linter.Enable(id, *rule.Enabled); err != nil {
+				return nil, err
+			}
+		}
+		if rule.Severity != "" {
+			severity, err := hosts.ParseSeverity(rule.Severity)
+			if err != nil {
+				return nil, fmt.Errorf("lint rule %s: %w", id, err)
+			}
+			if err := linter.SetSeverity(id, severity); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return linter, nil
+}
+
+// lockOptionsFor returns how path is locked. Only the hosts file itself is
+// flocked with lock.ModeHostsFile: fragments may not exist yet, so they are
+// locked through a lock file instead.
+func (c *CLI) lockOptionsFor(path string) lock.Options {
+	options := c.lockOptions
+	if options.Mode == lock.ModeHostsFile && path != c.hostsFile {
+		options.Mode = lock.ModeSidecar
+	}
+	return options
+}
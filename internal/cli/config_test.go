@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vaxvhbe/hostsctl/internal/config"
+	"github.com/vaxvhbe/hostsctl/internal/hosts"
+	"github.com/vaxvhbe/hostsctl/internal/lock"
+)
+
+func TestCLI_loadConfig(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	configFile := filepath.Join(tmpDir, "config.yaml")
+	if err := os.WriteFile(configFile, []byte("lock:\n  mode: hosts-file\n  dir: /run/lock\n  timeout: 10s\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	// Building the command resets the fields bound to flags
+	cli := NewCLI()
+	cmd := cli.buildRootCommand()
+	cli.hostsFile = filepath.Join(tmpDir, "hosts")
+	cli.configPath = configFile
+
+	if err := cli.loadConfig(cmd); err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	want := lock.Options{Mode: lock.ModeHostsFile, Dir: "/run/lock", Timeout: 10 * time.Second}
+	if cli.lockOptions != want {
+		t.Errorf("lockOptions = %+v, want %+v", cli.lockOptions, want)
+	}
+
+	// Fragments are locked through a lock file in the configured directory
+	if got := cli.lockOptionsFor(filepath.Join(tmpDir, "fragment.hosts")).Mode; got != lock.ModeSidecar {
+		t.Errorf("lockOptionsFor(fragment).Mode = %q, want %q", got, lock.ModeSidecar)
+	}
+
+	// --lock-timeout takes precedence over the config file
+	if err := cmd.PersistentFlags().Set("lock-timeout", "2s"); err != nil {
+		t.Fatalf("Failed to set --lock-timeout: %v", err)
+	}
+	if err := cli.loadConfig(cmd); err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if cli.lockOptions.Timeout != 2*time.Second {
+		t.Errorf("lockOptions.Timeout = %v, want 2s", cli.lockOptions.Timeout)
+	}
+
+	cli.configPath = filepath.Join(tmpDir, "missing.yaml")
+	if err := cli.loadConfig(cmd); err == nil {
+		t.Error("loadConfig() should fail for a missing --config file")
+	}
+
+	if err := os.WriteFile(configFile, []byte("lock:\n  mode: flock\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	cli.configPath = configFile
+	if err := cli.loadConfig(cmd); err == nil {
+		t.Error("loadConfig() should fail for an unknown lock mode")
+	}
+}
+
+func TestNewLinter(t *testing.T) {
+	enabled := true
+	linter, err := newLinter(config.LintConfig{Rules: map[string]config.RuleConfig{
+		"duplicate-hostname": {Severity: "warning"},
+		"unmanaged-entry":    {Enabled: &enabled},
+	}})
+	if err != nil {
+		t.Fatalf("newLinter() error = %v", err)
+	}
+
+	if rule, _ := linter.Rule("duplicate-hostname"); rule.Severity != hosts.SeverityWarning || !rule.Enabled {
+		t.Errorf("Expected duplicate-hostname to be an enabled warning, got %+v", rule)
+	}
+	if rule, _ := linter.Rule("unmanaged-entry"); !rule.Enabled || rule.Severity != hosts.SeverityInfo {
+		t.Errorf("Expected unmanaged-entry to be enabled with its default severity, got %+v", rule)
+	}
+
+	invalid := []config.LintConfig{
+		{Rules: map[string]config.RuleConfig{"no-such-rule": {}}},
+		{Rules: map[string]config.RuleConfig{"invalid-ip": {Severity: "fatal"}}},
+	}
+	for _, cfg := range invalid {
+		if _, err := newLinter(cfg); err == nil {
+			t.Errorf("newLinter(%+v) should fail", cfg)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/vaxvhbe/hostsctl/internal/hosts"
+	"github.com/vaxvhbe/hostsctl/pkg"
+)
+
+// buildCompileCommand creates the compile command.
+func (c *CLI) buildCompileCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "compile",
+		Short: "Compile hosts.d fragments into the hosts file",
+		Long: `Assemble the fragment files of the fragment directory into the hosts file.
+
+Fragments are plain hosts files named "<name>.hosts" (for example
+/etc/hosts.d/10-base.hosts). They are compiled in lexical order, each into its
+own "# BEGIN hostsctl fragment:<name>" block; blocks of fragments that were
+deleted are removed. Lines outside these blocks are left untouched.
+
+Examples:
+  hostsctl add --fragment 50-dev --ip 10.0.0.5 --name api.dev   # Edit a fragment
+  hostsctl compile                                               # Rebuild /etc/hosts
+  hostsctl compile --fragment-dir ./hosts.d --hosts-file ./hosts`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.runCompile(cmd.Context())
+		},
+	}
+
+	return cmd
+}
+
+func (c *CLI) runCompile(ctx context.Context) error {
+	fragments := hosts.NewFragmentDir(c.fragmentDir, c.strict)
+
+	return c.withLock(ctx, c.hostsFile, func() error {
+		store := c.newStore(c.strict)
+
+		names, err := fragments.CompileContext(ctx, store)
+		if err != nil {
+			return fmt.Errorf("failed to compile fragments: %w", err)
+		}
+
+		if c.dryRun {
+			return c.reportPreview(store.Preview(), c.hostsFile)
+		}
+
+		if c.jsonOutput {
+			return json.NewEncoder(os.Stdout).Encode(map[string]interface{}{
+				"hosts_file":     c.hostsFile,
+				"fragments":      names,
+				"write_strategy": store.WriteStrategy(),
+			})
+		}
+
+		fmt.Printf("Compiled %d fragment(s) from %s into %s\n", len(names), c.fragmentDir, c.hostsFile)
+		return nil
+	})
+}
+
+// runFragmentAdd adds an entry to a fragment instead of the hosts file.
+func (c *CLI) runFragmentAdd(ctx context.Context, fragment, ip string, names []string, comment string, annotations Annotations) error {
+	entry, err := newEntry(ip, names, comment, annotations)
+	if err != nil {
+		return err
+	}
+	entry.Block = ""
+
+	return c.withFragment(ctx, fragment, func(fragmentFile *hosts.HostsFile) error {
+		fragmentFile.AddEntry(entry)
+		c.reportChange("Added entry to fragment %s: %s -> %s", fragment, entry.IP, c.displayNames(entry.Names))
+		return nil
+	})
+}
+
+// runFragmentRemove removes entries from a fragment instead of the hosts file.
+func (c *CLI) runFragmentRemove(ctx context.Context, fragment string, id int, name string) error {
+	if id == 0 && name == "" {
+		return fmt.Errorf("either --id or --name must be specified")
+	}
+
+	return c.withFragment(ctx, fragment, func(fragmentFile *hosts.HostsFile) error {
+		if id != 0 {
+			if !fragmentFile.RemoveEntry(id) {
+				return fmt.Errorf("entry with ID %d not found in fragment %s", id, fragment)
+			}
+			c.reportChange("Removed entry with ID %d from fragment %s", id, fragment)
+			return nil
+		}
+
+		ascii, err := pkg.ToASCII(name)
+		if err != nil {
+			return err
+		}
+
+		entries := fragmentFile.FindByName(ascii)
+		if len(entries) == 0 {
+			return fmt.Errorf("no entries found with hostname %s in fragment %s", name, fragment)
+		}
+
+		ids := make([]int, 0, len(entries))
+		for _, entry := range entries {
+			ids = append(ids, entry.ID)
+		}
+		for _, entryID := range ids {
+			fragmentFile.RemoveEntry(entryID)
+			c.reportChange("Removed entry with ID %d (%s) from fragment %s", entryID, name, fragment)
+		}
+		return nil
+	})
+}
+
+// withFragment loads a fragment under its lock, applies fn and saves the result.
+// The hosts file itself is not touched until the fragments are compiled.
+func (c *CLI) withFragment(ctx context.Context, fragment string, fn func(*hosts.HostsFile) error) error {
+	fragments := hosts.NewFragmentDir(c.fragmentDir, c.strict)
+
+	path, err := fragments.Path(fragment)
+	if err != nil {
+		return err
+	}
+
+	if !c.dryRun {
+		if err := os.MkdirAll(c.fragmentDir, 0755); err != nil {
+			return fmt.Errorf("failed to create fragment directory: %w", err)
+		}
+	}
+
+	return c.withLock(ctx, path, func() error {
+		fragmentFile, err := fragments.Load(fragment)
+		if err != nil {
+			return err
+		}
+
+		if err := c.reportDiagnostics(fragmentFile); err != nil {
+			return err
+		}
+
+		if err := fn(fragmentFile); err != nil {
+			return err
+		}
+
+		if c.dryRun {
+			preview, err := fragments.Preview(fragment, fragmentFile)
+			if err != nil {
+				return err
+			}
+			return c.reportPreview(preview, path)
+		}
+
+		if err := fragments.Save(fragment, fragmentFile); err != nil {
+			return err
+		}
+
+		for _, change := range c.changes {
+			fmt.Println(change)
+		}
+		fmt.Println("Run 'hostsctl compile' to apply the change to the hosts file")
+		return nil
+	})
+}
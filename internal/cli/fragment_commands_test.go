@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCLI_runFragmentAddAndCompile(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	if err := os.WriteFile(hostsFile, []byte("127.0.0.1\tlocalhost\n"), 0644); err != nil {
+		t.Fatalf("Failed to write hosts file: %v", err)
+	}
+
+	cli := NewCLI()
+	cli.hostsFile = hostsFile
+	cli.fragmentDir = filepath.Join(tmpDir, "hosts.d")
+
+	if err := cli.runFragmentAdd(context.Background(), "50-dev", "10.0.0.5", []string{"api.dev"}, "", Annotations{}); err != nil {
+		t.Fatalf("runFragmentAdd() error = %v", err)
+	}
+	if err := cli.runFragmentAdd(context.Background(), "50-dev", "10.0.0.6", []string{"web.dev"}, "", Annotations{}); err != nil {
+		t.Fatalf("runFragmentAdd() error = %v", err)
+	}
+
+	fragment, err := os.ReadFile(filepath.Join(cli.fragmentDir, "50-dev.hosts"))
+	if err != nil {
+		t.Fatalf("Failed to read fragment: %v", err)
+	}
+	if string(fragment) != "10.0.0.5\tapi.dev\n10.0.0.6\tweb.dev\n" {
+		t.Errorf("Fragment content = %q", string(fragment))
+	}
+
+	data, _ := os.ReadFile(hostsFile)
+	if strings.Contains(string(data), "api.dev") {
+		t.Error("Hosts file should not change before compile")
+	}
+
+	if err := cli.runFragmentRemove(context.Background(), "50-dev", 0, "web.dev"); err != nil {
+		t.Fatalf("runFragmentRemove() error = %v", err)
+	}
+
+	if err := cli.runCompile(context.Background()); err != nil {
+		t.Fatalf("runCompile() error = %v", err)
+	}
+
+	data, err = os.ReadFile(hostsFile)
+	if err != nil {
+		t.Fatalf("Failed to read hosts file: %v", err)
+	}
+	if !strings.Contains(string(data), "# BEGIN hostsctl fragment:50-dev\n10.0.0.5\tapi.dev\n# END hostsctl fragment:50-dev\n") {
+		t.Errorf("Compiled hosts file = %q", string(data))
+	}
+	if strings.Contains(string(data), "web.dev") {
+		t.Error("Removed fragment entry should not be compiled")
+	}
+}
@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vaxvhbe/hostsctl/internal/hosts"
+	"github.com/vaxvhbe/hostsctl/pkg"
+)
+
+// HistoryOptions contains the filters of the history command.
+type HistoryOptions struct {
+	User     string
+	Hostname string
+	Since    string
+	Until    string
+	Limit    int
+}
+
+// buildHistoryCommand creates the history command.
+func (c *CLI) buildHistoryCommand() *cobra.Command {
+	var options HistoryOptions
+
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show the audit log of changes to hosts files",
+		Long: `Show the audit log of changes made by hostsctl.
+
+Every change is appended to the audit log with the time, the real user (the
+user who ran sudo, if any), the command line, the entries that were added,
+removed or changed and the checksum of the resulting file.
+
+Dates are given as YYYY-MM-DD, as RFC 3339 timestamps, or as a duration
+relative to now such as 24h.
+
+Examples:
+  hostsctl history                           # Show all changes
+  hostsctl history --user alice --since 168h # Changes by alice in the last week
+  hostsctl history --hostname api.dev        # Changes to api.dev
+  hostsctl history --since 2025-01-01 --until 2025-01-31 --json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.runHistory(options)
+		},
+	}
+
+	cmd.Flags().StringVar(&options.User, "user", "", "Only show changes by this user")
+	cmd.Flags().StringVar(&options.Hostname, "hostname", "", "Only show changes to entries with this hostname")
+	cmd.Flags().StringVar(&options.Since, "since", "", "Only show changes at or after this date")
+	cmd.Flags().StringVar(&options.Until, "until", "", "Only show changes at or before this date")
+	cmd.Flags().IntVar(&options.Limit, "limit", 0, "Only show the most recent N changes (0 for all)")
+
+	return cmd
+}
+
+func (c *CLI) runHistory(options HistoryOptions) error {
+	filter := hosts.AuditFilter{User: options.User}
+
+	if options.Hostname != "" {
+		hostname, err := pkg.ToASCII(options.Hostname)
+		if err != nil {
+			return err
+		}
+		filter.Hostname = hostname
+	}
+
+	var err error
+	now := time.Now()
+	if filter.Since, err = parseHistoryTime(options.Since, now, false); err != nil {
+		return fmt.Errorf("invalid --since: %w", err)
+	}
+	if filter.Until, err = parseHistoryTime(options.Until, now, true); err != nil {
+		return fmt.Errorf("invalid --until: %w", err)
+	}
+
+	records, err := hosts.NewAuditLog(c.auditLog).Read(filter)
+	if err != nil {
+		return err
+	}
+
+	if options.Limit > 0 && len(records) > options.Limit {
+		records = records[len(records)-options.Limit:]
+	}
+
+	if c.jsonOutput {
+		if records == nil {
+			records = []hosts.AuditRecord{}
+		}
+		return json.NewEncoder(os.Stdout).Encode(records)
+	}
+
+	if len(records) == 0 {
+		fmt.Printf("No changes found in %s\n", c.auditLog)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "TIME\tUSER\tHOSTS FILE\tCHANGES\tCOMMAND")
+	_, _ = fmt.Fprintln(w, "----\t----\t----------\t-------\t-------")
+
+	for _, record := range records {
+		command := record.Command
+		if len(command) > 50 {
+			command = command[:47] + "..."
+		}
+
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t+%d -%d ~%d\t%s\n",
+			record.Time.Local().Format("2006-01-02 15:04:05"),
+			record.User,
+			record.HostsFile,
+			len(record.Added), len(record.Removed), len(record.Changed),
+			command)
+	}
+
+	_ = w.Flush()
+	return nil
+}
+
+// parseHistoryTime parses a date given to --since or --until: a date
+// (YYYY-MM-DD), an RFC 3339 timestamp, or a duration before now. A date
+// alone covers the whole day, so for --until (endOfDay) it means its last
+// instant. An empty value returns the zero time.
+func parseHistoryTime(value string, now time.Time, endOfDay bool) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		return t, nil
+	}
+
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+
+	return time.Time{}, fmt.Errorf("expected a date such as 2025-01-31, a timestamp or a duration such as 24h")
+}
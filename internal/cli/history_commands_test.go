@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseHistoryTime(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		value     string
+		endOfDay  bool
+		want      time.Time
+		wantError bool
+	}{
+		{"empty", "", false, time.Time{}, false},
+		{"timestamp", "2025-01-02T03:04:05Z", false, time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), false},
+		{"duration", "24h", false, now.Add(-24 * time.Hour), false},
+		{"date", "2025-01-31", false, time.Date(2025, 1, 31, 0, 0, 0, 0, time.Local), false},
+		{"date until", "2025-01-31", true, time.Date(2025, 2, 1, 0, 0, 0, 0, time.Local).Add(-time.Nanosecond), false},
+		{"invalid", "yesterday", false, time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseHistoryTime(tt.value, now, tt.endOfDay)
+			if (err != nil) != tt.wantError {
+				t.Fatalf("parseHistoryTime() error = %v, wantError %v", err, tt.wantError)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseHistoryTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/vaxvhbe/hostsctl/internal/hosts"
+)
+
+// buildUndoCommand creates the undo command.
+func (c *CLI) buildUndoCommand() *cobra.Command {
+	var steps int
+
+	cmd := &cobra.Command{
+		Use:   "undo",
+		Short: "Undo the last changes to the hosts file",
+		Long: `Undo the last changes made by hostsctl to the hosts file.
+
+Every change is recorded in an operation journal next to the backups, so
+undo and redo step back and forth through the last changes. Undo is refused
+if the hosts file was modified since the change, for example by an editor.
+
+Examples:
+  hostsctl undo                # Undo the last change
+  hostsctl undo --steps 3      # Undo the last three changes
+  hostsctl undo --dry-run      # Show what undo would change`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.runUndo(cmd.Context(), steps)
+		},
+	}
+
+	cmd.Flags().IntVarP(&steps, "steps", "n", 1, "Number of changes to undo")
+
+	return cmd
+}
+
+// buildRedoCommand creates the redo command.
+func (c *CLI) buildRedoCommand() *cobra.Command {
+	var steps int
+
+	cmd := &cobra.Command{
+		Use:   "redo",
+		Short: "Redo changes to the hosts file that were undone",
+		Long: `Apply again changes to the hosts file that were undone with 'hostsctl undo'.
+
+Undone changes are forgotten as soon as the hosts file is changed by another
+command. Redo is refused if the hosts file was modified since the undo.
+
+Examples:
+  hostsctl redo                # Redo the last undone change
+  hostsctl redo --steps 2      # Redo the last two undone changes`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.runRedo(cmd.Context(), steps)
+		},
+	}
+
+	cmd.Flags().IntVarP(&steps, "steps", "n", 1, "Number of changes to redo")
+
+	return cmd
+}
+
+func (c *CLI) runUndo(ctx context.Context, steps int) error {
+	return c.runJournalSteps(ctx, steps, "Undid", hosts.ErrNothingToUndo, (*hosts.Store).UndoContext)
+}
+
+func (c *CLI) runRedo(ctx context.Context, steps int) error {
+	return c.runJournalSteps(ctx, steps, "Redid", hosts.ErrNothingToRedo, (*hosts.Store).RedoContext)
+}
+
+// runJournalSteps applies step up to steps times under the hosts file lock.
+// Running out of operations after the first step is not an error.
+func (c *CLI) runJournalSteps(ctx context.Context, steps int, verb string, nothing error, step func(*hosts.Store, context.Context) (*hosts.Operation, error)) error {
+	if steps < 1 {
+		return fmt.Errorf("--steps must be at least 1")
+	}
+	if c.dryRun && steps > 1 {
+		return fmt.Errorf("--dry-run previews a single step and cannot be combined with --steps")
+	}
+
+	return c.withLock(ctx, c.hostsFile, func() error {
+		store := c.newStore(false)
+
+		for i := 0; i < steps; i++ {
+			// Stop between steps when interrupted; the steps already taken are kept
+			if i > 0 && ctx.Err() != nil {
+				break
+			}
+
+			op, err := step(store, ctx)
+			if errors.Is(err, nothing) && i > 0 {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			c.reportChange("%s '%s' from %s", verb, op.Name, op.Time.Format("2006-01-02 15:04:05"))
+		}
+
+		return c.reportSaved(store)
+	})
+}
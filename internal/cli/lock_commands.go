@@ -0,0 +1,170 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vaxvhbe/hostsctl/internal/lock"
+)
+
+// buildLockCommand creates the lock command and its subcommands.
+func (c *CLI) buildLockCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lock",
+		Short: "Inspect and break the lock of the hosts file",
+		Long: `Inspect and break the lock that hostsctl takes while changing the hosts file.
+
+The lock file records the PID, command line, user and start time of its
+holder. A lock whose holder is no longer running is stale and can be broken.`,
+	}
+
+	cmd.AddCommand(c.buildLockStatusCommand())
+	cmd.AddCommand(c.buildLockBreakCommand())
+
+	return cmd
+}
+
+// buildLockStatusCommand creates the lock status command.
+func (c *CLI) buildLockStatusCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show who holds the lock of the hosts file",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.runLockStatus()
+		},
+	}
+
+	return cmd
+}
+
+// buildLockBreakCommand creates the lock break command.
+func (c *CLI) buildLockBreakCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "break",
+		Short: "Remove a stale lock of the hosts file",
+		Long: `Remove the lock file of the hosts file, left behind by a command that is no
+longer running.
+
+The lock is not broken while the process recorded in it is still running.
+
+Examples:
+  hostsctl lock status
+  sudo hostsctl lock break`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.runLockBreak()
+		},
+	}
+
+	return cmd
+}
+
+func (c *CLI) runLockStatus() error {
+	status, err := c.lockOptions.Inspect(c.hostsFile)
+	if err != nil {
+		return err
+	}
+
+	if c.jsonOutput {
+		return json.NewEncoder(os.Stdout).Encode(status)
+	}
+
+	if status.Mode == lock.ModeNone {
+		fmt.Println("Locking is disabled (lock mode none)")
+		return nil
+	}
+
+	fmt.Printf("Lock file: %s (lock mode %s)\n", status.Path, status.Mode)
+	fmt.Printf("Status: %s\n", lockState(status))
+	if status.Holder != nil {
+		c.printHolder(status.Holder)
+	}
+	return nil
+}
+
+func (c *CLI) runLockBreak() error {
+	if c.dryRun {
+		status, err := c.lockOptions.Inspect(c.hostsFile)
+		if err != nil {
+			return err
+		}
+		if status.Exists {
+			if err := status.Breakable(); err != nil {
+				return err
+			}
+		}
+		return c.reportLockBreak(status)
+	}
+
+	status, err := c.lockOptions.Break(c.hostsFile)
+	if err != nil {
+		return err
+	}
+	return c.reportLockBreak(status)
+}
+
+// reportLockBreak prints the result of breaking the lock described by status.
+func (c *CLI) reportLockBreak(status *lock.Status) error {
+	if c.jsonOutput {
+		return json.NewEncoder(os.Stdout).Encode(map[string]interface{}{
+			"dry_run": c.dryRun,
+			"broken":  status.Exists,
+			"lock":    status,
+		})
+	}
+
+	if status.Mode == lock.ModeNone {
+		fmt.Println("Locking is disabled (lock mode none)")
+		return nil
+	}
+	if !status.Exists {
+		fmt.Printf("No lock file at %s\n", status.Path)
+		return nil
+	}
+
+	verb := "Removed"
+	if c.dryRun {
+		verb = "Would remove"
+	}
+	fmt.Printf("%s %s lock file %s\n", verb, lockState(status), status.Path)
+	if status.Holder != nil {
+		c.printHolder(status.Holder)
+	}
+	return nil
+}
+
+// printHolder prints the details of a lock holder.
+func (c *CLI) printHolder(holder *lock.Holder) {
+	running := "not running"
+	if holder.Alive() {
+		running = "running"
+	}
+
+	fmt.Printf("PID: %d (%s)\n", holder.PID, running)
+	if holder.Command != "" {
+		fmt.Printf("Command: %s\n", holder.Command)
+	}
+	if holder.User != "" {
+		fmt.Printf("User: %s\n", holder.User)
+	}
+	if !holder.Started.IsZero() {
+		fmt.Printf("Started: %s (%s ago)\n", holder.Started.Format(time.RFC3339), formatAge(time.Since(holder.Started)))
+	}
+}
+
+// lockState summarizes a lock status as "free", "held", "stale" or "unused"
+// (a lock file that nobody holds).
+func lockState(status *lock.Status) string {
+	switch {
+	case status.Held:
+		return "held"
+	case status.Stale:
+		return "stale"
+	case status.Exists && status.Mode == lock.ModeSidecar:
+		return "unused"
+	default:
+		return "free"
+	}
+}
@@ -0,0 +1,27 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/vaxvhbe/hostsctl/internal/lock"
+)
+
+func TestLockState(t *testing.T) {
+	tests := []struct {
+		status lock.Status
+		want   string
+	}{
+		{lock.Status{Mode: lock.ModeSidecar}, "free"},
+		{lock.Status{Mode: lock.ModeSidecar, Exists: true}, "unused"},
+		{lock.Status{Mode: lock.ModeSidecar, Exists: true, Held: true}, "held"},
+		{lock.Status{Mode: lock.ModeSidecar, Exists: true, Stale: true}, "stale"},
+		{lock.Status{Mode: lock.ModeHostsFile, Exists: true}, "free"},
+		{lock.Status{Mode: lock.ModeHostsFile, Exists: true, Held: true}, "held"},
+	}
+
+	for _, tt := range tests {
+		if got := lockState(&tt.status); got != tt.want {
+			t.Errorf("lockState(%+v) = %q, want %q", tt.status, got, tt.want)
+		}
+	}
+}
@@ -1,17 +1,19 @@
 package cli
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 	"text/tabwriter"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/vaxvhbe/hostsctl/internal/hosts"
-	"github.com/vaxvhbe/hostsctl/internal/lock"
 	"github.com/vaxvhbe/hostsctl/internal/profiles"
+	"github.com/vaxvhbe/hostsctl/pkg"
 )
 
 // buildProfileCommand creates the main profile command with subcommands.
@@ -78,14 +80,20 @@ func (c *CLI) buildProfileApplyCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "apply [profile-name]",
 		Short: "Apply a saved profile to hosts file",
-		Args:  cobra.ExactArgs(1),
+		Long: `Apply a saved profile to the hosts file.
+
+The profile's entries are written to a managed block named after the profile
+("# BEGIN hostsctl <name>" ... "# END hostsctl <name>"). Lines outside that
+block are never modified.`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return c.runProfileApply(args[0], merge, backup)
+			return c.runProfileApply(cmd.Context(), args[0], merge)
 		},
 	}
 
-	cmd.Flags().BoolVar(&merge, "merge", false, "Merge with existing entries instead of replacing")
+	cmd.Flags().BoolVar(&merge, "merge", false, "Merge with the entries already in the profile's block instead of replacing them")
 	cmd.Flags().BoolVar(&backup, "backup", true, "Create backup before applying")
+	_ = cmd.Flags().MarkDeprecated("backup", "a backup is always created before the hosts file is written")
 
 	return cmd
 }
@@ -129,7 +137,7 @@ func (c *CLI) buildProfileDiffCommand() *cobra.Command {
 		Short: "Compare profile with current hosts file",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return c.runProfileDiff(args[0])
+			return c.runProfileDiff(cmd.Context(), args[0])
 		},
 	}
 
@@ -253,7 +261,7 @@ func (c *CLI) runProfileSave(name, description string, overwrite bool) error {
 }
 
 // runProfileApply applies a saved profile to the hosts file.
-func (c *CLI) runProfileApply(name string, merge, backup bool) error {
+func (c *CLI) runProfileApply(ctx context.Context, name string, merge bool) error {
 	manager, err := profiles.NewManager()
 	if err != nil {
 		return fmt.Errorf("failed to initialize profile manager: %w", err)
@@ -264,46 +272,41 @@ func (c *CLI) runProfileApply(name string, merge, backup bool) error {
 		return fmt.Errorf("failed to load profile: %w", err)
 	}
 
-	return lock.WithQuickLock(c.hostsFile, func() error {
-		store := hosts.NewStore(c.hostsFile, false)
+	if hosts.IsReservedBlock(profile.Name) {
+		return fmt.Errorf("profile '%s' cannot be applied: its name is reserved for a hostsctl managed block", profile.Name)
+	}
 
-		var hostsFile *hosts.HostsFile
-		if merge {
-			// Load current hosts file and merge with profile
-			hostsFile, err = store.Load()
-			if err != nil {
-				return fmt.Errorf("failed to load current hosts file: %w", err)
-			}
+	return c.withLock(ctx, c.hostsFile, func() error {
+		store := c.newStore(c.strict)
 
-			// Add profile entries to current hosts
-			for _, entry := range profile.Entries {
-				hostsFile.AddEntry(entry)
-			}
-		} else {
-			// Replace entire hosts file with profile
-			hostsFile = &hosts.HostsFile{
-				Entries: profile.Entries,
-				Path:    c.hostsFile,
-			}
-		}
-
-		if backup {
-			if _, err := store.Backup(""); err != nil {
-				return fmt.Errorf("failed to create backup: %w", err)
+		err := c.updateHostsFile(ctx, store, func(hostsFile *hosts.HostsFile) error {
+			// Each profile owns its own named managed block
+			if merge {
+				for _, entry := range profile.Entries {
+					entry.Block = profile.Name
+					hostsFile.AddEntry(entry)
+				}
+			} else {
+				hostsFile.ReplaceBlock(profile.Name, profile.Entries)
 			}
+			return nil
+		})
+		if err != nil {
+			return err
 		}
 
-		if err := store.Save(hostsFile); err != nil {
-			return fmt.Errorf("failed to save hosts file: %w", err)
+		if c.dryRun {
+			return c.reportPreview(store.Preview(), c.hostsFile)
 		}
 
 		if c.jsonOutput {
 			result := map[string]interface{}{
-				"profile":    profile.Name,
-				"entries":    len(profile.Entries),
-				"merge":      merge,
-				"backup":     backup,
-				"applied_at": time.Now(),
+				"profile":        profile.Name,
+				"entries":        len(profile.Entries),
+				"merge":          merge,
+				"backup":         true,
+				"applied_at":     time.Now(),
+				"write_strategy": store.WriteStrategy(),
 			}
 			return json.NewEncoder(os.Stdout).Encode(result)
 		}
@@ -395,8 +398,8 @@ func (c *CLI) runProfileShow(name string) error {
 				status = "disabled"
 			}
 
-			hostnames := strings.Join(entry.Names, ", ")
-			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", status, entry.IP, hostnames, entry.Comment)
+			hostnames := c.displayNames(entry.Names)
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", status, entry.IP, hostnames, entry.FullComment())
 		}
 
 		_ = w.Flush()
@@ -406,7 +409,7 @@ func (c *CLI) runProfileShow(name string) error {
 }
 
 // runProfileDiff compares a profile with the current hosts file.
-func (c *CLI) runProfileDiff(name string) error {
+func (c *CLI) runProfileDiff(ctx context.Context, name string) error {
 	manager, err := profiles.NewManager()
 	if err != nil {
 		return fmt.Errorf("failed to initialize profile manager: %w", err)
@@ -417,8 +420,8 @@ func (c *CLI) runProfileDiff(name string) error {
 		return fmt.Errorf("failed to load profile: %w", err)
 	}
 
-	store := hosts.NewStore(c.hostsFile, false)
-	current, err := store.Load()
+	store := c.newStore(false)
+	current, err := c.loadShared(ctx, store)
 	if err != nil {
 		return fmt.Errorf("failed to load current hosts file: %w", err)
 	}
@@ -429,7 +432,7 @@ func (c *CLI) runProfileDiff(name string) error {
 		return json.NewEncoder(os.Stdout).Encode(diff)
 	}
 
-	c.printDiff(diff, profile.Name)
+	c.printDiff(diff, "current hosts file", fmt.Sprintf("profile '%s'", profile.Name))
 	return nil
 }
 
@@ -547,32 +550,40 @@ func (c *CLI) calculateDiff(current, profile []hosts.Entry) *DiffResult {
 		}
 	}
 
+	// Map iteration order is random: sort for stable output
+	byKey := func(a, b hosts.Entry) int { return strings.Compare(c.entryKey(a), c.entryKey(b)) }
+	slices.SortFunc(result.Added, byKey)
+	slices.SortFunc(result.Removed, byKey)
+	slices.SortFunc(result.Same, byKey)
+	slices.SortFunc(result.Modified, func(a, b DiffEntry) int { return byKey(a.Old, b.Old) })
+
 	return result
 }
 
 // entryKey generates a unique key for a hosts entry for comparison.
 func (c *CLI) entryKey(entry hosts.Entry) string {
-	return fmt.Sprintf("%s:%s", entry.IP, strings.Join(entry.Names, ","))
+	return fmt.Sprintf("%s:%s", pkg.NormalizeIP(entry.IP), strings.Join(entry.Names, ","))
 }
 
 // entriesEqual compares two hosts entries for equality.
 func (c *CLI) entriesEqual(a, b hosts.Entry) bool {
-	return a.IP == b.IP &&
+	return pkg.SameIP(a.IP, b.IP) &&
 		strings.Join(a.Names, ",") == strings.Join(b.Names, ",") &&
-		a.Comment == b.Comment &&
+		a.FullComment() == b.FullComment() &&
 		a.Disabled == b.Disabled
 }
 
-// printDiff prints a human-readable diff report.
-func (c *CLI) printDiff(diff *DiffResult, profileName string) {
-	fmt.Printf("Comparing current hosts file with profile '%s':\n\n", profileName)
+// printDiff prints a human-readable report of the changes from one set of
+// entries to another, labelled from and to.
+func (c *CLI) printDiff(diff *DiffResult, from, to string) {
+	fmt.Printf("Comparing %s with %s:\n\n", from, to)
 
 	if len(diff.Added) > 0 {
 		fmt.Printf("Added entries (%d):\n", len(diff.Added))
 		for _, entry := range diff.Added {
 			fmt.Printf("  + %s %s", entry.IP, strings.Join(entry.Names, " "))
-			if entry.Comment != "" {
-				fmt.Printf(" # %s", entry.Comment)
+			if entry.FullComment() != "" {
+				fmt.Printf(" # %s", entry.FullComment())
 			}
 			fmt.Println()
 		}
@@ -583,8 +594,8 @@ func (c *CLI) printDiff(diff *DiffResult, profileName string) {
 		fmt.Printf("Removed entries (%d):\n", len(diff.Removed))
 		for _, entry := range diff.Removed {
 			fmt.Printf("  - %s %s", entry.IP, strings.Join(entry.Names, " "))
-			if entry.Comment != "" {
-				fmt.Printf(" # %s", entry.Comment)
+			if entry.FullComment() != "" {
+				fmt.Printf(" # %s", entry.FullComment())
 			}
 			fmt.Println()
 		}
@@ -595,13 +606,13 @@ func (c *CLI) printDiff(diff *DiffResult, profileName string) {
 		fmt.Printf("Modified entries (%d):\n", len(diff.Modified))
 		for _, mod := range diff.Modified {
 			fmt.Printf("  ~ %s %s", mod.Old.IP, strings.Join(mod.Old.Names, " "))
-			if mod.Old.Comment != "" {
-				fmt.Printf(" # %s", mod.Old.Comment)
+			if mod.Old.FullComment() != "" {
+				fmt.Printf(" # %s", mod.Old.FullComment())
 			}
 			fmt.Println()
 			fmt.Printf("    %s %s", mod.New.IP, strings.Join(mod.New.Names, " "))
-			if mod.New.Comment != "" {
-				fmt.Printf(" # %s", mod.New.Comment)
+			if mod.New.FullComment() != "" {
+				fmt.Printf(" # %s", mod.New.FullComment())
 			}
 			fmt.Println()
 		}
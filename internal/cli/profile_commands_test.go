@@ -82,6 +82,10 @@ func TestCLI_entryKey(t *testing.T) {
 			entry:    hosts.Entry{IP: "10.0.0.1", Names: []string{"single"}},
 			expected: "10.0.0.1:single",
 		},
+		{
+			entry:    hosts.Entry{IP: "0:0:0:0:0:0:0:1", Names: []string{"localhost"}},
+			expected: "::1:localhost",
+		},
 	}
 
 	for _, tt := range tests {
@@ -139,6 +143,12 @@ func TestCLI_entriesEqual(t *testing.T) {
 			b:    hosts.Entry{IP: "127.0.0.1", Names: []string{"b", "a"}, Comment: "Local", Disabled: false},
 			want: false,
 		},
+		{
+			name: "equivalent IPv6 spellings",
+			a:    hosts.Entry{IP: "::1", Names: []string{"localhost"}},
+			b:    hosts.Entry{IP: "0:0:0:0:0:0:0:1", Names: []string{"localhost"}},
+			want: true,
+		},
 		{
 			name: "empty entries",
 			a:    hosts.Entry{},
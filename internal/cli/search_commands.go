@@ -1,15 +1,18 @@
 package cli
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"regexp"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/vaxvhbe/hostsctl/internal/hosts"
+	"github.com/vaxvhbe/hostsctl/pkg"
 )
 
 // SearchOptions contains options for searching hosts entries.
@@ -21,6 +24,9 @@ type SearchOptions struct {
 	SearchNames     bool   // Search in hostnames
 	SearchComments  bool   // Search in comments
 	IncludeDisabled bool   // Include disabled entries in results
+	Tag             string // Only entries carrying this tag
+	Owner           string // Only entries with this owner
+	ExpiredOnly     bool   // Only entries whose expiry date has passed
 }
 
 // SearchResult represents a search result with match information.
@@ -47,11 +53,12 @@ Examples:
   hostsctl search "*.dev" --glob   # Glob pattern
   hostsctl search "^192\.168"      # Regex pattern
   hostsctl search "test" --ip      # Search only IP addresses
-  hostsctl search "app" --comment  # Search only comments`,
+  hostsctl search "app" --comment  # Search only comments
+  hostsctl search "api" --tag dev  # Search entries tagged "dev"`,
 		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			options.Pattern = args[0]
-			return c.runSearch(options)
+			return c.runSearch(cmd.Context(), options)
 		},
 	}
 
@@ -61,6 +68,9 @@ Examples:
 	cmd.Flags().BoolVar(&options.SearchNames, "names", false, "Search only hostnames")
 	cmd.Flags().BoolVar(&options.SearchComments, "comments", false, "Search only comments")
 	cmd.Flags().BoolVar(&options.IncludeDisabled, "include-disabled", false, "Include disabled entries")
+	cmd.Flags().StringVar(&options.Tag, "tag", "", "Only search entries carrying this tag")
+	cmd.Flags().StringVar(&options.Owner, "owner", "", "Only search entries with this owner")
+	cmd.Flags().BoolVar(&options.ExpiredOnly, "expired", false, "Only search expired entries")
 
 	// Add aliases for common flags
 	cmd.Flags().BoolP("case-insensitive", "i", false, "Case-insensitive search (alias for --ignore-case)")
@@ -70,7 +80,7 @@ Examples:
 }
 
 // runSearch executes the search command.
-func (c *CLI) runSearch(options SearchOptions) error {
+func (c *CLI) runSearch(ctx context.Context, options SearchOptions) error {
 
 	// Default to searching all fields if none specified
 	if !options.SearchIP && !options.SearchNames && !options.SearchComments {
@@ -79,8 +89,8 @@ func (c *CLI) runSearch(options SearchOptions) error {
 		options.SearchComments = true
 	}
 
-	store := hosts.NewStore(c.hostsFile, false)
-	hostsFile, err := store.Load()
+	store := c.newStore(false)
+	hostsFile, err := c.loadShared(ctx, store)
 	if err != nil {
 		return fmt.Errorf("failed to load hosts file: %w", err)
 	}
@@ -135,11 +145,24 @@ func (c *CLI) searchEntries(entries []hosts.Entry, options SearchOptions) ([]Sea
 	}
 
 	// Search through entries
+	now := time.Now()
 	for _, entry := range entries {
 		if !options.IncludeDisabled && entry.Disabled {
 			continue
 		}
 
+		if options.Tag != "" && !entry.HasTag(options.Tag) {
+			continue
+		}
+
+		if options.Owner != "" && !strings.EqualFold(entry.Owner, options.Owner) {
+			continue
+		}
+
+		if options.ExpiredOnly && !entry.IsExpired(now) {
+			continue
+		}
+
 		// Search IP address
 		if options.SearchIP && matcher(entry.IP) {
 			results = append(results, SearchResult{
@@ -153,7 +176,7 @@ func (c *CLI) searchEntries(entries []hosts.Entry, options SearchOptions) ([]Sea
 		// Search hostnames
 		if options.SearchNames {
 			for _, name := range entry.Names {
-				if matcher(name) {
+				if matcher(name) || matcher(pkg.ToUnicode(name)) {
 					results = append(results, SearchResult{
 						Entry:     entry,
 						MatchType: "hostname",
@@ -165,7 +188,7 @@ func (c *CLI) searchEntries(entries []hosts.Entry, options SearchOptions) ([]Sea
 		}
 
 		// Search comments (only if not already matched)
-		if options.SearchComments && entry.Comment != "" {
+		if options.SearchComments && entry.FullComment() != "" {
 			// Check if this entry was already added
 			alreadyAdded := false
 			for _, result := range results {
@@ -175,11 +198,11 @@ func (c *CLI) searchEntries(entries []hosts.Entry, options SearchOptions) ([]Sea
 				}
 			}
 
-			if !alreadyAdded && matcher(entry.Comment) {
+			if !alreadyAdded && matcher(entry.FullComment()) {
 				results = append(results, SearchResult{
 					Entry:     entry,
 					MatchType: "comment",
-					MatchText: entry.Comment,
+					MatchText: entry.FullComment(),
 				})
 			}
 		}
@@ -207,11 +230,11 @@ func (c *CLI) printSearchResults(results []SearchResult, options SearchOptions)
 			status = "disabled"
 		}
 
-		hostnames := strings.Join(result.Entry.Names, ", ")
+		hostnames := c.displayNames(result.Entry.Names)
 		match := fmt.Sprintf("%s: %s", result.MatchType, result.MatchText)
 
 		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n",
-			result.Entry.ID, status, result.Entry.IP, hostnames, result.Entry.Comment, match)
+			result.Entry.ID, status, result.Entry.IP, hostnames, result.Entry.FullComment(), match)
 	}
 
 	_ = w.Flush()
@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
@@ -231,7 +232,7 @@ func TestCLI_runSearch(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := cli.runSearch(tt.options)
+			err := cli.runSearch(context.Background(), tt.options)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("runSearch() error = %v, wantErr %v", err, tt.wantErr)
 			}
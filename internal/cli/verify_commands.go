@@ -0,0 +1,218 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/vaxvhbe/hostsctl/internal/hosts"
+)
+
+// severityOrder ranks severities from the most to the least serious.
+var severityOrder = []hosts.Severity{hosts.SeverityError, hosts.SeverityWarning, hosts.SeverityInfo}
+
+// findingGroup is the findings of one lint rule.
+type findingGroup struct {
+	Rule     string
+	Severity hosts.Severity
+	Findings []hosts.Finding
+}
+
+// buildVerifyCommand creates the verify command.
+func (c *CLI) buildVerifyCommand() *cobra.Command {
+	var listRules bool
+
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify hosts file syntax and check for issues",
+		Long: `Check the hosts file against a set of lint rules and report the findings,
+grouped by rule, with their line numbers and suggested fixes.
+
+Every rule has an ID and a severity (error, warning or info). The command
+fails only if a rule with severity error reports a finding. Rules can be
+enabled, disabled or given another severity in the configuration file:
+
+  lint:
+    rules:
+      duplicate-hostname:
+        severity: warning
+      unmanaged-entry:
+        enabled: true
+
+Examples:
+  hostsctl verify
+  hostsctl verify --list-rules   # Show the rules and their settings
+  hostsctl verify --json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if listRules {
+				return c.runVerifyRules()
+			}
+			return c.runVerify(cmd.Context())
+		},
+	}
+
+	cmd.Flags().BoolVar(&listRules, "list-rules", false, "List the lint rules instead of checking the hosts file")
+
+	return cmd
+}
+
+func (c *CLI) runVerify(ctx context.Context) error {
+	store := c.newStore(false)
+
+	var findings []hosts.Finding
+	err := c.withReadLock(ctx, c.hostsFile, func() error {
+		var err error
+		findings, err = store.Lint(c.linter)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to verify hosts file: %w", err)
+	}
+
+	counts := countSeverities(findings)
+	valid := counts[hosts.SeverityError] == 0
+
+	if c.jsonOutput {
+		issues := make([]string, len(findings))
+		for i, finding := range findings {
+			issues[i] = finding.String()
+		}
+		if findings == nil {
+			findings = []hosts.Finding{}
+		}
+
+		result := map[string]interface{}{
+			"valid":    valid,
+			"issues":   issues,
+			"findings": findings,
+			"summary":  counts,
+		}
+		if err := json.NewEncoder(os.Stdout).Encode(result); err != nil {
+			return err
+		}
+		if !valid {
+			return fmt.Errorf("hosts file has validation issues")
+		}
+		return nil
+	}
+
+	switch {
+	case len(findings) == 0:
+		fmt.Println("✓ Hosts file is valid")
+		return nil
+	case valid:
+		fmt.Printf("✓ Hosts file is valid (%s)\n", summarizeSeverities(counts))
+	default:
+		fmt.Printf("✗ Found %d issue(s) (%s)\n", len(findings), summarizeSeverities(counts))
+	}
+
+	for _, group := range groupFindings(findings) {
+		fmt.Printf("\n%s (%s)\n", group.Rule, group.Severity)
+		for _, finding := range group.Findings {
+			location := finding.Location()
+			if location != "" {
+				location += ": "
+			}
+			fmt.Printf("  %s%s\n", location, finding.Message)
+			if finding.Fix != "" {
+				fmt.Printf("    fix: %s\n", finding.Fix)
+			}
+		}
+	}
+
+	if !valid {
+		return fmt.Errorf("hosts file has validation issues")
+	}
+	return nil
+}
+
+// runVerifyRules lists the lint rules with their current settings.
+func (c *CLI) runVerifyRules() error {
+	rules := c.linter.Rules()
+
+	if c.jsonOutput {
+		return json.NewEncoder(os.Stdout).Encode(rules)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "RULE\tSEVERITY\tENABLED\tDESCRIPTION")
+	_, _ = fmt.Fprintln(w, "----\t--------\t-------\t-----------")
+
+	for _, rule := range rules {
+		enabled := "yes"
+		if !rule.Enabled {
+			enabled = "no"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", rule.ID, rule.Severity, enabled, rule.Description)
+	}
+
+	return w.Flush()
+}
+
+// groupFindings groups findings by rule, the most serious rules first. The
+// findings of a rule keep their order by line.
+func groupFindings(findings []hosts.Finding) []findingGroup {
+	var groups []findingGroup
+	index := make(map[string]int)
+	for _, finding := range findings {
+		i, ok := index[finding.Rule]
+		if !ok {
+			i = len(groups)
+			index[finding.Rule] = i
+			groups = append(groups, findingGroup{Rule: finding.Rule, Severity: finding.Severity})
+		}
+		groups[i].Findings = append(groups[i].Findings, finding)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		a, b := severityRank(groups[i].Severity), severityRank(groups[j].Severity)
+		if a != b {
+			return a < b
+		}
+		return groups[i].Rule < groups[j].Rule
+	})
+	return groups
+}
+
+// severityRank returns the position of severity in severityOrder.
+func severityRank(severity hosts.Severity) int {
+	for i, s := range severityOrder {
+		if s == severity {
+			return i
+		}
+	}
+	return len(severityOrder)
+}
+
+// countSeverities counts the findings of each severity.
+func countSeverities(findings []hosts.Finding) map[hosts.Severity]int {
+	counts := make(map[hosts.Severity]int, len(severityOrder))
+	for _, severity := range severityOrder {
+		counts[severity] = 0
+	}
+	for _, finding := range findings {
+		counts[finding.Severity]++
+	}
+	return counts
+}
+
+// summarizeSeverities describes finding counts, e.g. "1 error(s), 2 warning(s)".
+func summarizeSeverities(counts map[hosts.Severity]int) string {
+	var parts []string
+	for _, severity := range severityOrder {
+		if counts[severity] == 0 {
+			continue
+		}
+		label := string(severity) + "(s)"
+		if severity == hosts.SeverityInfo {
+			label = string(severity)
+		}
+		parts = append(parts, fmt.Sprintf("%d %s", counts[severity], label))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/vaxvhbe/hostsctl/internal/hosts"
+)
+
+func TestGroupFindings(t *testing.T) {
+	findings := []hosts.Finding{
+		{Rule: "expired-entry", Severity: hosts.SeverityWarning, Line: 1},
+		{Rule: "invalid-hostname", Severity: hosts.SeverityError, Line: 2},
+		{Rule: "expired-entry", Severity: hosts.SeverityWarning, Line: 3},
+		{Rule: "duplicate-hostname", Severity: hosts.SeverityError, Line: 4},
+		{Rule: "unmanaged-entry", Severity: hosts.SeverityInfo, Line: 5},
+	}
+
+	groups := groupFindings(findings)
+
+	want := []struct {
+		rule  string
+		lines []int
+	}{
+		{"duplicate-hostname", []int{4}},
+		{"invalid-hostname", []int{2}},
+		{"expired-entry", []int{1, 3}},
+		{"unmanaged-entry", []int{5}},
+	}
+	if len(groups) != len(want) {
+		t.Fatalf("groupFindings() returned %d groups, want %d", len(groups), len(want))
+	}
+	for i, w := range want {
+		if groups[i].Rule != w.rule || len(groups[i].Findings) != len(w.lines) {
+			t.Errorf("Group %d = %s with %d finding(s), want %s with %d", i, groups[i].Rule, len(groups[i].Findings), w.rule, len(w.lines))
+			continue
+		}
+		for j, line := range w.lines {
+			if groups[i].Findings[j].Line != line {
+				t.Errorf("Group %s finding %d is on line %d, want %d", w.rule, j, groups[i].Findings[j].Line, line)
+			}
+		}
+	}
+
+	if got := summarizeSeverities(countSeverities(findings)); got != "2 error(s), 2 warning(s), 1 info" {
+		t.Errorf("summarizeSeverities() = %q", got)
+	}
+}
@@ -0,0 +1,93 @@
+// Package config loads the hostsctl configuration file.
+//
+// The configuration file is YAML. Settings given on the command line take
+// precedence over it.
+//
+//	lock:
+//	  mode: sidecar        # sidecar, hosts-file or none
+//	  dir: /run/lock       # Directory of the lock file (default: next to the hosts file)
+//	  timeout: 10s         # How long to wait for the lock
+//	lint:
+//	  rules:
+//	    duplicate-hostname:
+//	      severity: warning  # error, warning or info
+//	    unmanaged-entry:
+//	      enabled: true
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Config holds the settings of the configuration file.
+type Config struct {
+	Lock LockConfig `yaml:"lock"`
+	Lint LintConfig `yaml:"lint"`
+}
+
+// LockConfig configures how the hosts file is locked.
+type LockConfig struct {
+	Mode    string        `yaml:"mode"`    // Which file is flocked: sidecar, hosts-file or none
+	Dir     string        `yaml:"dir"`     // Directory of sidecar lock files ("" for next to the hosts file)
+	Timeout time.Duration `yaml:"timeout"` // How long to wait for the lock (0 for the default)
+}
+
+// LintConfig configures the rules checked by the verify command.
+type LintConfig struct {
+	Rules map[string]RuleConfig `yaml:"rules"` // Settings by rule ID
+}
+
+// RuleConfig overrides the defaults of a lint rule.
+type RuleConfig struct {
+	Enabled  *bool  `yaml:"enabled"`  // Whether the rule runs (nil for its default)
+	Severity string `yaml:"severity"` // Severity of its findings: error, warning or info ("" for its default)
+}
+
+// DefaultPath returns where the configuration file is looked for by default:
+// /etc/hostsctl/config.yaml when running as root, otherwise the user's
+// configuration directory ($XDG_CONFIG_HOME or ~/.config).
+func DefaultPath() string {
+	if os.Geteuid() == 0 {
+		return "/etc/hostsctl/config.yaml"
+	}
+
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		configDir = filepath.Join(homeDir, ".config")
+	}
+	return filepath.Join(configDir, "hostsctl", "config.yaml")
+}
+
+// Load reads the configuration file at path. Unknown settings are rejected so
+// that typos do not go unnoticed. A missing file is reported with an error
+// matching os.ErrNotExist.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- configuration file path
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	config := &Config{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	if config.Lock.Timeout < 0 {
+		return nil, fmt.Errorf("config file %s: lock timeout cannot be negative", path)
+	}
+	return config, nil
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-config-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	path := filepath.Join(tmpDir, "config.yaml")
+
+	if _, err := Load(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Load() of a missing file error = %v, want os.ErrNotExist", err)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    LockConfig
+		wantErr bool
+	}{
+		{"empty", "", LockConfig{}, false},
+		{"lock", "lock:\n  mode: hosts-file\n  dir: /run/lock\n  timeout: 10s\n", LockConfig{Mode: "hosts-file", Dir: "/run/lock", Timeout: 10 * time.Second}, false},
+		{"unknown setting", "lock:\n  mdoe: none\n", LockConfig{}, true},
+		{"negative timeout", "lock:\n  timeout: -1s\n", LockConfig{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write config file: %v", err)
+			}
+
+			config, err := Load(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && config.Lock != tt.want {
+				t.Errorf("Load() = %+v, want %+v", config.Lock, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad_Lint(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-config-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	path := filepath.Join(tmpDir, "config.yaml")
+	content := "lint:\n  rules:\n    duplicate-hostname:\n      severity: warning\n    unmanaged-entry:\n      enabled: true\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	config, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	duplicate := config.Lint.Rules["duplicate-hostname"]
+	if duplicate.Severity != "warning" || duplicate.Enabled != nil {
+		t.Errorf("Expected only the severity of duplicate-hostname to be set, got %+v", duplicate)
+	}
+	unmanaged := config.Lint.Rules["unmanaged-entry"]
+	if unmanaged.Enabled == nil || !*unmanaged.Enabled || unmanaged.Severity != "" {
+		t.Errorf("Expected unmanaged-entry to be enabled, got %+v", unmanaged)
+	}
+}
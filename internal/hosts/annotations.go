@@ -0,0 +1,109 @@
+package hosts
+
+import (
+	"strings"
+	"time"
+)
+
+// ExpiresLayout is the date format used by the @expires annotation.
+const ExpiresLayout = "2006-01-02"
+
+// Annotation keys recognized inside entry comments.
+const (
+	annotationTags    = "tags"
+	annotationOwner   = "owner"
+	annotationExpires = "expires"
+)
+
+// parseAnnotations splits a trailing entry comment into its free text and the
+// structured "@key=value" annotations it contains, for example:
+//
+//	api stub @tags=dev,payments @owner=alice @expires=2026-12-01
+//
+// Unknown keys and malformed values are left in the free text so that no
+// information is lost when the entry is written back.
+func parseAnnotations(comment string, entry *Entry) string {
+	if !strings.Contains(comment, "@") {
+		return comment
+	}
+
+	var text []string
+	for _, word := range strings.Fields(comment) {
+		key, value, ok := strings.Cut(strings.TrimPrefix(word, "@"), "=")
+		if !strings.HasPrefix(word, "@") || !ok || value == "" {
+			text = append(text, word)
+			continue
+		}
+
+		switch key {
+		case annotationTags:
+			for _, tag := range strings.Split(value, ",") {
+				if tag != "" {
+					entry.Tags = append(entry.Tags, tag)
+				}
+			}
+		case annotationOwner:
+			entry.Owner = value
+		case annotationExpires:
+			expires, err := time.ParseInLocation(ExpiresLayout, value, time.Local)
+			if err != nil {
+				text = append(text, word)
+				continue
+			}
+			entry.Expires = expires
+		default:
+			text = append(text, word)
+		}
+	}
+
+	return strings.Join(text, " ")
+}
+
+// Annotations returns the "@key=value" annotations of the entry as they are
+// written in the hosts file, or an empty string if the entry has none.
+func (e *Entry) Annotations() string {
+	var parts []string
+	if len(e.Tags) > 0 {
+		parts = append(parts, "@"+annotationTags+"="+strings.Join(e.Tags, ","))
+	}
+	if e.Owner != "" {
+		parts = append(parts, "@"+annotationOwner+"="+e.Owner)
+	}
+	if !e.Expires.IsZero() {
+		parts = append(parts, "@"+annotationExpires+"="+e.Expires.Format(ExpiresLayout))
+	}
+	return strings.Join(parts, " ")
+}
+
+// FullComment returns the comment followed by the entry's annotations,
+// exactly as it appears after the "#" in the hosts file.
+func (e *Entry) FullComment() string {
+	annotations := e.Annotations()
+	switch {
+	case annotations == "":
+		return e.Comment
+	case e.Comment == "":
+		return annotations
+	default:
+		return e.Comment + " " + annotations
+	}
+}
+
+// HasTag reports whether the entry carries the given tag (case-insensitive).
+func (e *Entry) HasTag(tag string) bool {
+	for _, t := range e.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsExpired reports whether the entry's expiry date has passed at the given time.
+// An entry stays valid for the whole day named by its @expires annotation.
+func (e *Entry) IsExpired(now time.Time) bool {
+	if e.Expires.IsZero() {
+		return false
+	}
+	return !now.Before(e.Expires.AddDate(0, 0, 1))
+}
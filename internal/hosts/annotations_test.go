@@ -0,0 +1,124 @@
+package hosts
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParser_ParseAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		wantComment string
+		wantTags    []string
+		wantOwner   string
+		wantExpires string
+	}{
+		{
+			name:        "all annotations",
+			input:       "10.0.0.1\tapi.local\t# api stub @tags=dev,payments @owner=alice @expires=2026-12-01",
+			wantComment: "api stub",
+			wantTags:    []string{"dev", "payments"},
+			wantOwner:   "alice",
+			wantExpires: "2026-12-01",
+		},
+		{
+			name:        "annotations only",
+			input:       "10.0.0.1\tapi.local\t# @owner=bob",
+			wantComment: "",
+			wantOwner:   "bob",
+		},
+		{
+			name:        "plain comment",
+			input:       "10.0.0.1\tapi.local\t# contact alice@example.com",
+			wantComment: "contact alice@example.com",
+		},
+		{
+			name:        "unknown key and bad date kept in comment",
+			input:       "10.0.0.1\tapi.local\t# stub @team=core @expires=soon",
+			wantComment: "stub @team=core @expires=soon",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := NewParser(false)
+			hostsFile, err := parser.Parse(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("Parser.Parse() error = %v", err)
+			}
+
+			entry := hostsFile.Entries[0]
+			if entry.Comment != tt.wantComment {
+				t.Errorf("Comment = %q, want %q", entry.Comment, tt.wantComment)
+			}
+			if strings.Join(entry.Tags, ",") != strings.Join(tt.wantTags, ",") {
+				t.Errorf("Tags = %v, want %v", entry.Tags, tt.wantTags)
+			}
+			if entry.Owner != tt.wantOwner {
+				t.Errorf("Owner = %q, want %q", entry.Owner, tt.wantOwner)
+			}
+
+			gotExpires := ""
+			if !entry.Expires.IsZero() {
+				gotExpires = entry.Expires.Format(ExpiresLayout)
+			}
+			if gotExpires != tt.wantExpires {
+				t.Errorf("Expires = %q, want %q", gotExpires, tt.wantExpires)
+			}
+		})
+	}
+}
+
+func TestEntry_StringWithAnnotations(t *testing.T) {
+	expires, _ := time.ParseInLocation(ExpiresLayout, "2026-12-01", time.Local)
+	entry := Entry{
+		IP:      "10.0.0.1",
+		Names:   []string{"api.local"},
+		Comment: "api stub",
+		Tags:    []string{"dev", "payments"},
+		Owner:   "alice",
+		Expires: expires,
+	}
+
+	want := "10.0.0.1\tapi.local\t# api stub @tags=dev,payments @owner=alice @expires=2026-12-01"
+	if got := entry.String(); got != want {
+		t.Errorf("Entry.String() = %q, want %q", got, want)
+	}
+
+	// Annotations survive a parse/serialize round trip
+	hostsFile, err := NewParser(false).Parse(strings.NewReader(want))
+	if err != nil {
+		t.Fatalf("Parser.Parse() error = %v", err)
+	}
+	if got := hostsFile.Entries[0].String(); got != want {
+		t.Errorf("Round trip = %q, want %q", got, want)
+	}
+}
+
+func TestEntry_HasTag(t *testing.T) {
+	entry := Entry{Tags: []string{"dev", "Payments"}}
+
+	if !entry.HasTag("payments") {
+		t.Error("HasTag should match case-insensitively")
+	}
+	if entry.HasTag("prod") {
+		t.Error("HasTag should return false for a missing tag")
+	}
+}
+
+func TestEntry_IsExpired(t *testing.T) {
+	expires := time.Date(2026, 12, 1, 0, 0, 0, 0, time.Local)
+	entry := Entry{Expires: expires}
+
+	if entry.IsExpired(time.Date(2026, 12, 1, 23, 0, 0, 0, time.Local)) {
+		t.Error("Entry should still be valid on its expiry date")
+	}
+	if !entry.IsExpired(time.Date(2026, 12, 2, 0, 0, 0, 0, time.Local)) {
+		t.Error("Entry should be expired the day after its expiry date")
+	}
+	if (&Entry{}).IsExpired(time.Now()) {
+		t.Error("Entry without expiry date should never expire")
+	}
+}
@@ -0,0 +1,315 @@
+package hosts
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/vaxvhbe/hostsctl/pkg"
+)
+
+// WriteStrategy describes how Store.Save wrote the hosts file to disk.
+type WriteStrategy string
+
+const (
+	// WriteAtomic writes a temporary file and renames it over the hosts file.
+	WriteAtomic WriteStrategy = "atomic"
+	// WriteSymlinkTarget atomically replaces the file a symlinked hosts file
+	// points to, leaving the symlink itself in place.
+	WriteSymlinkTarget WriteStrategy = "symlink-target"
+	// WriteInPlace truncates and rewrites the hosts file under an exclusive
+	// lock. It is used when the file cannot be renamed over, as with the
+	// bind-mounted /etc/hosts of Docker and Kubernetes containers, or when
+	// the caller locks the hosts file itself (see Store.SetHostsFileLocked),
+	// and only after the backup of the current content has been verified.
+	WriteInPlace WriteStrategy = "in-place"
+)
+
+// flockRetryDelay is how often flockFile tries to take a busy flock again.
+const flockRetryDelay = 100 * time.Millisecond
+
+// newFileMode is the mode of files created by replaceFile when there is no
+// original file to copy it from.
+const newFileMode = 0644
+
+// write stores content in the hosts file using the safest strategy that works
+// for it. backup is the snapshot of the current content taken by Save; it may
+// be nil if the hosts file does not exist yet. Nothing is written if ctx is
+// canceled before the hosts file is replaced.
+func (s *Store) write(ctx context.Context, content string, backup *BackupInfo) (WriteStrategy, error) {
+	target, strategy, err := resolveTarget(s.path)
+	if err != nil {
+		return "", err
+	}
+
+	// A flock on the hosts file only holds on its inode: renaming a new file
+	// over it would let other tools lock the new inode while this change is
+	// still in progress.
+	if s.hostsFileLocked {
+		return s.rewriteInPlace(ctx, target, content, backup)
+	}
+
+	err = replaceFile(ctx, target, content)
+	if err == nil {
+		return strategy, nil
+	}
+	if !isRenameBlocked(err) {
+		return "", err
+	}
+
+	// The file is a mount point (or on another device than its directory):
+	// fall back to rewriting it in place.
+	return s.rewriteInPlace(ctx, target, content, backup)
+}
+
+// rewriteInPlace writes content to target with writeInPlace, but only with a
+// usable backup of the current content.
+func (s *Store) rewriteInPlace(ctx context.Context, target, content string, backup *BackupInfo) (WriteStrategy, error) {
+	if err := s.verifyBackup(target, backup); err != nil {
+		return "", fmt.Errorf("cannot rewrite %s in place: %w", target, err)
+	}
+	if err := interrupted(ctx); err != nil {
+		return "", err
+	}
+	if err := writeInPlace(ctx, target, content, !s.hostsFileLocked, s.lockTimeout); err != nil {
+		return "", err
+	}
+	return WriteInPlace, nil
+}
+
+// resolveTarget returns the file that must be written to update path: path
+// itself, or the file it points to if path is a symlink.
+func resolveTarget(path string) (string, WriteStrategy, error) {
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return path, WriteAtomic, nil
+	}
+
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to resolve symlink %s: %w", path, err)
+	}
+	return target, WriteSymlinkTarget, nil
+}
+
+// isRenameBlocked reports whether replaceFile failed because the target
+// cannot be renamed over, as happens with bind mounts.
+func isRenameBlocked(err error) bool {
+	return errors.Is(err, syscall.EBUSY) || errors.Is(err, syscall.EXDEV)
+}
+
+// verifyBackup checks that backup can be read back and holds exactly the
+// current content of path, so that an interrupted in-place write can be
+// recovered from.
+func (s *Store) verifyBackup(path string, backup *BackupInfo) error {
+	if backup == nil {
+		return fmt.Errorf("no backup of the current content")
+	}
+
+	current, err := os.ReadFile(path) // #nosec G304 -- hosts file path
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	saved, err := s.ReadBackup(backup.Path)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(current, saved) {
+		return fmt.Errorf("backup %s does not match the current content", backup.Path)
+	}
+	return nil
+}
+
+// writeInPlace overwrites the file at path with content without replacing
+// the file itself. An exclusive flock on the file, taken here if flock is set
+// or else already held by the caller, keeps cooperating readers from seeing a
+// partial write, and the result is read back to verify it. Taking the flock
+// gives up after timeout, or as soon as ctx is canceled.
+func writeInPlace(ctx context.Context, path, content string, flock bool, timeout time.Duration) error {
+	file, err := os.OpenFile(path, os.O_RDWR, 0) // #nosec G304 -- hosts file path
+	if err != nil {
+		return fmt.Errorf("failed to open %s for writing: %w", path, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	if flock {
+		if err := flockFile(ctx, file, timeout); err != nil {
+			return err
+		}
+		defer func() { _ = syscall.Flock(int(file.Fd()), syscall.LOCK_UN) }()
+	}
+
+	if _, err := file.WriteAt([]byte(content), 0); err != nil {
+		return fmt.Errorf("failed to write %s in place: %w", path, err)
+	}
+	if err := file.Truncate(int64(len(content))); err != nil {
+		return fmt.Errorf("failed to truncate %s: %w", path, err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync %s: %w", path, err)
+	}
+
+	written := make([]byte, len(content)+1)
+	n, err := file.ReadAt(written, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to verify %s: %w", path, err)
+	}
+	if string(written[:n]) != content {
+		return fmt.Errorf("failed to verify %s: content differs after writing", path)
+	}
+	return nil
+}
+
+// flockFile takes an exclusive flock on file, polling until another process
+// holding it lets go, timeout elapses or ctx is canceled.
+func flockFile(ctx context.Context, file *os.File, timeout time.Duration) error {
+	deadline, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			return fmt.Errorf("failed to lock %s: %w", file.Name(), err)
+		}
+
+		select {
+		case <-deadline.Done():
+			if err := interrupted(ctx); err != nil {
+				return err
+			}
+			return fmt.Errorf("timeout waiting for lock on %s, which is held by another process", file.Name())
+		case <-time.After(flockRetryDelay):
+		}
+	}
+}
+
+// replaceFile atomically replaces the file at path with content. The content
+// is written to a uniquely named temporary file in the same directory, which
+// receives the mode, ownership and extended attributes (such as SELinux
+// labels) of the original, is fsynced and then renamed over the original.
+// The directory is fsynced afterwards so that the rename survives a crash.
+// If ctx is canceled before the rename, the temporary file is removed and
+// the original is left untouched.
+func replaceFile(ctx context.Context, path, content string) error {
+	// Validate file path to prevent directory traversal
+	if err := pkg.ValidateSecurePath(path); err != nil {
+		return fmt.Errorf("invalid path: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+	tempFile, err := os.CreateTemp(dir, tempPattern(path))
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tempPath := tempFile.Name()
+
+	if err := writeTemp(tempFile, path, content); err != nil {
+		_ = tempFile.Close()
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	if err := tempFile.Close(); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	if err := interrupted(ctx); err != nil {
+		_ = os.Remove(tempPath)
+		return err
+	}
+
+	if err := os.Rename(tempPath, path); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to atomically replace %s: %w", path, err)
+	}
+
+	if err := syncDir(dir); err != nil {
+		return fmt.Errorf("failed to sync directory %s: %w", dir, err)
+	}
+
+	return nil
+}
+
+// tempPattern returns the pattern of the names of the temporary files that
+// replaceFile creates to replace path, "*" standing for a random string.
+func tempPattern(path string) string {
+	return "." + filepath.Base(path) + ".hostsctl-*.tmp"
+}
+
+// writeTemp writes content to the temporary file, gives it the metadata of
+// the original file (if it exists) and fsyncs it for durability.
+func writeTemp(tempFile *os.File, original, content string) error {
+	if _, err := tempFile.WriteString(content); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(original)
+	switch {
+	case os.IsNotExist(err):
+		if err := tempFile.Chmod(newFileMode); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	default:
+		if err := copyMetadata(original, info, tempFile); err != nil {
+			return err
+		}
+	}
+
+	return tempFile.Sync()
+}
+
+// copyMetadata copies the permission bits, owner, group and extended
+// attributes of the original file onto dst.
+func copyMetadata(original string, info os.FileInfo, dst *os.File) error {
+	if err := dst.Chmod(info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to copy file mode: %w", err)
+	}
+
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		// Only root can give a file away; other users keep their own
+		// ownership, exactly as if they had edited the file in place.
+		if err := dst.Chown(int(stat.Uid), int(stat.Gid)); err != nil && os.Geteuid() == 0 {
+			return fmt.Errorf("failed to copy file ownership: %w", err)
+		}
+	}
+
+	if err := copyXattrs(original, dst.Name()); err != nil {
+		return fmt.Errorf("failed to copy extended attributes: %w", err)
+	}
+
+	// Chown may clear the setuid/setgid bits, so apply the mode once more.
+	return dst.Chmod(info.Mode().Perm())
+}
+
+// syncDir fsyncs a directory so that renames inside it are durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir) // #nosec G304 -- directory of a validated path
+	if err != nil {
+		return err
+	}
+	defer func() { _ = d.Close() }()
+
+	if err := d.Sync(); err != nil && !isUnsupportedSync(err) {
+		return err
+	}
+	return nil
+}
+
+// isUnsupportedSync reports whether fsync failed because the file system does
+// not support syncing directories.
+func isUnsupportedSync(err error) bool {
+	return errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.ENOTSUP)
+}
@@ -0,0 +1,332 @@
+package hosts
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestReplaceFile_PreservesMode(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-atomic-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	if err := os.WriteFile(hostsFile, []byte("127.0.0.1\tlocalhost\n"), 0600); err != nil {
+		t.Fatalf("Failed to create hosts file: %v", err)
+	}
+	if err := os.Chmod(hostsFile, 0640); err != nil {
+		t.Fatalf("Failed to chmod hosts file: %v", err)
+	}
+
+	if err := replaceFile(context.Background(), hostsFile, "127.0.0.1\tlocalhost\n10.0.0.1\tapi.local\n"); err != nil {
+		t.Fatalf("replaceFile() error = %v", err)
+	}
+
+	info, err := os.Stat(hostsFile)
+	if err != nil {
+		t.Fatalf("Failed to stat hosts file: %v", err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("Expected mode 0640 to be preserved, got %o", info.Mode().Perm())
+	}
+
+	assertFileContent(t, hostsFile, "127.0.0.1\tlocalhost\n10.0.0.1\tapi.local\n")
+
+	// No temporary files may be left behind
+	files, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to read temp dir: %v", err)
+	}
+	for _, file := range files {
+		if file.Name() != "hosts" {
+			t.Errorf("Unexpected file left behind: %s", file.Name())
+		}
+	}
+}
+
+func TestReplaceFile_NewFileMode(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-atomic-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	path := filepath.Join(tmpDir, "new.hosts")
+	if err := replaceFile(context.Background(), path, "10.0.0.1\tapi.local\n"); err != nil {
+		t.Fatalf("replaceFile() error = %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+	if info.Mode().Perm() != newFileMode {
+		t.Errorf("Expected mode %o for a new file, got %o", newFileMode, info.Mode().Perm())
+	}
+}
+
+func TestReplaceFile_Canceled(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-atomic-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	if err := os.WriteFile(hostsFile, []byte("127.0.0.1\tlocalhost\n"), 0644); err != nil {
+		t.Fatalf("Failed to create hosts file: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = replaceFile(ctx, hostsFile, "10.0.0.1\tapi.local\n")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("replaceFile() error = %v, want context.Canceled", err)
+	}
+
+	assertFileContent(t, hostsFile, "127.0.0.1\tlocalhost\n")
+
+	files, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to read temp dir: %v", err)
+	}
+	for _, file := range files {
+		if file.Name() != "hosts" {
+			t.Errorf("Unexpected file left behind: %s", file.Name())
+		}
+	}
+}
+
+func TestStore_SaveContext_Canceled(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-atomic-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	if err := os.WriteFile(hostsFile, []byte("127.0.0.1\tlocalhost\n"), 0644); err != nil {
+		t.Fatalf("Failed to create hosts file: %v", err)
+	}
+
+	store := NewStore(hostsFile, false)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	err = store.UpdateContext(ctx, func(hostsFile *HostsFile) error {
+		cancel()
+		hostsFile.AddEntry(Entry{IP: "10.0.0.1", Names: []string{"api.local"}})
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("UpdateContext() error = %v, want context.Canceled", err)
+	}
+
+	assertFileContent(t, hostsFile, "127.0.0.1\tlocalhost\n")
+
+	files, err := store.TempFiles()
+	if err != nil {
+		t.Fatalf("TempFiles() error = %v", err)
+	}
+	if len(files) != 0 {
+		t.Errorf("Expected no temporary files to be left behind, got %v", files)
+	}
+}
+
+func TestStore_SaveThroughSymlink(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-atomic-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	target := filepath.Join(tmpDir, "hosts.real")
+	if err := os.WriteFile(target, []byte("127.0.0.1\tlocalhost\n"), 0644); err != nil {
+		t.Fatalf("Failed to create hosts file: %v", err)
+	}
+	link := filepath.Join(tmpDir, "hosts")
+	if err := os.Symlink("hosts.real", link); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	store := NewStore(link, false)
+	hostsFile, err := store.Load()
+	if err != nil {
+		t.Fatalf("Store.Load() error = %v", err)
+	}
+	hostsFile.AddEntry(Entry{IP: "10.0.0.1", Names: []string{"api.local"}, Block: DefaultBlock})
+
+	if err := store.Save(hostsFile); err != nil {
+		t.Fatalf("Store.Save() error = %v", err)
+	}
+
+	if store.WriteStrategy() != WriteSymlinkTarget {
+		t.Errorf("Expected strategy %q, got %q", WriteSymlinkTarget, store.WriteStrategy())
+	}
+
+	info, err := os.Lstat(link)
+	if err != nil {
+		t.Fatalf("Failed to stat symlink: %v", err)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		t.Fatal("Hosts file symlink was replaced by a regular file")
+	}
+
+	data, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatalf("Failed to read symlink target: %v", err)
+	}
+	if !strings.Contains(string(data), "10.0.0.1\tapi.local") {
+		t.Errorf("Symlink target was not updated: %q", string(data))
+	}
+}
+
+func TestWriteInPlace(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-atomic-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	if err := os.WriteFile(hostsFile, []byte("127.0.0.1\tlocalhost\n10.0.0.1\tlonger.name.local\n"), 0644); err != nil {
+		t.Fatalf("Failed to create hosts file: %v", err)
+	}
+	before, err := os.Stat(hostsFile)
+	if err != nil {
+		t.Fatalf("Failed to stat hosts file: %v", err)
+	}
+
+	if err := writeInPlace(context.Background(), hostsFile, "127.0.0.1\tlocalhost\n", true, time.Second); err != nil {
+		t.Fatalf("writeInPlace() error = %v", err)
+	}
+
+	assertFileContent(t, hostsFile, "127.0.0.1\tlocalhost\n")
+
+	after, err := os.Stat(hostsFile)
+	if err != nil {
+		t.Fatalf("Failed to stat hosts file: %v", err)
+	}
+	if !os.SameFile(before, after) {
+		t.Error("writeInPlace() should keep the same file")
+	}
+}
+
+func TestWriteInPlace_BusyFlock(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-atomic-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	if err := os.WriteFile(hostsFile, []byte("127.0.0.1\tlocalhost\n"), 0644); err != nil {
+		t.Fatalf("Failed to create hosts file: %v", err)
+	}
+
+	// Another program holds the flock through its own open file description
+	holder, err := os.Open(hostsFile)
+	if err != nil {
+		t.Fatalf("Failed to open hosts file: %v", err)
+	}
+	defer func() { _ = holder.Close() }()
+	if err := syscall.Flock(int(holder.Fd()), syscall.LOCK_EX); err != nil {
+		t.Fatalf("Failed to lock hosts file: %v", err)
+	}
+
+	err = writeInPlace(context.Background(), hostsFile, "10.0.0.1\thost.local\n", true, 200*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), hostsFile) {
+		t.Errorf("writeInPlace() error = %v, want a timeout naming %s", err, hostsFile)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = writeInPlace(ctx, hostsFile, "10.0.0.1\thost.local\n", true, time.Minute)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("writeInPlace() error = %v, want context.Canceled", err)
+	}
+
+	assertFileContent(t, hostsFile, "127.0.0.1\tlocalhost\n")
+}
+
+func TestStore_SaveWithHostsFileLocked(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-atomic-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	if err := os.WriteFile(hostsFile, []byte("127.0.0.1\tlocalhost\n"), 0644); err != nil {
+		t.Fatalf("Failed to create hosts file: %v", err)
+	}
+	before, err := os.Stat(hostsFile)
+	if err != nil {
+		t.Fatalf("Failed to stat hosts file: %v", err)
+	}
+
+	// The caller's flock is on this inode, so it must not be replaced
+	store := NewStore(hostsFile, false)
+	store.SetHostsFileLocked(true)
+	err = store.Update(func(hostsData *HostsFile) error {
+		hostsData.AddEntry(Entry{IP: "10.0.0.1", Names: []string{"api.local"}, Block: DefaultBlock})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Store.Update() error = %v", err)
+	}
+
+	if store.WriteStrategy() != WriteInPlace {
+		t.Errorf("Expected strategy %q, got %q", WriteInPlace, store.WriteStrategy())
+	}
+
+	after, err := os.Stat(hostsFile)
+	if err != nil {
+		t.Fatalf("Failed to stat hosts file: %v", err)
+	}
+	if !os.SameFile(before, after) {
+		t.Error("Save() should keep the locked file")
+	}
+}
+
+func TestStore_VerifyBackup(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-atomic-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	if err := os.WriteFile(hostsFile, []byte("127.0.0.1\tlocalhost\n"), 0644); err != nil {
+		t.Fatalf("Failed to create hosts file: %v", err)
+	}
+
+	store := NewStore(hostsFile, false)
+	backup, err := store.createBackup()
+	if err != nil {
+		t.Fatalf("createBackup() error = %v", err)
+	}
+
+	if err := store.verifyBackup(hostsFile, backup); err != nil {
+		t.Errorf("verifyBackup() error = %v", err)
+	}
+
+	if err := store.verifyBackup(hostsFile, nil); err == nil {
+		t.Error("verifyBackup() should fail without a backup")
+	}
+
+	if err := os.WriteFile(hostsFile, []byte("10.0.0.1\tchanged.local\n"), 0644); err != nil {
+		t.Fatalf("Failed to modify hosts file: %v", err)
+	}
+	if err := store.verifyBackup(hostsFile, backup); err == nil {
+		t.Error("verifyBackup() should fail when the backup does not match the current content")
+	}
+}
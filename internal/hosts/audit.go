@@ -0,0 +1,243 @@
+package hosts
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AuditRecord is one line of the audit log: a change written to a hosts file.
+type AuditRecord struct {
+	Time      time.Time `json:"time"`              // When the change was written
+	User      string    `json:"user"`              // Real user, from SUDO_USER when run through sudo
+	UID       int       `json:"uid"`               // Real user ID, from SUDO_UID when run through sudo
+	EUID      int       `json:"euid"`              // Effective user ID the change was written with
+	Command   string    `json:"command"`           // Command line that made the change
+	HostsFile string    `json:"hosts_file"`        // Path of the changed hosts file
+	Added     []Entry   `json:"added,omitempty"`   // Entries that were added
+	Removed   []Entry   `json:"removed,omitempty"` // Entries that were removed
+	Changed   []Entry   `json:"changed,omitempty"` // Entries whose line changed, as written
+	SHA256    string    `json:"sha256"`            // Checksum of the resulting file
+}
+
+// AuditFilter selects audit records. Zero fields match everything.
+type AuditFilter struct {
+	User     string    // Real user name
+	Hostname string    // Hostname of an added, removed or changed entry
+	Since    time.Time // Earliest time, inclusive
+	Until    time.Time // Latest time, inclusive
+}
+
+// AuditLog is an append-only log of changes in JSON lines format.
+type AuditLog struct {
+	path string
+}
+
+// NewAuditLog returns the audit log stored at path.
+func NewAuditLog(path string) *AuditLog {
+	return &AuditLog{path: path}
+}
+
+// Path returns the path of the audit log file.
+func (l *AuditLog) Path() string {
+	return l.path
+}
+
+// DefaultAuditLogPath returns where the audit log is kept by default:
+// /var/log/hostsctl when running as root, otherwise the user's state
+// directory ($XDG_STATE_HOME or ~/.local/state).
+func DefaultAuditLogPath() string {
+	if os.Geteuid() == 0 {
+		return "/var/log/hostsctl/audit.log"
+	}
+
+	stateDir := os.Getenv("XDG_STATE_HOME")
+	if stateDir == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return filepath.Join(os.TempDir(), "hostsctl", "audit.log")
+		}
+		stateDir = filepath.Join(homeDir, ".local", "state")
+	}
+	return filepath.Join(stateDir, "hostsctl", "audit.log")
+}
+
+// open opens the audit log for appending, creating it if needed.
+func (l *AuditLog) open() (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0750); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640) // #nosec G304 -- configured log path
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return file, nil
+}
+
+// append writes record as one line to the opened audit log.
+func (l *AuditLog) append(file *os.File, record AuditRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit record: %w", err)
+	}
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return file.Sync()
+}
+
+// Read returns the records of the audit log that match filter, oldest first.
+// A missing log has no records.
+func (l *AuditLog) Read(filter AuditFilter) ([]AuditRecord, error) {
+	file, err := os.Open(l.path) // #nosec G304 -- configured log path
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var records []AuditRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+
+		var record AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("audit log %s line %d: %w", l.path, lineNum, err)
+		}
+		if filter.Matches(record) {
+			records = append(records, record)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	return records, nil
+}
+
+// Matches reports whether record is selected by the filter.
+func (f AuditFilter) Matches(record AuditRecord) bool {
+	if f.User != "" && record.User != f.User {
+		return false
+	}
+	if !f.Since.IsZero() && record.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && record.Time.After(f.Until) {
+		return false
+	}
+	if f.Hostname == "" {
+		return true
+	}
+
+	for _, entries := range [][]Entry{record.Added, record.Removed, record.Changed} {
+		for _, entry := range entries {
+			for _, name := range entry.Names {
+				if strings.EqualFold(name, f.Hostname) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// SetAuditLog makes every change written by the store append a record to
+// log. A nil log disables auditing.
+func (s *Store) SetAuditLog(log *AuditLog) {
+	s.auditLog = log
+}
+
+// auditRecord describes the change from the previous entries of the hosts
+// file to hostsFile, written with the given checksum.
+func (s *Store) auditRecord(previous []Entry, hostsFile *HostsFile, sha string) AuditRecord {
+	name, uid := realUser()
+	record := AuditRecord{
+		Time:      time.Now(),
+		User:      name,
+		UID:       uid,
+		EUID:      os.Geteuid(),
+		Command:   s.command,
+		HostsFile: s.path,
+		SHA256:    sha,
+	}
+
+	record.Added, record.Removed, record.Changed = s.diffEntries(previous, hostsFile.Entries)
+	return record
+}
+
+// previousEntries returns the entries of the content saved in backup: those
+// remembered from the last Load or Save if it read or wrote that content, or
+// else those parsed from the backup.
+func (s *Store) previousEntries(backup *BackupInfo) []Entry {
+	if s.loaded != nil && s.fingerprint != nil && s.fingerprint.SHA256 == backup.SHA256 {
+		return s.loaded
+	}
+	if old, err := s.LoadBackup(backup.Path); err == nil {
+		return old.Entries
+	}
+	return nil
+}
+
+// diffEntries compares two versions of the entries of a hosts file by ID.
+// An entry is changed when it keeps its ID but its line differs, for example
+// because it was disabled or adopted into a block.
+func (s *Store) diffEntries(previous, current []Entry) (added, removed, changed []Entry) {
+	before := make(map[int]*Entry, len(previous))
+	for i := range previous {
+		before[previous[i].ID] = &previous[i]
+	}
+
+	for i := range current {
+		entry := &current[i]
+		old, ok := before[entry.ID]
+		if !ok {
+			added = append(added, *entry)
+			continue
+		}
+		delete(before, entry.ID)
+
+		if old.Block != entry.Block || s.parser.renderEntry(old) != s.parser.renderEntry(entry) {
+			changed = append(changed, *entry)
+		}
+	}
+
+	for i := range previous {
+		if _, ok := before[previous[i].ID]; ok {
+			removed = append(removed, previous[i])
+		}
+	}
+	return added, removed, changed
+}
+
+// realUser returns the name and ID of the user who invoked hostsctl, looking
+// through sudo.
+func realUser() (string, int) {
+	if name := os.Getenv("SUDO_USER"); name != "" {
+		uid, err := strconv.Atoi(os.Getenv("SUDO_UID"))
+		if err != nil {
+			uid = -1
+		}
+		return name, uid
+	}
+
+	uid := os.Getuid()
+	if current, err := user.Current(); err == nil {
+		return current.Username, uid
+	}
+	return strconv.Itoa(uid), uid
+}
@@ -0,0 +1,152 @@
+package hosts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStore_AuditLog(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-audit-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	t.Setenv("SUDO_USER", "alice")
+	t.Setenv("SUDO_UID", "1001")
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	if err := os.WriteFile(hostsFile, []byte("127.0.0.1\tlocalhost\n"), 0644); err != nil {
+		t.Fatalf("Failed to create hosts file: %v", err)
+	}
+
+	auditLog := NewAuditLog(filepath.Join(tmpDir, "log", "audit.log"))
+	store := NewStore(hostsFile, false)
+	store.SetAuditLog(auditLog)
+	store.SetCommand("hostsctl add --ip 10.0.0.1 --name api.local")
+
+	err = store.Update(func(hostsData *HostsFile) error {
+		hostsData.AddEntry(Entry{IP: "10.0.0.1", Names: []string{"api.local"}, Block: DefaultBlock})
+		hostsData.AddEntry(Entry{IP: "10.0.0.2", Names: []string{"web.local"}, Block: DefaultBlock})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Store.Update() error = %v", err)
+	}
+
+	store.SetCommand("hostsctl disable --name api.local")
+	err = store.Update(func(hostsData *HostsFile) error {
+		hostsData.DisableEntry(hostsData.FindByName("api.local")[0].ID)
+		hostsData.RemoveEntry(hostsData.FindByName("web.local")[0].ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Store.Update() error = %v", err)
+	}
+
+	records, err := auditLog.Read(AuditFilter{})
+	if err != nil {
+		t.Fatalf("AuditLog.Read() error = %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 audit records, got %d", len(records))
+	}
+
+	first, second := records[0], records[1]
+	if first.User != "alice" || first.UID != 1001 {
+		t.Errorf("Expected user alice (1001), got %s (%d)", first.User, first.UID)
+	}
+	if first.Command != "hostsctl add --ip 10.0.0.1 --name api.local" {
+		t.Errorf("Unexpected command %q", first.Command)
+	}
+	if len(first.Added) != 2 || len(first.Removed) != 0 || len(first.Changed) != 0 {
+		t.Errorf("First record: expected 2 added entries, got +%d -%d ~%d", len(first.Added), len(first.Removed), len(first.Changed))
+	}
+	if len(second.Added) != 0 || len(second.Removed) != 1 || len(second.Changed) != 1 {
+		t.Errorf("Second record: expected 1 removed and 1 changed entry, got +%d -%d ~%d", len(second.Added), len(second.Removed), len(second.Changed))
+	}
+	if second.SHA256 != store.Fingerprint().SHA256 {
+		t.Errorf("Expected the checksum of the resulting file, got %s", second.SHA256)
+	}
+
+	records, err = auditLog.Read(AuditFilter{Hostname: "web.local"})
+	if err != nil {
+		t.Fatalf("AuditLog.Read() error = %v", err)
+	}
+	if len(records) != 2 {
+		t.Errorf("Expected 2 records touching web.local, got %d", len(records))
+	}
+
+	records, err = auditLog.Read(AuditFilter{User: "bob"})
+	if err != nil {
+		t.Fatalf("AuditLog.Read() error = %v", err)
+	}
+	if len(records) != 0 {
+		t.Errorf("Expected no records by bob, got %d", len(records))
+	}
+}
+
+func TestStore_PreviousEntriesFromLoad(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-audit-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	if err := os.WriteFile(hostsFile, []byte("127.0.0.1\tlocalhost\n"), 0644); err != nil {
+		t.Fatalf("Failed to create hosts file: %v", err)
+	}
+
+	store := NewStore(hostsFile, false)
+	store.SetAuditLog(NewAuditLog(filepath.Join(tmpDir, "audit.log")))
+
+	hostsData, err := store.Load()
+	if err != nil {
+		t.Fatalf("Store.Load() error = %v", err)
+	}
+	hostsData.DisableEntry(hostsData.Entries[0].ID)
+	hostsData.Entries[0].Names[0] = "renamed.local"
+	hostsData.Entries[0].Tags = append(hostsData.Entries[0].Tags, "dev")
+
+	// A backup of the loaded content is not read back: it does not even exist
+	backup := &BackupInfo{Path: filepath.Join(tmpDir, "missing.bak"), SHA256: store.Fingerprint().SHA256}
+	previous := store.previousEntries(backup)
+	if len(previous) != 1 || previous[0].Disabled || previous[0].Names[0] != "localhost" || len(previous[0].Tags) != 0 {
+		t.Errorf("previousEntries() = %+v, want the entry as loaded", previous)
+	}
+}
+
+func TestAuditFilter_Matches(t *testing.T) {
+	now := time.Now()
+	record := AuditRecord{
+		Time:    now,
+		User:    "alice",
+		Changed: []Entry{{IP: "10.0.0.1", Names: []string{"api.local", "API.example"}}},
+	}
+
+	tests := []struct {
+		name   string
+		filter AuditFilter
+		want   bool
+	}{
+		{"empty filter", AuditFilter{}, true},
+		{"user", AuditFilter{User: "alice"}, true},
+		{"other user", AuditFilter{User: "bob"}, false},
+		{"hostname", AuditFilter{Hostname: "api.example"}, true},
+		{"other hostname", AuditFilter{Hostname: "web.local"}, false},
+		{"in range", AuditFilter{Since: now.Add(-time.Hour), Until: now.Add(time.Hour)}, true},
+		{"before range", AuditFilter{Since: now.Add(time.Hour)}, false},
+		{"after range", AuditFilter{Until: now.Add(-time.Hour)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Matches(record); got != tt.want {
+				t.Errorf("AuditFilter.Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,533 @@
+package hosts
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vaxvhbe/hostsctl/pkg"
+)
+
+// Automatic backups are snapshots named after the SHA-256 of their content:
+// "<hosts file name>.hostsctl.<sha256>.bak", with a ".gz" suffix when
+// compressed. Identical snapshots are stored once. A manifest next to them,
+// "<hosts file name>.hostsctl.manifest.json", records the checksum, original
+// path, creation time and triggering command of every snapshot.
+const (
+	backupInfix    = ".hostsctl."
+	backupSuffix   = ".bak"
+	gzipSuffix     = ".gz"
+	manifestSuffix = "manifest.json"
+	shortIDLength  = 12
+)
+
+// backupManifest is the on-disk index of the snapshots of one hosts file.
+type backupManifest struct {
+	Snapshots []snapshotRecord `json:"snapshots"`
+}
+
+// snapshotRecord describes one snapshot in the manifest.
+type snapshotRecord struct {
+	SHA256     string    `json:"sha256"`            // Checksum of the uncompressed content
+	File       string    `json:"file"`              // File name inside the backup directory
+	Original   string    `json:"original"`          // Path of the hosts file the snapshot was taken from
+	CreatedAt  time.Time `json:"created_at"`        // When the snapshot was last taken
+	Command    string    `json:"command,omitempty"` // Command that triggered the snapshot
+	Compressed bool      `json:"compressed"`        // Whether the file is gzip-compressed
+	Entries    int       `json:"entries,omitempty"` // Number of entries, counted when the snapshot was taken
+}
+
+// SetBackupDir sets the directory automatic backups are written to and listed
+// from. An empty dir keeps them next to the hosts file.
+func (s *Store) SetBackupDir(dir string) {
+	s.backupDir = dir
+}
+
+// BackupDir returns the directory automatic backups are written to.
+func (s *Store) BackupDir() string {
+	if s.backupDir == "" {
+		return filepath.Dir(s.path)
+	}
+	return s.backupDir
+}
+
+// SetBackupCompression controls whether new snapshots are gzip-compressed.
+func (s *Store) SetBackupCompression(compress bool) {
+	s.compressBackups = compress
+}
+
+// SetCommand records the command line that triggers the following backups,
+// so that it can be shown in the backup manifest.
+func (s *Store) SetCommand(command string) {
+	s.command = command
+}
+
+// createBackup takes a snapshot of the current hosts file before it is replaced.
+func (s *Store) createBackup() (*BackupInfo, error) {
+	return s.snapshot()
+}
+
+// snapshot stores the current content of the hosts file in the backup
+// directory and records it in the manifest. If an identical snapshot already
+// exists, only its creation time and command are updated.
+func (s *Store) snapshot() (*BackupInfo, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hosts file: %w", err)
+	}
+
+	dir := s.BackupDir()
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	manifest, err := s.loadManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+	record := snapshotRecord{
+		SHA256:     checksum,
+		Original:   s.path,
+		CreatedAt:  time.Now(),
+		Command:    s.command,
+		Compressed: s.compressBackups,
+		Entries:    s.countSnapshotEntries(checksum, data),
+	}
+
+	existing := manifest.find(record.SHA256)
+	if existing != nil && fileExists(filepath.Join(dir, existing.File)) {
+		existing.CreatedAt = record.CreatedAt
+		existing.Command = record.Command
+		existing.Entries = record.Entries
+		record = *existing
+	} else {
+		record.File = filepath.Base(s.path) + backupInfix + record.SHA256 + backupSuffix
+		content := data
+		if record.Compressed {
+			record.File += gzipSuffix
+			if content, err = gzipBytes(data); err != nil {
+				return nil, fmt.Errorf("failed to compress backup: %w", err)
+			}
+		}
+
+		path := filepath.Join(dir, record.File)
+		if err := pkg.ValidateSecurePath(path); err != nil {
+			return nil, fmt.Errorf("invalid backup path: %w", err)
+		}
+		if err := replaceFile(context.Background(), path, string(content)); err != nil {
+			return nil, fmt.Errorf("failed to write backup: %w", err)
+		}
+
+		if existing != nil {
+			*existing = record
+		} else {
+			manifest.Snapshots = append(manifest.Snapshots, record)
+		}
+	}
+
+	if err := s.saveManifest(manifest); err != nil {
+		return nil, err
+	}
+
+	info := s.backupInfo(record)
+	if stat, err := os.Stat(info.Path); err == nil {
+		info.Size = stat.Size()
+	}
+	return &info, nil
+}
+
+// Backup creates a manual backup of the hosts file to the specified path.
+// If outputPath is empty, a snapshot is taken in the backup directory.
+// Returns BackupInfo with metadata about the created backup.
+func (s *Store) Backup(outputPath string) (*BackupInfo, error) {
+	if outputPath == "" {
+		return s.snapshot()
+	}
+
+	sourceFile, err := os.Open(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer func() { _ = sourceFile.Close() }()
+
+	stat, err := sourceFile.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get source file info: %w", err)
+	}
+
+	// Validate file path to prevent directory traversal
+	if err := pkg.ValidateSecurePath(outputPath); err != nil {
+		return nil, fmt.Errorf("invalid output path: %w", err)
+	}
+
+	backupFile, err := os.Create(outputPath) // #nosec G304 -- path validated above
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup file: %w", err)
+	}
+	defer func() { _ = backupFile.Close() }()
+
+	_, err = io.Copy(backupFile, sourceFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy file contents: %w", err)
+	}
+
+	return &BackupInfo{
+		Path:      outputPath,
+		Original:  s.path,
+		CreatedAt: time.Now(),
+		Size:      stat.Size(),
+	}, nil
+}
+
+// Restore replaces the current hosts file with content from a backup.
+// Snapshots whose content does not match their recorded SHA-256 are refused.
+func (s *Store) Restore(backupPath string) error {
+	return s.RestoreContext(context.Background(), backupPath)
+}
+
+// RestoreContext is like Restore but saves the backup with SaveContext, so
+// that the hosts file is left untouched if ctx is canceled.
+func (s *Store) RestoreContext(ctx context.Context, backupPath string) error {
+	if err := s.requiresRoot(); err != nil {
+		return err
+	}
+
+	data, err := s.ReadBackup(backupPath)
+	if err != nil {
+		return err
+	}
+
+	hostsFile, err := s.parser.Parse(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to parse backup file: %w", err)
+	}
+
+	hostsFile.Path = s.path
+	return s.SaveContext(ctx, hostsFile)
+}
+
+// ListBackups returns information about all backups of the hosts file, oldest
+// first: the snapshots recorded in the manifest and any plain timestamped
+// backups written by earlier versions, which carry no checksum. Only the
+// manifest and file metadata are read; the entry count is the one recorded
+// when the snapshot was taken, and is 0 for backups that have none (see
+// CountBackupEntries).
+func (s *Store) ListBackups() ([]BackupInfo, error) {
+	manifest, err := s.loadManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []BackupInfo
+	known := make(map[string]bool, len(manifest.Snapshots))
+	for _, record := range manifest.Snapshots {
+		known[record.File] = true
+		info := s.backupInfo(record)
+		stat, err := os.Stat(info.Path)
+		if err != nil {
+			continue
+		}
+		info.Size = stat.Size()
+		backups = append(backups, info)
+	}
+
+	prefix := filepath.Base(s.path) + backupInfix
+	var matches []string
+	for _, suffix := range []string{backupSuffix, backupSuffix + gzipSuffix} {
+		found, err := filepath.Glob(filepath.Join(s.BackupDir(), prefix+"*"+suffix))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list backups: %w", err)
+		}
+		matches = append(matches, found...)
+	}
+
+	for _, match := range matches {
+		name := filepath.Base(match)
+		if known[name] {
+			continue
+		}
+
+		stat, err := os.Stat(match)
+		if err != nil {
+			continue
+		}
+
+		id := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(name, prefix), gzipSuffix), backupSuffix)
+		backups = append(backups, BackupInfo{
+			ID:         id,
+			Path:       match,
+			Original:   s.path,
+			CreatedAt:  stat.ModTime(),
+			Size:       stat.Size(),
+			Compressed: strings.HasSuffix(name, gzipSuffix),
+		})
+	}
+
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.Before(backups[j].CreatedAt)
+	})
+
+	return backups, nil
+}
+
+// FindBackup returns the backup with the given ID, as listed by ListBackups.
+// Snapshots can also be found by any unambiguous prefix of their SHA-256.
+func (s *Store) FindBackup(id string) (*BackupInfo, error) {
+	backups, err := s.ListBackups()
+	if err != nil {
+		return nil, err
+	}
+
+	var found *BackupInfo
+	for i := range backups {
+		backup := &backups[i]
+		if backup.ID == id || backup.SHA256 == id {
+			return backup, nil
+		}
+		if backup.SHA256 != "" && len(id) >= 4 && strings.HasPrefix(backup.SHA256, id) {
+			if found != nil {
+				return nil, fmt.Errorf("backup ID '%s' is ambiguous", id)
+			}
+			found = backup
+		}
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf("backup '%s' not found in %s", id, s.BackupDir())
+	}
+	return found, nil
+}
+
+// LoadBackup reads and parses a backup file without restoring it.
+// Snapshots are checked against their recorded SHA-256.
+func (s *Store) LoadBackup(backupPath string) (*HostsFile, error) {
+	data, err := s.ReadBackup(backupPath)
+	if err != nil {
+		return nil, err
+	}
+
+	hostsFile, err := NewParser(false).Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse backup file: %w", err)
+	}
+
+	hostsFile.Path = backupPath
+	return hostsFile, nil
+}
+
+// ReadBackup returns the uncompressed content of a backup file. The content
+// of snapshots, identified through the manifest or their content-addressed
+// file name, must match their SHA-256.
+func (s *Store) ReadBackup(backupPath string) ([]byte, error) {
+	// Validate file path to prevent directory traversal
+	if err := pkg.ValidateSecurePath(backupPath); err != nil {
+		return nil, fmt.Errorf("invalid backup path: %w", err)
+	}
+
+	data, err := os.ReadFile(backupPath) // #nosec G304 -- path validated above
+	if err != nil {
+		return nil, fmt.Errorf("failed to open backup file: %w", err)
+	}
+
+	if strings.HasSuffix(backupPath, gzipSuffix) {
+		if data, err = gunzipBytes(data); err != nil {
+			return nil, fmt.Errorf("failed to decompress backup file: %w", err)
+		}
+	}
+
+	expected := s.expectedChecksum(backupPath)
+	if expected == "" {
+		return data, nil
+	}
+
+	sum := sha256.Sum256(data)
+	if actual := hex.EncodeToString(sum[:]); actual != expected {
+		return nil, fmt.Errorf("backup %s is corrupted: SHA-256 is %s, expected %s", backupPath, actual, expected)
+	}
+	return data, nil
+}
+
+// expectedChecksum returns the SHA-256 a backup file must match, or "" for
+// plain copies that carry no checksum.
+func (s *Store) expectedChecksum(backupPath string) string {
+	if filepath.Clean(filepath.Dir(backupPath)) == filepath.Clean(s.BackupDir()) {
+		if manifest, err := s.loadManifest(); err == nil {
+			for _, record := range manifest.Snapshots {
+				if record.File == filepath.Base(backupPath) {
+					return record.SHA256
+				}
+			}
+		}
+	}
+
+	name := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(backupPath), gzipSuffix), backupSuffix)
+	if i := strings.LastIndex(name, backupInfix); i >= 0 && isSHA256(name[i+len(backupInfix):]) {
+		return name[i+len(backupInfix):]
+	}
+	return ""
+}
+
+// removeBackup deletes a backup file and its manifest record.
+func (s *Store) removeBackup(backup BackupInfo) error {
+	if err := os.Remove(backup.Path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove backup %s: %w", backup.Path, err)
+	}
+
+	if backup.SHA256 == "" {
+		return nil
+	}
+
+	manifest, err := s.loadManifest()
+	if err != nil {
+		return err
+	}
+
+	kept := manifest.Snapshots[:0]
+	for _, record := range manifest.Snapshots {
+		if record.SHA256 != backup.SHA256 {
+			kept = append(kept, record)
+		}
+	}
+	manifest.Snapshots = kept
+	return s.saveManifest(manifest)
+}
+
+// CountBackupEntries fills in the entry count of backups that have none
+// recorded, such as plain backups written by earlier versions, by reading
+// them. Backups that cannot be read keep a count of 0.
+func (s *Store) CountBackupEntries(backups []BackupInfo) {
+	for i := range backups {
+		if backups[i].EntryCount != 0 {
+			continue
+		}
+		if hostsFile, err := s.LoadBackup(backups[i].Path); err == nil {
+			backups[i].EntryCount = len(hostsFile.Entries)
+		}
+	}
+}
+
+// countSnapshotEntries returns the number of entries in data, the content of
+// the hosts file with the given checksum. The count of the last Load or Save
+// is used when it was of that content, to avoid parsing the file again.
+func (s *Store) countSnapshotEntries(checksum string, data []byte) int {
+	if s.fingerprint != nil && s.fingerprint.SHA256 == checksum {
+		return s.entryCount
+	}
+
+	hostsFile, err := NewParser(false).Parse(bytes.NewReader(data))
+	if err != nil {
+		return 0
+	}
+	return len(hostsFile.Entries)
+}
+
+// backupInfo converts a manifest record to the BackupInfo of its snapshot.
+func (s *Store) backupInfo(record snapshotRecord) BackupInfo {
+	return BackupInfo{
+		ID:         record.SHA256[:shortIDLength],
+		Path:       filepath.Join(s.BackupDir(), record.File),
+		Original:   record.Original,
+		CreatedAt:  record.CreatedAt,
+		SHA256:     record.SHA256,
+		Command:    record.Command,
+		EntryCount: record.Entries,
+		Compressed: record.Compressed,
+	}
+}
+
+// manifestPath returns the path of the backup manifest of the hosts file.
+func (s *Store) manifestPath() string {
+	return filepath.Join(s.BackupDir(), filepath.Base(s.path)+backupInfix+manifestSuffix)
+}
+
+// loadManifest reads the backup manifest. A missing manifest is empty.
+func (s *Store) loadManifest() (*backupManifest, error) {
+	manifest := &backupManifest{}
+
+	data, err := os.ReadFile(s.manifestPath())
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup manifest: %w", err)
+	}
+
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse backup manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// saveManifest atomically writes the backup manifest.
+func (s *Store) saveManifest(manifest *backupManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal backup manifest: %w", err)
+	}
+
+	if err := replaceFile(context.Background(), s.manifestPath(), string(data)+"\n"); err != nil {
+		return fmt.Errorf("failed to write backup manifest: %w", err)
+	}
+	return nil
+}
+
+// find returns the record of the snapshot with the given checksum, or nil.
+func (m *backupManifest) find(sha string) *snapshotRecord {
+	for i := range m.Snapshots {
+		if m.Snapshots[i].SHA256 == sha {
+			return &m.Snapshots[i]
+		}
+	}
+	return nil
+}
+
+// isSHA256 reports whether s is a hex-encoded SHA-256 checksum.
+func isSHA256(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// fileExists reports whether a file exists at path.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// gzipBytes compresses data with gzip.
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// gunzipBytes decompresses gzip data.
+func gunzipBytes(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+	return io.ReadAll(r)
+}
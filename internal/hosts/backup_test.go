@@ -0,0 +1,231 @@
+package hosts
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStore_SnapshotDeduplication(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-backup-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsPath := filepath.Join(tmpDir, "hosts")
+	if err := os.WriteFile(hostsPath, []byte("127.0.0.1\tlocalhost\n"), 0644); err != nil {
+		t.Fatalf("Failed to write hosts file: %v", err)
+	}
+
+	store := NewStore(hostsPath, false)
+	store.SetCommand("hostsctl backup")
+
+	first, err := store.Backup("")
+	if err != nil {
+		t.Fatalf("Store.Backup() error = %v", err)
+	}
+	second, err := store.Backup("")
+	if err != nil {
+		t.Fatalf("Store.Backup() error = %v", err)
+	}
+
+	if first.SHA256 != second.SHA256 || first.Path != second.Path {
+		t.Errorf("Identical snapshots should share one file, got %s and %s", first.Path, second.Path)
+	}
+	if !strings.Contains(filepath.Base(first.Path), first.SHA256) {
+		t.Errorf("Snapshot file %s should be named after its checksum %s", first.Path, first.SHA256)
+	}
+
+	backups, err := store.ListBackups()
+	if err != nil {
+		t.Fatalf("Store.ListBackups() error = %v", err)
+	}
+	if len(backups) != 1 {
+		t.Fatalf("Expected 1 backup, got %d", len(backups))
+	}
+	if backups[0].Command != "hostsctl backup" || backups[0].Original != hostsPath {
+		t.Errorf("Manifest record = %+v, want command and original path", backups[0])
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "hosts.hostsctl.manifest.json")); err != nil {
+		t.Errorf("Manifest should be written: %v", err)
+	}
+}
+
+func TestStore_CompressedSnapshot(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-backup-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsPath := filepath.Join(tmpDir, "hosts")
+	content := "127.0.0.1\tlocalhost\n10.0.0.1\tapp.local\n"
+	if err := os.WriteFile(hostsPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write hosts file: %v", err)
+	}
+
+	store := NewStore(hostsPath, false)
+	store.SetBackupCompression(true)
+
+	backup, err := store.Backup("")
+	if err != nil {
+		t.Fatalf("Store.Backup() error = %v", err)
+	}
+	if !backup.Compressed || !strings.HasSuffix(backup.Path, ".bak.gz") {
+		t.Errorf("Backup %s should be compressed", backup.Path)
+	}
+
+	hostsFile, err := store.LoadBackup(backup.Path)
+	if err != nil {
+		t.Fatalf("Store.LoadBackup() error = %v", err)
+	}
+	if len(hostsFile.Entries) != 2 {
+		t.Errorf("Expected 2 entries in backup, got %d", len(hostsFile.Entries))
+	}
+
+	if err := os.WriteFile(hostsPath, []byte("127.0.0.1\tlocalhost\n"), 0644); err != nil {
+		t.Fatalf("Failed to write hosts file: %v", err)
+	}
+	if err := store.Restore(backup.Path); err != nil {
+		t.Fatalf("Store.Restore() error = %v", err)
+	}
+	assertFileContent(t, hostsPath, content)
+}
+
+func TestStore_RestoreRefusesCorruptedSnapshot(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-backup-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsPath := filepath.Join(tmpDir, "hosts")
+	content := "127.0.0.1\tlocalhost\n"
+	if err := os.WriteFile(hostsPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write hosts file: %v", err)
+	}
+
+	store := NewStore(hostsPath, false)
+	backup, err := store.Backup("")
+	if err != nil {
+		t.Fatalf("Store.Backup() error = %v", err)
+	}
+
+	if err := os.WriteFile(backup.Path, []byte("10.6.6.6\tlocalhost\n"), 0644); err != nil {
+		t.Fatalf("Failed to tamper with backup: %v", err)
+	}
+
+	err = store.Restore(backup.Path)
+	if err == nil || !strings.Contains(err.Error(), "corrupted") {
+		t.Fatalf("Store.Restore() error = %v, want checksum mismatch", err)
+	}
+	assertFileContent(t, hostsPath, content)
+
+	// A copy keeps its content-addressed name and is still checked without the manifest
+	copied := filepath.Join(tmpDir, "elsewhere", filepath.Base(backup.Path))
+	if err := os.MkdirAll(filepath.Dir(copied), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(copied, []byte("10.6.6.6\tlocalhost\n"), 0644); err != nil {
+		t.Fatalf("Failed to write copy: %v", err)
+	}
+	if err := store.Restore(copied); err == nil {
+		t.Error("Store.Restore() should refuse a copied snapshot with a wrong checksum")
+	}
+}
+
+func TestStore_FindBackupByPrefix(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-backup-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsPath := filepath.Join(tmpDir, "hosts")
+	if err := os.WriteFile(hostsPath, []byte("127.0.0.1\tlocalhost\n"), 0644); err != nil {
+		t.Fatalf("Failed to write hosts file: %v", err)
+	}
+
+	store := NewStore(hostsPath, false)
+	backup, err := store.Backup("")
+	if err != nil {
+		t.Fatalf("Store.Backup() error = %v", err)
+	}
+
+	for _, id := range []string{backup.ID, backup.SHA256, backup.SHA256[:6]} {
+		found, err := store.FindBackup(id)
+		if err != nil {
+			t.Fatalf("FindBackup(%q) error = %v", id, err)
+		}
+		if found.Path != backup.Path {
+			t.Errorf("FindBackup(%q) = %s, want %s", id, found.Path, backup.Path)
+		}
+	}
+}
+
+func TestStore_BackupEntryCount(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-backup-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsPath := filepath.Join(tmpDir, "hosts")
+	if err := os.WriteFile(hostsPath, []byte("127.0.0.1\tlocalhost\n::1\tlocalhost\n"), 0644); err != nil {
+		t.Fatalf("Failed to write hosts file: %v", err)
+	}
+
+	store := NewStore(hostsPath, false)
+	err = store.Update(func(hostsFile *HostsFile) error {
+		hostsFile.AddEntry(Entry{IP: "10.0.0.1", Names: []string{"app.local"}, Block: DefaultBlock})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Store.Update() error = %v", err)
+	}
+
+	backups, err := store.ListBackups()
+	if err != nil {
+		t.Fatalf("Store.ListBackups() error = %v", err)
+	}
+	if len(backups) != 1 || backups[0].EntryCount != 2 {
+		t.Fatalf("ListBackups() = %+v, want one backup with 2 entries", backups)
+	}
+
+	// The count comes from the manifest: listing does not read the snapshot
+	if err := os.WriteFile(backups[0].Path, []byte("garbage"), 0600); err != nil {
+		t.Fatalf("Failed to overwrite snapshot: %v", err)
+	}
+	backups, err = store.ListBackups()
+	if err != nil {
+		t.Fatalf("Store.ListBackups() error = %v", err)
+	}
+	if backups[0].EntryCount != 2 {
+		t.Errorf("EntryCount = %d, want 2 from the manifest", backups[0].EntryCount)
+	}
+
+	// Plain backups of earlier versions are only counted on request
+	legacy := filepath.Join(tmpDir, "hosts.hostsctl.20240101-120000.bak")
+	if err := os.WriteFile(legacy, []byte("127.0.0.1\tlocalhost\n"), 0600); err != nil {
+		t.Fatalf("Failed to write legacy backup: %v", err)
+	}
+	backups, err = store.ListBackups()
+	if err != nil {
+		t.Fatalf("Store.ListBackups() error = %v", err)
+	}
+	for _, backup := range backups {
+		if backup.Path == legacy && backup.EntryCount != 0 {
+			t.Errorf("Legacy backup EntryCount = %d, want 0 before counting", backup.EntryCount)
+		}
+	}
+
+	store.CountBackupEntries(backups)
+	for _, backup := range backups {
+		if backup.Path == legacy && backup.EntryCount != 1 {
+			t.Errorf("Legacy backup EntryCount = %d, want 1", backup.EntryCount)
+		}
+	}
+}
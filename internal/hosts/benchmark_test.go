@@ -0,0 +1,95 @@
+package hosts
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// blocklist returns a hosts file in the style of an ad-blocking list with the
+// given number of entries.
+func blocklist(lines int) string {
+	var b strings.Builder
+	b.WriteString("127.0.0.1\tlocalhost\n::1\tlocalhost ip6-localhost\n\n# BEGIN hostsctl\n")
+	for i := 0; i < lines; i++ {
+		fmt.Fprintf(&b, "0.0.0.0 ads%d.tracker-%d.example.com\n", i, i%997)
+	}
+	b.WriteString("# END hostsctl\n")
+	return b.String()
+}
+
+func BenchmarkParse1M(b *testing.B) {
+	input := blocklist(1_000_000)
+	parser := NewParser(false)
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := parser.Parse(strings.NewReader(input)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSerialize1M(b *testing.B) {
+	input := blocklist(1_000_000)
+	parser := NewParser(false)
+	hostsFile, err := parser.Parse(strings.NewReader(input))
+	if err != nil {
+		b.Fatal(err)
+	}
+	hostsFile.AddEntry(Entry{IP: "10.0.0.1", Names: []string{"app.local"}, Block: DefaultBlock})
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_ = parser.Serialize(hostsFile)
+	}
+}
+
+func BenchmarkAddEntry100k(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		hostsFile := &HostsFile{}
+		for j := 0; j < 100_000; j++ {
+			hostsFile.AddEntry(Entry{IP: "0.0.0.0", Names: []string{fmt.Sprintf("ads%d.example.com", j)}, Block: DefaultBlock})
+		}
+	}
+}
+
+func BenchmarkFindByName1M(b *testing.B) {
+	hostsFile, err := NewParser(false).Parse(strings.NewReader(blocklist(1_000_000)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	name := fmt.Sprintf("ads%d.tracker-%d.example.com", 123456, 123456%997)
+	hostsFile.FindByName(name)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if len(hostsFile.FindByName(name)) != 1 {
+			b.Fatal("entry not found")
+		}
+	}
+}
+
+func BenchmarkStoreLoadSave1M(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "hosts")
+	if err := os.WriteFile(path, []byte(blocklist(1_000_000)), 0644); err != nil {
+		b.Fatal(err)
+	}
+	store := NewStore(path, false)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		hostsFile, err := store.Load()
+		if err != nil {
+			b.Fatal(err)
+		}
+		hostsFile.AddEntry(Entry{IP: "10.0.0.1", Names: []string{fmt.Sprintf("app%d.local", i)}, Block: DefaultBlock})
+		if err := store.Save(hostsFile); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package hosts
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// legacyTempSuffix is appended to the hosts file path by the fixed temporary
+// file of earlier versions.
+const legacyTempSuffix = ".tmp"
+
+// TempFile describes a temporary file left behind by an interrupted write.
+type TempFile struct {
+	Path    string    `json:"path"`     // Path of the temporary file
+	Size    int64     `json:"size"`     // Size in bytes
+	ModTime time.Time `json:"mod_time"` // When it was last written
+}
+
+// TempFiles returns the temporary files of writes to the hosts file: those
+// next to the hosts file or the file it links to, including the fixed
+// "<name>.tmp" file of earlier versions, and those of backups, the backup
+// manifest and the journal in the backup directory. They are only orphaned
+// if no write is in progress, so the exclusive lock of the hosts file should
+// be held while calling it.
+func (s *Store) TempFiles() ([]TempFile, error) {
+	paths := []string{s.path}
+	if target, _, err := resolveTarget(s.path); err == nil && target != s.path {
+		paths = append(paths, target)
+	}
+
+	var patterns []string
+	for _, path := range paths {
+		patterns = append(patterns,
+			filepath.Join(filepath.Dir(path), tempPattern(path)),
+			path+legacyTempSuffix)
+	}
+	backupFiles := filepath.Base(s.path) + backupInfix + "*"
+	patterns = append(patterns, filepath.Join(s.BackupDir(), tempPattern(backupFiles)))
+
+	seen := make(map[string]bool)
+	var files []TempFile
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list temporary files: %w", err)
+		}
+
+		for _, path := range matches {
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+
+			info, err := os.Lstat(path)
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			files = append(files, TempFile{Path: path, Size: info.Size(), ModTime: info.ModTime()})
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	return files, nil
+}
+
+// RemoveTempFiles removes the temporary files found by TempFiles and returns
+// them. With dryRun set, nothing is removed.
+func (s *Store) RemoveTempFiles(dryRun bool) ([]TempFile, error) {
+	files, err := s.TempFiles()
+	if err != nil || dryRun {
+		return files, err
+	}
+
+	for i, file := range files {
+		if err := os.Remove(file.Path); err != nil && !os.IsNotExist(err) {
+			return files[:i], fmt.Errorf("failed to remove temporary file: %w", err)
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,68 @@
+package hosts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStore_RemoveTempFiles(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-cleanup-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	backupDir := filepath.Join(tmpDir, "backups")
+	if err := os.MkdirAll(backupDir, 0750); err != nil {
+		t.Fatalf("Failed to create backup dir: %v", err)
+	}
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	orphaned := []string{
+		filepath.Join(tmpDir, ".hosts.hostsctl-123.tmp"),
+		filepath.Join(tmpDir, "hosts.tmp"),
+		filepath.Join(backupDir, ".hosts.hostsctl.manifest.json.hostsctl-456.tmp"),
+	}
+	kept := []string{
+		hostsFile,
+		filepath.Join(tmpDir, ".other.hostsctl-789.tmp"),
+		filepath.Join(tmpDir, "notes.tmp"),
+		filepath.Join(backupDir, ".other.hostsctl.manifest.json.hostsctl-456.tmp"),
+	}
+	for _, path := range append(append([]string{}, orphaned...), kept...) {
+		if err := os.WriteFile(path, []byte("127.0.0.1\tlocalhost\n"), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", path, err)
+		}
+	}
+
+	store := NewStore(hostsFile, false)
+	store.SetBackupDir(backupDir)
+
+	files, err := store.RemoveTempFiles(true)
+	if err != nil {
+		t.Fatalf("RemoveTempFiles(dry run) error = %v", err)
+	}
+	if len(files) != len(orphaned) {
+		t.Fatalf("Expected %d temporary files, got %v", len(orphaned), files)
+	}
+	for _, path := range orphaned {
+		if !fileExists(path) {
+			t.Errorf("Dry run should not remove %s", path)
+		}
+	}
+
+	if _, err := store.RemoveTempFiles(false); err != nil {
+		t.Fatalf("RemoveTempFiles() error = %v", err)
+	}
+	for _, path := range orphaned {
+		if fileExists(path) {
+			t.Errorf("Expected %s to be removed", path)
+		}
+	}
+	for _, path := range kept {
+		if !fileExists(path) {
+			t.Errorf("Expected %s to be kept", path)
+		}
+	}
+}
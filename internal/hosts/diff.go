@@ -0,0 +1,242 @@
+package hosts
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// diffContext is the number of unchanged lines shown around each change.
+	diffContext = 3
+	// maxDiffEdits bounds the work spent looking for a minimal diff. The
+	// trace kept for backtracking grows with its square, about 2 MiB here.
+	// Beyond it, the changed region is shown as removed and re-added as a
+	// whole.
+	maxDiffEdits = 512
+)
+
+// diffOp is one line of a line-based diff: kept (' '), removed ('-') or
+// added ('+').
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// UnifiedDiff returns the changes from oldContent to newContent in unified
+// diff format, labelled with oldName and newName. It returns "" if the
+// contents are identical.
+func UnifiedDiff(oldName, newName, oldContent, newContent string) string {
+	if oldContent == newContent {
+		return ""
+	}
+	return formatUnifiedDiff(oldName, newName, diffLines(splitLines(oldContent), splitLines(newContent)))
+}
+
+// formatUnifiedDiff formats the ops computed by diffLines between two
+// different contents as a unified diff.
+func formatUnifiedDiff(oldName, newName string, ops []diffOp) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+
+	for start := 0; start < len(ops); {
+		// Find the next change and extend the hunk while changes are close
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+
+		last := first
+		for i := first + 1; i < len(ops); i++ {
+			if ops[i].kind == ' ' {
+				continue
+			}
+			if i-last > 2*diffContext {
+				break
+			}
+			last = i
+		}
+
+		from := max(first-diffContext, start)
+		to := min(last+diffContext+1, len(ops))
+		writeHunk(&b, ops, from, to)
+		start = to
+	}
+
+	return b.String()
+}
+
+// writeHunk writes ops[from:to] as one hunk, with its "@@" header.
+func writeHunk(b *strings.Builder, ops []diffOp, from, to int) {
+	oldLine, newLine := 1, 1
+	for _, op := range ops[:from] {
+		if op.kind != '+' {
+			oldLine++
+		}
+		if op.kind != '-' {
+			newLine++
+		}
+	}
+
+	oldCount, newCount := 0, 0
+	for _, op := range ops[from:to] {
+		if op.kind != '+' {
+			oldCount++
+		}
+		if op.kind != '-' {
+			newCount++
+		}
+	}
+
+	fmt.Fprintf(b, "@@ -%s +%s @@\n", hunkRange(oldLine, oldCount), hunkRange(newLine, newCount))
+	for _, op := range ops[from:to] {
+		b.WriteByte(op.kind)
+		b.WriteString(op.line)
+		b.WriteByte('\n')
+	}
+}
+
+// hunkRange formats the line range of one side of a hunk like GNU diff.
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start-1)
+	case 1:
+		return fmt.Sprintf("%d", start)
+	default:
+		return fmt.Sprintf("%d,%d", start, count)
+	}
+}
+
+// splitLines splits content into lines without their line terminators.
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// diffLines computes a line-based diff between a and b. Common leading and
+// trailing lines are matched directly, so large files with small changes
+// stay cheap to compare.
+func diffLines(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b)-prefix-suffix)
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	ops = append(ops, myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+// myersDiff computes a minimal diff with Myers' O(ND) algorithm. If more than
+// maxDiffEdits edits are needed, all of a is removed and all of b added.
+func myersDiff(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		// Only additions or only removals: there is nothing to search for
+		return replaceAll(a, b)
+	}
+
+	limit := min(n+m, maxDiffEdits)
+	v := make([]int, 2*limit+3)
+	offset := limit + 1
+
+	// trace[d] holds v[-d-1..d+1] as it was before round d
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrackDiff(trace, a, b)
+			}
+		}
+	}
+
+	return replaceAll(a, b)
+}
+
+// replaceAll returns the ops removing all of a and adding all of b.
+func replaceAll(a, b []string) []diffOp {
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, line := range a {
+		ops = append(ops, diffOp{'-', line})
+	}
+	for _, line := range b {
+		ops = append(ops, diffOp{'+', line})
+	}
+	return ops
+}
+
+// backtrackDiff walks the rounds recorded by myersDiff backwards to recover
+// the edit script.
+func backtrackDiff(trace [][]int, a, b []string) []diffOp {
+	x, y := len(a), len(b)
+	var reversed []diffOp
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		at := func(k int) int { return v[k+d+1] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			reversed = append(reversed, diffOp{' ', a[x]})
+		}
+
+		if d > 0 {
+			if x == prevX {
+				y--
+				reversed = append(reversed, diffOp{'+', b[y]})
+			} else {
+				x--
+				reversed = append(reversed, diffOp{'-', a[x]})
+			}
+		}
+	}
+
+	ops := make([]diffOp, len(reversed))
+	for i, op := range reversed {
+		ops[len(reversed)-1-i] = op
+	}
+	return ops
+}
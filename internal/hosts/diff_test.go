@@ -0,0 +1,189 @@
+package hosts
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old      string
+		new      string
+		expected string
+	}{
+		{
+			name:     "identical",
+			old:      "127.0.0.1\tlocalhost\n",
+			new:      "127.0.0.1\tlocalhost\n",
+			expected: "",
+		},
+		{
+			name: "append",
+			old:  "127.0.0.1\tlocalhost\n",
+			new:  "127.0.0.1\tlocalhost\n10.0.0.1\tapi.local\n",
+			expected: "--- a\n+++ b\n@@ -1 +1,2 @@\n" +
+				" 127.0.0.1\tlocalhost\n" +
+				"+10.0.0.1\tapi.local\n",
+		},
+		{
+			name: "change in the middle",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			new:  "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			expected: "--- a\n+++ b\n@@ -2,7 +2,7 @@\n" +
+				" 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "separate hunks",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			new:  "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n",
+			expected: "--- a\n+++ b\n@@ -1,4 +1,4 @@\n" +
+				"-1\n+one\n 2\n 3\n 4\n" +
+				"@@ -9,4 +9,4 @@\n" +
+				" 9\n 10\n 11\n-12\n+twelve\n",
+		},
+		{
+			name:     "moved boundaries",
+			old:      "a\nb\nc\n",
+			new:      "b\nc\nd\n",
+			expected: "--- a\n+++ b\n@@ -1,3 +1,3 @@\n-a\n b\n c\n+d\n",
+		},
+		{
+			name:     "from empty",
+			old:      "",
+			new:      "10.0.0.1\tapi.local\n",
+			expected: "--- a\n+++ b\n@@ -0,0 +1 @@\n+10.0.0.1\tapi.local\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := UnifiedDiff("a", "b", tt.old, tt.new)
+			if got != tt.expected {
+				t.Errorf("UnifiedDiff() =\n%s\nwant\n%s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDiffLines_LargeFileSmallChange(t *testing.T) {
+	var old, changed []string
+	for i := 0; i < 100000; i++ {
+		old = append(old, fmt.Sprintf("0.0.0.0\tblocked%d.example", i))
+	}
+	changed = append(changed, old...)
+	changed[50000] = "0.0.0.0\tchanged.example"
+
+	ops := diffLines(old, changed)
+
+	var added, removed int
+	for _, op := range ops {
+		switch op.kind {
+		case '+':
+			added++
+		case '-':
+			removed++
+		}
+	}
+	if added != 1 || removed != 1 {
+		t.Errorf("Expected 1 added and 1 removed line, got %d and %d", added, removed)
+	}
+}
+
+func TestDiffLines_LargeInsertion(t *testing.T) {
+	old := []string{"127.0.0.1\tlocalhost", "::1\tlocalhost"}
+	changed := []string{old[0]}
+	for i := 0; i < 100000; i++ {
+		changed = append(changed, fmt.Sprintf("0.0.0.0\tblocked%d.example", i))
+	}
+	changed = append(changed, old[1])
+
+	// The inserted lines are found directly, not after maxDiffEdits rounds
+	ops := diffLines(old, changed)
+
+	added := 0
+	for _, op := range ops {
+		switch op.kind {
+		case '+':
+			added++
+		case '-':
+			t.Fatalf("Unexpected removed line %q", op.line)
+		}
+	}
+	if added != 100000 {
+		t.Errorf("Expected 100000 added lines, got %d", added)
+	}
+}
+
+func TestDiffLines_LargeRewrite(t *testing.T) {
+	var old, changed []string
+	for i := 0; i < 50000; i++ {
+		old = append(old, fmt.Sprintf("10.0.%d.%d\told%d.example", i/256%256, i%256, i))
+		changed = append(changed, fmt.Sprintf("10.1.%d.%d\tnew%d.example", i/256%256, i%256, i))
+	}
+
+	// Nothing matches, so the search gives up and replaces everything
+	ops := diffLines(old, changed)
+
+	if len(ops) != len(old)+len(changed) {
+		t.Fatalf("Expected %d ops, got %d", len(old)+len(changed), len(ops))
+	}
+	for i, op := range ops {
+		want := byte('-')
+		if i >= len(old) {
+			want = '+'
+		}
+		if op.kind != want {
+			t.Fatalf("Op %d is %q, want %q", i, op.kind, want)
+		}
+	}
+}
+
+func TestStore_DryRunSave(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-diff-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	original := "127.0.0.1\tlocalhost\n"
+	if err := os.WriteFile(hostsFile, []byte(original), 0644); err != nil {
+		t.Fatalf("Failed to create hosts file: %v", err)
+	}
+
+	store := NewStore(hostsFile, false)
+	store.SetDryRun(true)
+
+	err = store.Update(func(hostsData *HostsFile) error {
+		hostsData.AddEntry(Entry{IP: "10.0.0.1", Names: []string{"api.local"}, Block: DefaultBlock})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Store.Update() error = %v", err)
+	}
+
+	assertFileContent(t, hostsFile, original)
+
+	files, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to read temp dir: %v", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("Dry run should not create backups, found %d files", len(files))
+	}
+
+	preview := store.Preview()
+	if preview == nil || !preview.HasChanges() {
+		t.Fatal("Expected a preview with changes")
+	}
+	if !strings.Contains(preview.Diff, "+10.0.0.1\tapi.local\n") {
+		t.Errorf("Preview diff = %q", preview.Diff)
+	}
+	if len(preview.Removed) != 0 || len(preview.Added) != 4 {
+		t.Errorf("Expected 4 added and 0 removed lines, got %v and %v", preview.Added, preview.Removed)
+	}
+}
@@ -0,0 +1,134 @@
+package hosts
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"time"
+)
+
+// maxUpdateAttempts is how many times Update applies an operation before
+// giving up on a hosts file that keeps being modified by other programs.
+const maxUpdateAttempts = 3
+
+// ErrConcurrentModification is returned by Save when the hosts file was
+// changed by another program (an editor, NetworkManager, Docker, ...) since
+// it was loaded. Saving would silently discard that change.
+var ErrConcurrentModification = errors.New("hosts file was modified by another program since it was loaded")
+
+// ErrNoChanges can be returned by the function given to Update to leave the
+// hosts file untouched.
+var ErrNoChanges = errors.New("no changes")
+
+// Fingerprint identifies the state of the hosts file on disk when it was
+// loaded. Only a change of content counts as a modification; the other
+// fields describe the file in error messages.
+type Fingerprint struct {
+	Inode   uint64    `json:"inode"`
+	ModTime time.Time `json:"mod_time"`
+	Size    int64     `json:"size"`
+	SHA256  string    `json:"sha256"`
+}
+
+// newFingerprint builds the fingerprint of a file from its metadata and content.
+func newFingerprint(info os.FileInfo, data []byte) Fingerprint {
+	sum := sha256.Sum256(data)
+	fingerprint := Fingerprint{
+		ModTime: info.ModTime(),
+		Size:    info.Size(),
+		SHA256:  hex.EncodeToString(sum[:]),
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		fingerprint.Inode = uint64(stat.Ino) // #nosec G115 -- inode numbers are never negative
+	}
+	return fingerprint
+}
+
+// readFingerprint reads the file at path and returns its content and fingerprint.
+func readFingerprint(path string) ([]byte, Fingerprint, error) {
+	file, err := os.Open(path) // #nosec G304 -- hosts file path
+	if err != nil {
+		return nil, Fingerprint{}, err
+	}
+	defer func() { _ = file.Close() }()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, Fingerprint{}, err
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, Fingerprint{}, err
+	}
+	return data, newFingerprint(info, data), nil
+}
+
+// Fingerprint returns the fingerprint of the hosts file as of the last Load
+// or Save, or nil if the file was neither loaded nor saved through this store.
+func (s *Store) Fingerprint() *Fingerprint {
+	return s.fingerprint
+}
+
+// checkUnmodified returns ErrConcurrentModification if the content of the
+// hosts file differs from when it was loaded.
+func (s *Store) checkUnmodified() error {
+	if s.fingerprint == nil {
+		return nil
+	}
+
+	_, current, err := readFingerprint(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to check hosts file for changes: %w", err)
+	}
+
+	if current.SHA256 != s.fingerprint.SHA256 {
+		return fmt.Errorf("%w: %s changed at %s (%d -> %d bytes)", ErrConcurrentModification,
+			s.path, current.ModTime.Format(time.RFC3339), s.fingerprint.Size, current.Size)
+	}
+	return nil
+}
+
+// Update loads the hosts file, applies fn to it and saves the result. If
+// another program modifies the file in between, the change is not clobbered:
+// fn is applied again to the fresh content, up to maxUpdateAttempts times.
+// fn must therefore only depend on the HostsFile it is given. If fn returns
+// ErrNoChanges, the file is left untouched and Update returns nil.
+func (s *Store) Update(fn func(*HostsFile) error) error {
+	return s.UpdateContext(context.Background(), fn)
+}
+
+// UpdateContext is like Update but loads and saves the hosts file with
+// LoadContext and SaveContext, so that it gives up if ctx is canceled.
+func (s *Store) UpdateContext(ctx context.Context, fn func(*HostsFile) error) error {
+	var err error
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		var hostsFile *HostsFile
+		hostsFile, err = s.LoadContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to load hosts file: %w", err)
+		}
+
+		if err := fn(hostsFile); err != nil {
+			if errors.Is(err, ErrNoChanges) {
+				return nil
+			}
+			return err
+		}
+
+		err = s.SaveContext(ctx, hostsFile)
+		if !errors.Is(err, ErrConcurrentModification) {
+			break
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to save hosts file: %w", err)
+	}
+	return nil
+}
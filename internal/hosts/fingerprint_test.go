@@ -0,0 +1,152 @@
+package hosts
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStore_SaveDetectsConcurrentModification(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-fingerprint-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	if err := os.WriteFile(hostsFile, []byte("127.0.0.1\tlocalhost\n"), 0644); err != nil {
+		t.Fatalf("Failed to create hosts file: %v", err)
+	}
+
+	store := NewStore(hostsFile, false)
+	hostsData, err := store.Load()
+	if err != nil {
+		t.Fatalf("Store.Load() error = %v", err)
+	}
+	hostsData.AddEntry(Entry{IP: "10.0.0.1", Names: []string{"api.local"}, Block: DefaultBlock})
+
+	// Another program rewrites the file in the meantime
+	external := "127.0.0.1\tlocalhost\n172.17.0.2\tcontainer\n"
+	if err := os.WriteFile(hostsFile, []byte(external), 0644); err != nil {
+		t.Fatalf("Failed to modify hosts file: %v", err)
+	}
+
+	err = store.Save(hostsData)
+	if !errors.Is(err, ErrConcurrentModification) {
+		t.Fatalf("Store.Save() error = %v, want ErrConcurrentModification", err)
+	}
+
+	assertFileContent(t, hostsFile, external)
+
+	// The refused save takes no snapshot
+	backups, err := store.ListBackups()
+	if err != nil {
+		t.Fatalf("Store.ListBackups() error = %v", err)
+	}
+	if len(backups) != 0 {
+		t.Errorf("Refused save should not create a backup, got %d", len(backups))
+	}
+}
+
+func TestStore_SaveTwiceAfterLoad(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-fingerprint-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	if err := os.WriteFile(hostsFile, []byte("127.0.0.1\tlocalhost\n"), 0644); err != nil {
+		t.Fatalf("Failed to create hosts file: %v", err)
+	}
+
+	store := NewStore(hostsFile, false)
+	hostsData, err := store.Load()
+	if err != nil {
+		t.Fatalf("Store.Load() error = %v", err)
+	}
+
+	hostsData.AddEntry(Entry{IP: "10.0.0.1", Names: []string{"api.local"}, Block: DefaultBlock})
+	if err := store.Save(hostsData); err != nil {
+		t.Fatalf("first Store.Save() error = %v", err)
+	}
+
+	// The store's own write must not count as an external modification
+	hostsData.AddEntry(Entry{IP: "10.0.0.2", Names: []string{"web.local"}, Block: DefaultBlock})
+	if err := store.Save(hostsData); err != nil {
+		t.Fatalf("second Store.Save() error = %v", err)
+	}
+}
+
+func TestStore_UpdateReappliesOnFreshContent(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-fingerprint-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	if err := os.WriteFile(hostsFile, []byte("127.0.0.1\tlocalhost\n"), 0644); err != nil {
+		t.Fatalf("Failed to create hosts file: %v", err)
+	}
+
+	store := NewStore(hostsFile, false)
+	calls := 0
+	err = store.Update(func(hostsData *HostsFile) error {
+		calls++
+		if calls == 1 {
+			// Simulate another program writing between Load and Save
+			if err := os.WriteFile(hostsFile, []byte("127.0.0.1\tlocalhost\n172.17.0.2\tcontainer\n"), 0644); err != nil {
+				t.Fatalf("Failed to modify hosts file: %v", err)
+			}
+		}
+		hostsData.AddEntry(Entry{IP: "10.0.0.1", Names: []string{"api.local"}, Block: DefaultBlock})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Store.Update() error = %v", err)
+	}
+
+	if calls != 2 {
+		t.Errorf("Expected the operation to be applied twice, got %d", calls)
+	}
+
+	data, err := os.ReadFile(hostsFile)
+	if err != nil {
+		t.Fatalf("Failed to read hosts file: %v", err)
+	}
+	if !strings.Contains(string(data), "172.17.0.2\tcontainer") || !strings.Contains(string(data), "10.0.0.1\tapi.local") {
+		t.Errorf("Expected both the external change and the new entry, got %q", string(data))
+	}
+}
+
+func TestStore_UpdateNoChanges(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-fingerprint-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	if err := os.WriteFile(hostsFile, []byte("127.0.0.1\tlocalhost\n"), 0644); err != nil {
+		t.Fatalf("Failed to create hosts file: %v", err)
+	}
+
+	store := NewStore(hostsFile, false)
+	err = store.Update(func(hostsData *HostsFile) error {
+		return ErrNoChanges
+	})
+	if err != nil {
+		t.Fatalf("Store.Update() error = %v", err)
+	}
+
+	backups, err := store.ListBackups()
+	if err != nil {
+		t.Fatalf("ListBackups() error = %v", err)
+	}
+	if len(backups) != 0 {
+		t.Errorf("Expected no backup when nothing changed, got %d", len(backups))
+	}
+}
@@ -0,0 +1,173 @@
+package hosts
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/vaxvhbe/hostsctl/pkg"
+)
+
+// FragmentExt is the file extension of hosts.d fragment files.
+const FragmentExt = ".hosts"
+
+// fragmentBlockPrefix prefixes the managed block names of compiled fragments.
+const fragmentBlockPrefix = "fragment:"
+
+// FragmentDir is a directory of hosts file fragments (such as /etc/hosts.d)
+// that are compiled into a single hosts file. Each fragment is a plain hosts
+// file named "<name>.hosts"; fragments are compiled in lexical order, each into
+// its own managed block, so that tools can drop files into the directory
+// without racing on one shared file.
+type FragmentDir struct {
+	path   string  // Path to the fragment directory
+	parser *Parser // Parser instance for reading/writing fragments
+}
+
+// NewFragmentDir creates a new FragmentDir for the specified directory.
+// The strict parameter controls whether parsing errors should fail or be skipped.
+func NewFragmentDir(path string, strict bool) *FragmentDir {
+	return &FragmentDir{
+		path:   path,
+		parser: NewParser(strict),
+	}
+}
+
+// FragmentBlock returns the name of the managed block a fragment is compiled into.
+func FragmentBlock(name string) string {
+	return fragmentBlockPrefix + strings.TrimSuffix(name, FragmentExt)
+}
+
+// IsFragmentBlock reports whether a managed block was compiled from a fragment.
+func IsFragmentBlock(block string) bool {
+	return strings.HasPrefix(block, fragmentBlockPrefix)
+}
+
+// Path returns the file system path of the named fragment. The ".hosts"
+// extension is added if the name does not already carry it.
+func (d *FragmentDir) Path(name string) (string, error) {
+	name = strings.TrimSuffix(name, FragmentExt)
+	if name == "" {
+		return "", fmt.Errorf("fragment name cannot be empty")
+	}
+	if strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid fragment name: %s", name)
+	}
+
+	path := filepath.Join(d.path, name+FragmentExt)
+	if err := pkg.ValidateSecurePath(path); err != nil {
+		return "", fmt.Errorf("invalid fragment path: %w", err)
+	}
+	return path, nil
+}
+
+// List returns the names of the fragments in the directory, without their
+// extension, in the lexical order they are compiled in.
+func (d *FragmentDir) List() ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(d.path, "*"+FragmentExt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fragments: %w", err)
+	}
+
+	var names []string
+	for _, match := range matches {
+		base := filepath.Base(match)
+		if strings.HasPrefix(base, ".") {
+			continue
+		}
+		if stat, err := os.Stat(match); err != nil || !stat.Mode().IsRegular() {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(base, FragmentExt))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Load reads and parses the named fragment. A fragment that does not exist
+// yet is returned empty so that entries can be added to it.
+func (d *FragmentDir) Load(name string) (*HostsFile, error) {
+	path, err := d.Path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path) // #nosec G304 -- path validated by Path
+	if os.IsNotExist(err) {
+		return &HostsFile{Path: path}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open fragment: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	hostsFile, err := d.parser.Parse(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse fragment %s: %w", name, err)
+	}
+
+	hostsFile.Path = path
+	return hostsFile, nil
+}
+
+// Save atomically writes a fragment, creating the directory if needed.
+func (d *FragmentDir) Save(name string, hostsFile *HostsFile) error {
+	path, err := d.Path(name)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(d.path, 0755); err != nil {
+		return fmt.Errorf("failed to create fragment directory: %w", err)
+	}
+
+	if err := replaceFile(context.Background(), path, d.parser.Serialize(hostsFile)); err != nil {
+		return fmt.Errorf("failed to write fragment %s: %w", name, err)
+	}
+	return nil
+}
+
+// Compile assembles every fragment into the hosts file managed by store.
+// Each fragment replaces the contents of its own managed block; blocks of
+// fragments that no longer exist are removed. Fragment blocks are written in
+// lexical order at the end of the file, after any other content.
+// Returns the names of the compiled fragments.
+func (d *FragmentDir) Compile(store *Store) ([]string, error) {
+	return d.CompileContext(context.Background(), store)
+}
+
+// CompileContext is like Compile but updates the hosts file with
+// Store.UpdateContext, so that it gives up if ctx is canceled.
+func (d *FragmentDir) CompileContext(ctx context.Context, store *Store) ([]string, error) {
+	names, err := d.List()
+	if err != nil {
+		return nil, err
+	}
+
+	fragments := make([]*HostsFile, len(names))
+	for i, name := range names {
+		if fragments[i], err = d.Load(name); err != nil {
+			return nil, err
+		}
+	}
+
+	err = store.UpdateContext(ctx, func(hostsFile *HostsFile) error {
+		for _, block := range hostsFile.Blocks() {
+			if IsFragmentBlock(block) {
+				hostsFile.RemoveBlock(block)
+			}
+		}
+
+		for i, name := range names {
+			hostsFile.ReplaceBlock(FragmentBlock(name), fragments[i].Entries)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
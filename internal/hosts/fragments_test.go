@@ -0,0 +1,120 @@
+package hosts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFragmentDir_Compile(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-fragments-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsPath := filepath.Join(tmpDir, "hosts")
+	fragmentPath := filepath.Join(tmpDir, "hosts.d")
+	if err := os.Mkdir(fragmentPath, 0755); err != nil {
+		t.Fatalf("Failed to create fragment dir: %v", err)
+	}
+
+	files := map[string]string{
+		"hosts":                "127.0.0.1\tlocalhost\n",
+		"hosts.d/50-dev.hosts": "10.0.0.5\tapi.dev\n",
+		"hosts.d/10-base.hosts": "# base services\n" +
+			"10.0.0.1\tgateway.lan\n",
+		"hosts.d/notes.txt": "10.0.0.9\tignored.lan\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	fragments := NewFragmentDir(fragmentPath, false)
+	store := NewStore(hostsPath, false)
+
+	names, err := fragments.Compile(store)
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	if len(names) != 2 || names[0] != "10-base" || names[1] != "50-dev" {
+		t.Errorf("Compile() fragments = %v, want [10-base 50-dev]", names)
+	}
+
+	expected := "127.0.0.1\tlocalhost\n" +
+		"\n" +
+		"# BEGIN hostsctl fragment:10-base\n" +
+		"10.0.0.1\tgateway.lan\n" +
+		"# END hostsctl fragment:10-base\n" +
+		"\n" +
+		"# BEGIN hostsctl fragment:50-dev\n" +
+		"10.0.0.5\tapi.dev\n" +
+		"# END hostsctl fragment:50-dev\n"
+	assertFileContent(t, hostsPath, expected)
+
+	// A new fragment sorting between existing ones keeps the lexical order,
+	// and deleted fragments disappear from the hosts file.
+	if err := os.WriteFile(filepath.Join(fragmentPath, "20-db.hosts"), []byte("10.0.0.2\tdb.lan\n"), 0644); err != nil {
+		t.Fatalf("Failed to write fragment: %v", err)
+	}
+	if err := os.Remove(filepath.Join(fragmentPath, "50-dev.hosts")); err != nil {
+		t.Fatalf("Failed to remove fragment: %v", err)
+	}
+
+	if _, err := fragments.Compile(store); err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	expected = "127.0.0.1\tlocalhost\n" +
+		"\n" +
+		"# BEGIN hostsctl fragment:10-base\n" +
+		"10.0.0.1\tgateway.lan\n" +
+		"# END hostsctl fragment:10-base\n" +
+		"\n" +
+		"# BEGIN hostsctl fragment:20-db\n" +
+		"10.0.0.2\tdb.lan\n" +
+		"# END hostsctl fragment:20-db\n"
+	assertFileContent(t, hostsPath, expected)
+}
+
+func TestFragmentDir_Path(t *testing.T) {
+	fragments := NewFragmentDir("/etc/hosts.d", false)
+
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{"10-base", "/etc/hosts.d/10-base.hosts", false},
+		{"10-base.hosts", "/etc/hosts.d/10-base.hosts", false},
+		{"", "", true},
+		{"../passwd", "", true},
+		{".hidden", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := fragments.Path(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Path(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Path(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func assertFileContent(t *testing.T, path, expected string) {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	if string(data) != expected {
+		t.Errorf("%s = %q, want %q", path, string(data), expected)
+	}
+}
@@ -0,0 +1,126 @@
+package hosts
+
+import (
+	"slices"
+
+	"github.com/vaxvhbe/hostsctl/pkg"
+)
+
+// entryIndex holds lookup tables over HostsFile.Entries so that finding,
+// adding and removing entries does not require scanning the whole file.
+// It is built lazily on first use and kept in sync by the HostsFile methods.
+type entryIndex struct {
+	byID   map[int]int      // Entry ID -> position in Entries
+	byName map[string][]int // Hostname -> IDs of the entries carrying it, in file order
+	byIP   map[string][]int // Normalized IP -> IDs of the entries using it, in file order
+
+	size  int    // len(Entries) when the index was last synced
+	first *Entry // &Entries[0] when the index was last synced
+}
+
+// index returns the lookup tables for the file's entries, rebuilding them if
+// the Entries slice was replaced or appended to without going through the
+// HostsFile methods.
+func (h *HostsFile) index() *entryIndex {
+	if h.idx == nil || !h.idx.matches(h.Entries) {
+		h.Reindex()
+	}
+	return h.idx
+}
+
+// Reindex rebuilds the lookup tables used by FindByID, FindByName and FindByIP.
+// It only needs to be called after changing the IP or hostnames of an entry in
+// place; the HostsFile methods keep the tables up to date themselves.
+func (h *HostsFile) Reindex() {
+	idx := &entryIndex{
+		byID:   make(map[int]int, len(h.Entries)),
+		byName: make(map[string][]int, len(h.Entries)),
+		byIP:   make(map[string][]int, len(h.Entries)),
+	}
+	for i := range h.Entries {
+		idx.insert(&h.Entries[i], i)
+	}
+	idx.sync(h.Entries)
+	h.idx = idx
+}
+
+// matches reports whether the index still describes the given entries slice.
+func (idx *entryIndex) matches(entries []Entry) bool {
+	if idx.size != len(entries) {
+		return false
+	}
+	return len(entries) == 0 || idx.first == &entries[0]
+}
+
+// sync records the shape of the entries slice the index describes.
+func (idx *entryIndex) sync(entries []Entry) {
+	idx.size = len(entries)
+	idx.first = nil
+	if len(entries) > 0 {
+		idx.first = &entries[0]
+	}
+}
+
+// insert adds an entry stored at the given position to the index.
+func (idx *entryIndex) insert(entry *Entry, pos int) {
+	idx.byID[entry.ID] = pos
+	for _, name := range entry.Names {
+		idx.byName[name] = append(idx.byName[name], entry.ID)
+	}
+	ip := pkg.NormalizeIP(entry.IP)
+	idx.byIP[ip] = append(idx.byIP[ip], entry.ID)
+}
+
+// delete removes an entry from the index.
+func (idx *entryIndex) delete(entry *Entry) {
+	delete(idx.byID, entry.ID)
+	for _, name := range entry.Names {
+		idx.byName[name] = deleteID(idx.byName[name], entry.ID)
+		if len(idx.byName[name]) == 0 {
+			delete(idx.byName, name)
+		}
+	}
+	ip := pkg.NormalizeIP(entry.IP)
+	idx.byIP[ip] = deleteID(idx.byIP[ip], entry.ID)
+	if len(idx.byIP[ip]) == 0 {
+		delete(idx.byIP, ip)
+	}
+}
+
+// deleteID removes the first occurrence of id from ids.
+func deleteID(ids []int, id int) []int {
+	if i := slices.Index(ids, id); i >= 0 {
+		return slices.Delete(ids, i, i+1)
+	}
+	return ids
+}
+
+// entries resolves entry IDs to pointers into the entries slice.
+func (h *HostsFile) entries(ids []int) []*Entry {
+	if len(ids) == 0 {
+		return nil
+	}
+	results := make([]*Entry, 0, len(ids))
+	for _, id := range ids {
+		results = append(results, &h.Entries[h.idx.byID[id]])
+	}
+	return results
+}
+
+// FindByIP returns pointers to all entries for the given IP address.
+// Different spellings of the same IPv6 address are considered equal.
+func (h *HostsFile) FindByIP(ip string) []*Entry {
+	return h.entries(h.index().byIP[pkg.NormalizeIP(ip)])
+}
+
+// DuplicateNames returns the hostnames that appear in more than one entry,
+// mapped to the IDs of those entries, in file order.
+func (h *HostsFile) DuplicateNames() map[string][]int {
+	duplicates := make(map[string][]int)
+	for name, ids := range h.index().byName {
+		if len(ids) > 1 {
+			duplicates[name] = slices.Clone(ids)
+		}
+	}
+	return duplicates
+}
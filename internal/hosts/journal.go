@@ -0,0 +1,248 @@
+package hosts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The operation journal, "<hosts file name>.hostsctl.journal.json" in the
+// backup directory, records every Save so that it can be undone and redone.
+// The content before and after each operation is referenced by the SHA-256
+// of the snapshot holding it.
+const (
+	journalSuffix = "journal.json"
+	journalLimit  = 50
+)
+
+// ErrNothingToUndo and ErrNothingToRedo are returned by Undo and Redo when
+// the journal has no operation to step over.
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	ErrNothingToRedo = errors.New("nothing to redo")
+)
+
+// Journal is the on-disk history of the operations applied to a hosts file.
+type Journal struct {
+	Operations []Operation `json:"operations"` // Oldest first
+	Position   int         `json:"position"`   // Number of operations currently applied
+}
+
+// Operation is one change to the hosts file recorded in the journal.
+type Operation struct {
+	Name      string       `json:"name"`                // Operation, e.g. "add" or "profile apply"
+	Arguments []string     `json:"arguments,omitempty"` // Command line arguments of the operation
+	Time      time.Time    `json:"time"`                // When the operation was applied
+	Before    JournalState `json:"before"`              // Content before the operation
+	After     JournalState `json:"after"`               // Content after the operation
+}
+
+// JournalState identifies the content of the hosts file before or after an
+// operation.
+type JournalState struct {
+	SHA256   string `json:"sha256"`             // Fingerprint of the content
+	Snapshot string `json:"snapshot,omitempty"` // Snapshot file holding the content, once known
+}
+
+// SetOperation sets the operation recorded in the journal by Save.
+func (s *Store) SetOperation(name string, arguments []string) {
+	s.operation = name
+	s.arguments = arguments
+}
+
+// Journal returns the operation journal of the hosts file.
+func (s *Store) Journal() (*Journal, error) {
+	journal := &Journal{}
+
+	data, err := os.ReadFile(s.journalPath())
+	if os.IsNotExist(err) {
+		return journal, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	if err := json.Unmarshal(data, journal); err != nil {
+		return nil, fmt.Errorf("failed to parse journal: %w", err)
+	}
+	if journal.Position < 0 || journal.Position > len(journal.Operations) {
+		journal.Position = len(journal.Operations)
+	}
+	return journal, nil
+}
+
+// Undo reverts the most recent applied operation by writing back the content
+// it replaced. It refuses to do so if the hosts file was changed since that
+// operation, by another program or outside the journal.
+func (s *Store) Undo() (*Operation, error) {
+	return s.UndoContext(context.Background())
+}
+
+// UndoContext is like Undo but gives up if ctx is canceled before the hosts
+// file is replaced (see SaveContext).
+func (s *Store) UndoContext(ctx context.Context) (*Operation, error) {
+	journal, err := s.Journal()
+	if err != nil {
+		return nil, err
+	}
+	if journal.Position == 0 {
+		return nil, ErrNothingToUndo
+	}
+
+	op := &journal.Operations[journal.Position-1]
+	backup, err := s.replay(ctx, op, op.After, op.Before, "undo")
+	if err != nil || backup == nil {
+		return op, err
+	}
+
+	op.After.Snapshot = filepath.Base(backup.Path)
+	journal.Position--
+	return op, s.finishReplay(ctx, journal)
+}
+
+// Redo applies again the most recently undone operation. It refuses to do so
+// if the hosts file was changed since that operation was undone.
+func (s *Store) Redo() (*Operation, error) {
+	return s.RedoContext(context.Background())
+}
+
+// RedoContext is like Redo but gives up if ctx is canceled before the hosts
+// file is replaced (see SaveContext).
+func (s *Store) RedoContext(ctx context.Context) (*Operation, error) {
+	journal, err := s.Journal()
+	if err != nil {
+		return nil, err
+	}
+	if journal.Position == len(journal.Operations) {
+		return nil, ErrNothingToRedo
+	}
+
+	op := &journal.Operations[journal.Position]
+	backup, err := s.replay(ctx, op, op.Before, op.After, "redo")
+	if err != nil || backup == nil {
+		return op, err
+	}
+
+	op.Before.Snapshot = filepath.Base(backup.Path)
+	journal.Position++
+	return op, s.finishReplay(ctx, journal)
+}
+
+// replay replaces the content from by the snapshot of to, after checking that
+// the hosts file still holds from. It returns the backup of the replaced
+// content, or nil in dry-run mode.
+func (s *Store) replay(ctx context.Context, op *Operation, from, to JournalState, action string) (*BackupInfo, error) {
+	_, current, err := readFingerprint(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hosts file: %w", err)
+	}
+	if current.SHA256 != from.SHA256 {
+		return nil, fmt.Errorf("cannot %s '%s' from %s: the hosts file was modified since", action, op.Name, op.Time.Format(time.RFC3339))
+	}
+	s.fingerprint = &current
+
+	if to.Snapshot == "" {
+		return nil, fmt.Errorf("cannot %s '%s': no snapshot of the content was recorded", action, op.Name)
+	}
+
+	snapshot := filepath.Join(s.BackupDir(), to.Snapshot)
+	if !fileExists(snapshot) {
+		return nil, fmt.Errorf("cannot %s '%s': snapshot %s was pruned", action, op.Name, to.Snapshot)
+	}
+
+	data, err := s.ReadBackup(snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	hostsFile, err := s.parser.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot: %w", err)
+	}
+	hostsFile.Path = s.path
+
+	return s.save(ctx, hostsFile)
+}
+
+// finishReplay saves the journal after an undo or redo and prunes backups.
+// The hosts file is already written, so the journal is saved even if ctx is
+// canceled meanwhile.
+func (s *Store) finishReplay(ctx context.Context, journal *Journal) error {
+	if err := s.saveJournal(context.WithoutCancel(ctx), journal); err != nil {
+		return err
+	}
+
+	// The hosts file is already written: failing to prune must not report the undo as failed.
+	_, _ = s.PruneBackups(false)
+	return nil
+}
+
+// recordOperation appends the operation just saved to the journal, dropping
+// any operations that were undone. backup is the snapshot taken before it.
+func (s *Store) recordOperation(ctx context.Context, backup *BackupInfo) error {
+	if s.fingerprint == nil || backup.SHA256 == "" || backup.SHA256 == s.fingerprint.SHA256 {
+		return nil
+	}
+
+	journal, err := s.Journal()
+	if err != nil {
+		return err
+	}
+
+	journal.Operations = append(journal.Operations[:journal.Position], Operation{
+		Name:      s.operation,
+		Arguments: s.arguments,
+		Time:      time.Now(),
+		Before:    JournalState{SHA256: backup.SHA256, Snapshot: filepath.Base(backup.Path)},
+		After:     JournalState{SHA256: s.fingerprint.SHA256},
+	})
+	if len(journal.Operations) > journalLimit {
+		journal.Operations = journal.Operations[len(journal.Operations)-journalLimit:]
+	}
+	journal.Position = len(journal.Operations)
+
+	return s.saveJournal(ctx, journal)
+}
+
+// journalSnapshots returns the names of the snapshots referenced by the
+// operation journal.
+func (s *Store) journalSnapshots() (map[string]bool, error) {
+	journal, err := s.Journal()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make(map[string]bool)
+	for _, op := range journal.Operations {
+		for _, state := range []JournalState{op.Before, op.After} {
+			if state.Snapshot != "" {
+				snapshots[state.Snapshot] = true
+			}
+		}
+	}
+	return snapshots, nil
+}
+
+// journalPath returns the path of the operation journal of the hosts file.
+func (s *Store) journalPath() string {
+	return filepath.Join(s.BackupDir(), filepath.Base(s.path)+backupInfix+journalSuffix)
+}
+
+// saveJournal atomically writes the operation journal, unless ctx is canceled
+// first.
+func (s *Store) saveJournal(ctx context.Context, journal *Journal) error {
+	data, err := json.MarshalIndent(journal, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal journal: %w", err)
+	}
+
+	if err := replaceFile(ctx, s.journalPath(), string(data)+"\n"); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	return nil
+}
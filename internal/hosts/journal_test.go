@@ -0,0 +1,202 @@
+package hosts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStore_UndoRedo(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-journal-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	original := "127.0.0.1\tlocalhost\n"
+	if err := os.WriteFile(hostsFile, []byte(original), 0644); err != nil {
+		t.Fatalf("Failed to create hosts file: %v", err)
+	}
+
+	store := NewStore(hostsFile, false)
+	store.SetOperation("add", []string{"--ip", "10.0.0.1"})
+
+	add := func(ip, name string) string {
+		err := store.Update(func(hostsData *HostsFile) error {
+			hostsData.AddEntry(Entry{IP: ip, Names: []string{name}, Block: DefaultBlock})
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Store.Update() error = %v", err)
+		}
+		data, err := os.ReadFile(hostsFile)
+		if err != nil {
+			t.Fatalf("Failed to read hosts file: %v", err)
+		}
+		return string(data)
+	}
+
+	first := add("10.0.0.1", "api.local")
+	second := add("10.0.0.2", "web.local")
+
+	op, err := store.Undo()
+	if err != nil {
+		t.Fatalf("Store.Undo() error = %v", err)
+	}
+	if op.Name != "add" {
+		t.Errorf("Expected operation 'add', got %q", op.Name)
+	}
+	assertFileContent(t, hostsFile, first)
+
+	if _, err := store.Undo(); err != nil {
+		t.Fatalf("second Store.Undo() error = %v", err)
+	}
+	assertFileContent(t, hostsFile, original)
+
+	if _, err := store.Undo(); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("Store.Undo() error = %v, want ErrNothingToUndo", err)
+	}
+
+	if _, err := store.Redo(); err != nil {
+		t.Fatalf("Store.Redo() error = %v", err)
+	}
+	assertFileContent(t, hostsFile, first)
+
+	if _, err := store.Redo(); err != nil {
+		t.Fatalf("second Store.Redo() error = %v", err)
+	}
+	assertFileContent(t, hostsFile, second)
+
+	if _, err := store.Redo(); !errors.Is(err, ErrNothingToRedo) {
+		t.Errorf("Store.Redo() error = %v, want ErrNothingToRedo", err)
+	}
+
+	// A new change after an undo discards the undone operations
+	if _, err := store.Undo(); err != nil {
+		t.Fatalf("Store.Undo() error = %v", err)
+	}
+	add("10.0.0.3", "db.local")
+
+	if _, err := store.Redo(); !errors.Is(err, ErrNothingToRedo) {
+		t.Errorf("Store.Redo() after a new change error = %v, want ErrNothingToRedo", err)
+	}
+
+	journal, err := store.Journal()
+	if err != nil {
+		t.Fatalf("Store.Journal() error = %v", err)
+	}
+	if len(journal.Operations) != 2 || journal.Position != 2 {
+		t.Errorf("Expected 2 applied operations, got %d with position %d", len(journal.Operations), journal.Position)
+	}
+}
+
+func TestStore_UndoRefusesExternalModification(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-journal-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	if err := os.WriteFile(hostsFile, []byte("127.0.0.1\tlocalhost\n"), 0644); err != nil {
+		t.Fatalf("Failed to create hosts file: %v", err)
+	}
+
+	store := NewStore(hostsFile, false)
+	err = store.Update(func(hostsData *HostsFile) error {
+		hostsData.AddEntry(Entry{IP: "10.0.0.1", Names: []string{"api.local"}, Block: DefaultBlock})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Store.Update() error = %v", err)
+	}
+
+	external := "127.0.0.1\tlocalhost\n172.17.0.2\tcontainer\n"
+	if err := os.WriteFile(hostsFile, []byte(external), 0644); err != nil {
+		t.Fatalf("Failed to modify hosts file: %v", err)
+	}
+
+	if _, err := store.Undo(); err == nil {
+		t.Error("Store.Undo() should refuse when the hosts file was modified externally")
+	}
+	assertFileContent(t, hostsFile, external)
+}
+
+func TestStore_UndoBeyondRetention(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-journal-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	original := "127.0.0.1\tlocalhost\n"
+	if err := os.WriteFile(hostsFile, []byte(original), 0644); err != nil {
+		t.Fatalf("Failed to create hosts file: %v", err)
+	}
+
+	store := NewStore(hostsFile, false)
+	saves := DefaultRetentionPolicy.KeepLast + 5
+	for i := 0; i < saves; i++ {
+		err := store.Update(func(hostsData *HostsFile) error {
+			hostsData.AddEntry(Entry{IP: fmt.Sprintf("10.0.0.%d", i+1), Names: []string{fmt.Sprintf("host%d.local", i)}, Block: DefaultBlock})
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Store.Update() error = %v", err)
+		}
+	}
+
+	// Pruning keeps the snapshots the journal still needs
+	for i := 0; i < saves; i++ {
+		if _, err := store.Undo(); err != nil {
+			t.Fatalf("Store.Undo() #%d error = %v", i+1, err)
+		}
+	}
+	assertFileContent(t, hostsFile, original)
+}
+
+func TestStore_UndoContextCanceled(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-journal-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	if err := os.WriteFile(hostsFile, []byte("127.0.0.1\tlocalhost\n"), 0644); err != nil {
+		t.Fatalf("Failed to create hosts file: %v", err)
+	}
+
+	store := NewStore(hostsFile, false)
+	err = store.Update(func(hostsData *HostsFile) error {
+		hostsData.AddEntry(Entry{IP: "10.0.0.1", Names: []string{"api.local"}, Block: DefaultBlock})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Store.Update() error = %v", err)
+	}
+	changed, err := os.ReadFile(hostsFile)
+	if err != nil {
+		t.Fatalf("Failed to read hosts file: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := store.UndoContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Store.UndoContext() error = %v, want context.Canceled", err)
+	}
+	assertFileContent(t, hostsFile, string(changed))
+
+	journal, err := store.Journal()
+	if err != nil {
+		t.Fatalf("Store.Journal() error = %v", err)
+	}
+	if journal.Position != 1 {
+		t.Errorf("Expected the operation to stay applied, got position %d", journal.Position)
+	}
+}
@@ -0,0 +1,414 @@
+package hosts
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vaxvhbe/hostsctl/pkg"
+)
+
+// Rule is a check run by a Linter on a parsed hosts file. Rules report
+// findings without a rule ID or severity; the linter fills them in from the
+// rule and its configuration, and derives line numbers from entry IDs.
+type Rule struct {
+	ID          string                     `json:"id"`          // Stable identifier used in output and configuration, e.g. "duplicate-hostname"
+	Severity    Severity                   `json:"severity"`    // Severity of the findings
+	Description string                     `json:"description"` // What the rule checks
+	Enabled     bool                       `json:"enabled"`     // Whether the rule runs
+	Check       func(*HostsFile) []Finding `json:"-"`           // Returns the problems found in the file
+}
+
+// Finding is a problem reported by a lint rule.
+type Finding struct {
+	Rule     string   `json:"rule"`               // ID of the rule that reported it
+	Severity Severity `json:"severity"`           // Severity configured for the rule
+	Line     int      `json:"line,omitempty"`     // Line number (1-based, 0 if not tied to a line)
+	Column   int      `json:"column,omitempty"`   // Column number (1-based, 0 if not known)
+	EntryID  int      `json:"entry_id,omitempty"` // Entry concerned, if any
+	Message  string   `json:"message"`            // Human-readable description of the problem
+	Fix      string   `json:"fix,omitempty"`      // Suggested fix, if any
+}
+
+// String returns a one-line description of the finding, e.g.
+// "line 3, entry 1234: error: invalid IP address: 999.0.0.1 [invalid-ip]".
+func (f Finding) String() string {
+	location := f.Location()
+	if location != "" {
+		location += ": "
+	}
+	return fmt.Sprintf("%s%s: %s [%s]", location, f.Severity, f.Message, f.Rule)
+}
+
+// Location describes where the finding is, e.g. "line 3, entry 1234", or
+// returns "" if it is not tied to a line or entry.
+func (f Finding) Location() string {
+	var parts []string
+	if f.Line > 0 {
+		parts = append(parts, fmt.Sprintf("line %d", f.Line))
+	}
+	if f.Column > 0 {
+		parts = append(parts, fmt.Sprintf("column %d", f.Column))
+	}
+	if f.EntryID != 0 {
+		parts = append(parts, fmt.Sprintf("entry %d", f.EntryID))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ParseSeverity parses a finding severity: error, warning or info.
+func ParseSeverity(severity string) (Severity, error) {
+	switch Severity(severity) {
+	case SeverityError, SeverityWarning, SeverityInfo:
+		return Severity(severity), nil
+	default:
+		return "", fmt.Errorf("unknown severity '%s' (expected %s, %s or %s)", severity, SeverityError, SeverityWarning, SeverityInfo)
+	}
+}
+
+// Linter runs a set of rules on hosts files.
+type Linter struct {
+	rules []Rule
+}
+
+// NewLinter creates a Linter with the rules returned by DefaultRules.
+func NewLinter() *Linter {
+	return &Linter{rules: DefaultRules()}
+}
+
+// Register adds a rule to the linter. Rule IDs must be unique.
+func (l *Linter) Register(rule Rule) error {
+	if rule.ID == "" || rule.Check == nil {
+		return fmt.Errorf("lint rule needs an ID and a check")
+	}
+	if _, err := ParseSeverity(string(rule.Severity)); err != nil {
+		return fmt.Errorf("lint rule %s: %w", rule.ID, err)
+	}
+	if l.find(rule.ID) != nil {
+		return fmt.Errorf("lint rule %s is already registered", rule.ID)
+	}
+
+	l.rules = append(l.rules, rule)
+	return nil
+}
+
+// Rules returns the registered rules with their current settings, sorted by ID.
+func (l *Linter) Rules() []Rule {
+	rules := append([]Rule(nil), l.rules...)
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].ID < rules[j].ID
+	})
+	return rules
+}
+
+// Rule returns the rule with the given ID, if it is registered.
+func (l *Linter) Rule(id string) (Rule, bool) {
+	rule := l.find(id)
+	if rule == nil {
+		return Rule{}, false
+	}
+	return *rule, true
+}
+
+// Enable enables or disables the rule with the given ID.
+func (l *Linter) Enable(id string, enabled bool) error {
+	rule := l.find(id)
+	if rule == nil {
+		return fmt.Errorf("unknown lint rule '%s'", id)
+	}
+	rule.Enabled = enabled
+	return nil
+}
+
+// SetSeverity changes the severity of the findings of the rule with the given ID.
+func (l *Linter) SetSeverity(id string, severity Severity) error {
+	rule := l.find(id)
+	if rule == nil {
+		return fmt.Errorf("unknown lint rule '%s'", id)
+	}
+	if _, err := ParseSeverity(string(severity)); err != nil {
+		return err
+	}
+	rule.Severity = severity
+	return nil
+}
+
+// find returns the rule with the given ID, or nil.
+func (l *Linter) find(id string) *Rule {
+	for i := range l.rules {
+		if l.rules[i].ID == id {
+			return &l.rules[i]
+		}
+	}
+	return nil
+}
+
+// Lint runs the enabled rules on hostsFile and returns their findings,
+// ordered by line number. Findings that are not tied to a line come last.
+func (l *Linter) Lint(hostsFile *HostsFile) []Finding {
+	lines := entryLines(hostsFile)
+
+	var findings []Finding
+	for _, rule := range l.rules {
+		if !rule.Enabled {
+			continue
+		}
+
+		for _, finding := range rule.Check(hostsFile) {
+			finding.Rule = rule.ID
+			finding.Severity = rule.Severity
+			if finding.Line == 0 {
+				finding.Line = lines[finding.EntryID]
+			}
+			findings = append(findings, finding)
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if (a.Line == 0) != (b.Line == 0) {
+			return b.Line == 0
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Rule < b.Rule
+	})
+	return findings
+}
+
+// entryLines maps entry IDs to the line (1-based) they are on.
+func entryLines(hostsFile *HostsFile) map[int]int {
+	lines := make(map[int]int, len(hostsFile.Entries))
+	for i, line := range hostsFile.Lines {
+		if line.Kind == LineEntry {
+			lines[line.EntryID] = i + 1
+		}
+	}
+	return lines
+}
+
+// DefaultRules returns the built-in lint rules. All of them are enabled
+// except unmanaged-entry, which flags every entry of a stock hosts file.
+func DefaultRules() []Rule {
+	return []Rule{
+		{
+			ID:          "syntax-error",
+			Severity:    SeverityError,
+			Description: "Lines that could not be parsed",
+			Enabled:     true,
+			Check:       checkDiagnostics(SeverityError),
+		},
+		{
+			ID:          "parse-warning",
+			Severity:    SeverityWarning,
+			Description: "Lines that were parsed but look wrong, such as unclosed managed blocks",
+			Enabled:     true,
+			Check:       checkDiagnostics(SeverityWarning),
+		},
+		{
+			ID:          "invalid-entry",
+			Severity:    SeverityError,
+			Description: "Entries without an IP address or hostname",
+			Enabled:     true,
+			Check:       checkInvalidEntries,
+		},
+		{
+			ID:          "invalid-ip",
+			Severity:    SeverityError,
+			Description: "Entries whose IP address is not a valid IPv4 or IPv6 address",
+			Enabled:     true,
+			Check:       checkInvalidIPs,
+		},
+		{
+			ID:          "invalid-hostname",
+			Severity:    SeverityError,
+			Description: "Hostnames that do not follow RFC 1123",
+			Enabled:     true,
+			Check:       checkInvalidHostnames,
+		},
+		{
+			ID:          "confusable-hostname",
+			Severity:    SeverityWarning,
+			Description: "Internationalized hostnames with mixed-script or look-alike labels",
+			Enabled:     true,
+			Check:       checkConfusableHostnames,
+		},
+		{
+			ID:          "duplicate-hostname",
+			Severity:    SeverityError,
+			Description: "Hostnames mapped by more than one entry; resolvers only use the first one",
+			Enabled:     true,
+			Check:       checkDuplicateHostnames,
+		},
+		{
+			ID:          "expired-entry",
+			Severity:    SeverityWarning,
+			Description: "Entries whose @expires date has passed",
+			Enabled:     true,
+			Check:       checkExpiredEntries,
+		},
+		{
+			ID:          "unmanaged-entry",
+			Severity:    SeverityInfo,
+			Description: "Entries outside the hostsctl managed blocks",
+			Enabled:     false,
+			Check:       checkUnmanagedEntries,
+		},
+	}
+}
+
+// checkDiagnostics reports the parse diagnostics of the given severity.
+func checkDiagnostics(severity Severity) func(*HostsFile) []Finding {
+	return func(hostsFile *HostsFile) []Finding {
+		var findings []Finding
+		for _, diagnostic := range hostsFile.Diagnostics {
+			if diagnostic.Severity != severity {
+				continue
+			}
+			findings = append(findings, Finding{
+				Line:    diagnostic.Line,
+				Column:  diagnostic.Column,
+				Message: diagnostic.Reason,
+			})
+		}
+		return findings
+	}
+}
+
+// checkInvalidEntries reports entries without an IP address or hostname.
+func checkInvalidEntries(hostsFile *HostsFile) []Finding {
+	var findings []Finding
+	for _, entry := range hostsFile.Entries {
+		if !entry.IsValid() {
+			findings = append(findings, Finding{
+				EntryID: entry.ID,
+				Message: "invalid entry (missing IP or names)",
+			})
+		}
+	}
+	return findings
+}
+
+// checkInvalidIPs reports entries whose IP address is not valid.
+func checkInvalidIPs(hostsFile *HostsFile) []Finding {
+	var findings []Finding
+	for _, entry := range hostsFile.Entries {
+		if entry.IsValid() && !pkg.IsValidIP(entry.IP) {
+			findings = append(findings, Finding{
+				EntryID: entry.ID,
+				Message: "invalid IP address: " + entry.IP,
+			})
+		}
+	}
+	return findings
+}
+
+// checkInvalidHostnames reports hostnames that do not follow RFC 1123.
+func checkInvalidHostnames(hostsFile *HostsFile) []Finding {
+	var findings []Finding
+	for _, entry := range hostsFile.Entries {
+		if !entry.IsValid() {
+			continue
+		}
+		for _, name := range entry.Names {
+			if !isValidHostname(name) {
+				findings = append(findings, Finding{
+					EntryID: entry.ID,
+					Message: "invalid hostname: " + name,
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// checkConfusableHostnames reports hostnames with mixed-script or look-alike labels.
+func checkConfusableHostnames(hostsFile *HostsFile) []Finding {
+	var findings []Finding
+	for _, entry := range hostsFile.Entries {
+		if !entry.IsValid() {
+			continue
+		}
+		for _, name := range entry.Names {
+			for _, problem := range pkg.CheckConfusable(name) {
+				findings = append(findings, Finding{
+					EntryID: entry.ID,
+					Message: fmt.Sprintf("suspicious hostname %s: %s", name, problem),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// checkDuplicateHostnames reports every entry that maps a hostname already
+// mapped by an earlier entry.
+func checkDuplicateHostnames(hostsFile *HostsFile) []Finding {
+	lines := entryLines(hostsFile)
+	duplicates := hostsFile.DuplicateNames()
+
+	names := make([]string, 0, len(duplicates))
+	for name := range duplicates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var findings []Finding
+	for _, name := range names {
+		ids := duplicates[name]
+		for _, id := range ids[1:] {
+			findings = append(findings, Finding{
+				EntryID: id,
+				Message: fmt.Sprintf("duplicate hostname '%s', first mapped by entry %d on line %d", name, ids[0], lines[ids[0]]),
+				Fix:     fmt.Sprintf("remove or disable one of entries %s", joinIDs(ids)),
+			})
+		}
+	}
+	return findings
+}
+
+// checkExpiredEntries reports active entries whose @expires date has passed.
+func checkExpiredEntries(hostsFile *HostsFile) []Finding {
+	now := time.Now()
+
+	var findings []Finding
+	for _, entry := range hostsFile.Entries {
+		if !entry.Disabled && entry.IsExpired(now) {
+			findings = append(findings, Finding{
+				EntryID: entry.ID,
+				Message: fmt.Sprintf("%s expired on %s", strings.Join(entry.Names, " "), entry.Expires.Format(ExpiresLayout)),
+				Fix:     fmt.Sprintf("hostsctl rm --id %d", entry.ID),
+			})
+		}
+	}
+	return findings
+}
+
+// checkUnmanagedEntries reports entries outside the hostsctl managed blocks.
+func checkUnmanagedEntries(hostsFile *HostsFile) []Finding {
+	var findings []Finding
+	for _, entry := range hostsFile.Entries {
+		if !entry.IsManaged() {
+			findings = append(findings, Finding{
+				EntryID: entry.ID,
+				Message: fmt.Sprintf("%s is not managed by hostsctl", strings.Join(entry.Names, " ")),
+				Fix:     fmt.Sprintf("hostsctl adopt --id %d", entry.ID),
+			})
+		}
+	}
+	return findings
+}
+
+// joinIDs formats entry IDs as "1, 2 and 3".
+func joinIDs(ids []int) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = fmt.Sprint(id)
+	}
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return strings.Join(parts[:len(parts)-1], ", ") + " and " + parts[len(parts)-1]
+}
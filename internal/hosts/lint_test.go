@@ -0,0 +1,109 @@
+package hosts
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLinter_Lint(t *testing.T) {
+	content := "127.0.0.1\tlocalhost\n" +
+		"999.999.999.999\tinvalid.local\n" +
+		"# BEGIN hostsctl\n" +
+		"10.0.0.1\tapi.local\t# @expires=2000-01-01\n" +
+		"10.0.0.2\tapi.local\n" +
+		"# END hostsctl\n"
+
+	hostsFile, err := NewParser(false).Parse(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	linter := NewLinter()
+	findings := linter.Lint(hostsFile)
+
+	want := []struct {
+		rule     string
+		severity Severity
+		line     int
+	}{
+		{"syntax-error", SeverityError, 2},
+		{"expired-entry", SeverityWarning, 4},
+		{"duplicate-hostname", SeverityError, 5},
+	}
+	if len(findings) != len(want) {
+		t.Fatalf("Lint() = %v, want %d findings", findings, len(want))
+	}
+	for i, w := range want {
+		if findings[i].Rule != w.rule || findings[i].Severity != w.severity || findings[i].Line != w.line {
+			t.Errorf("Finding %d = %+v, want rule %s, severity %s on line %d", i, findings[i], w.rule, w.severity, w.line)
+		}
+	}
+	if findings[2].Fix == "" {
+		t.Error("Expected duplicate-hostname to suggest a fix")
+	}
+
+	// Rules can be disabled, enabled and given another severity
+	if err := linter.Enable("expired-entry", false); err != nil {
+		t.Fatalf("Enable() error = %v", err)
+	}
+	if err := linter.Enable("unmanaged-entry", true); err != nil {
+		t.Fatalf("Enable() error = %v", err)
+	}
+	if err := linter.SetSeverity("duplicate-hostname", SeverityInfo); err != nil {
+		t.Fatalf("SetSeverity() error = %v", err)
+	}
+
+	counts := make(map[string]int)
+	for _, finding := range linter.Lint(hostsFile) {
+		counts[finding.Rule]++
+		if finding.Rule == "duplicate-hostname" && finding.Severity != SeverityInfo {
+			t.Errorf("Expected duplicate-hostname findings to have severity info, got %s", finding.Severity)
+		}
+	}
+	if counts["expired-entry"] != 0 {
+		t.Error("Disabled rule expired-entry should report nothing")
+	}
+	if counts["unmanaged-entry"] != 1 {
+		t.Errorf("Expected 1 unmanaged-entry finding, got %d", counts["unmanaged-entry"])
+	}
+
+	if err := linter.Enable("no-such-rule", true); err == nil {
+		t.Error("Enable() should fail for an unknown rule")
+	}
+	if err := linter.SetSeverity("invalid-ip", "fatal"); err == nil {
+		t.Error("SetSeverity() should fail for an unknown severity")
+	}
+}
+
+func TestLinter_Register(t *testing.T) {
+	hostsFile, err := NewParser(false).Parse(strings.NewReader("127.0.0.1\tlocalhost\n"))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	rule := Rule{
+		ID:       "no-localhost",
+		Severity: SeverityWarning,
+		Enabled:  true,
+		Check: func(hostsFile *HostsFile) []Finding {
+			var findings []Finding
+			for _, entry := range hostsFile.Entries {
+				findings = append(findings, Finding{EntryID: entry.ID, Message: "localhost is mapped"})
+			}
+			return findings
+		},
+	}
+
+	linter := NewLinter()
+	if err := linter.Register(rule); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := linter.Register(rule); err == nil {
+		t.Error("Register() should refuse a rule ID that is already registered")
+	}
+
+	findings := linter.Lint(hostsFile)
+	if len(findings) != 1 || findings[0].Rule != "no-localhost" || findings[0].Line != 1 {
+		t.Errorf("Lint() = %+v, want one no-localhost finding on line 1", findings)
+	}
+}
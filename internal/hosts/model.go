@@ -2,19 +2,28 @@
 package hosts
 
 import (
+	"hash/fnv"
+	"sort"
+	"strings"
 	"time"
+
+	"github.com/vaxvhbe/hostsctl/pkg"
 )
 
 // Entry represents a single entry in a hosts file.
 // It can contain an IP address, one or more hostnames, an optional comment,
 // and can be disabled (commented out in the file).
 type Entry struct {
-	ID       int      `json:"id" yaml:"id"`             // Unique identifier for the entry
-	IP       string   `json:"ip" yaml:"ip"`             // IP address (IPv4 or IPv6)
-	Names    []string `json:"names" yaml:"names"`       // List of hostnames for this IP
-	Comment  string   `json:"comment" yaml:"comment"`   // Optional comment
-	Disabled bool     `json:"disabled" yaml:"disabled"` // Whether the entry is commented out
-	Raw      string   `json:"-" yaml:"-"`               // Original raw line from file (not exported)
+	ID       int       `json:"id" yaml:"id"`                              // Unique identifier for the entry
+	IP       string    `json:"ip" yaml:"ip"`                              // IP address (IPv4 or IPv6)
+	Names    []string  `json:"names" yaml:"names"`                        // List of hostnames for this IP
+	Comment  string    `json:"comment" yaml:"comment"`                    // Optional comment
+	Disabled bool      `json:"disabled" yaml:"disabled"`                  // Whether the entry is commented out
+	Tags     []string  `json:"tags,omitempty" yaml:"tags,omitempty"`      // Tags from the @tags annotation
+	Owner    string    `json:"owner,omitempty" yaml:"owner,omitempty"`    // Owner from the @owner annotation
+	Expires  time.Time `json:"expires,omitzero" yaml:"expires,omitempty"` // Expiry date from the @expires annotation
+	Block    string    `json:"block,omitempty" yaml:"block,omitempty"`    // Managed block the entry belongs to ("" if unmanaged)
+	Raw      string    `json:"-" yaml:"-"`                                // Original raw line from file (not exported)
 }
 
 // Profile represents a collection of hosts entries that can be imported/exported.
@@ -28,18 +37,63 @@ type Profile struct {
 	UpdatedAt   time.Time `json:"updated_at" yaml:"updated_at"`   // Last update timestamp
 }
 
+// LineKind identifies what a physical line of a hosts file contains.
+type LineKind int
+
+const (
+	LineBlank      LineKind = iota // Empty or whitespace-only line
+	LineComment                    // Free-standing comment that is not a disabled entry
+	LineEntry                      // Active or disabled hosts entry
+	LineInvalid                    // Line that could not be parsed, kept verbatim
+	LineBlockBegin                 // "# BEGIN hostsctl [name]" marker
+	LineBlockEnd                   // "# END hostsctl [name]" marker
+)
+
+// DefaultBlock is the name of the managed block written with bare
+// "# BEGIN hostsctl" / "# END hostsctl" markers.
+const DefaultBlock = "default"
+
+// IsReservedBlock reports whether a managed block name is reserved for
+// hostsctl itself: the default block and the blocks of compiled fragments.
+func IsReservedBlock(block string) bool {
+	return block == DefaultBlock || IsFragmentBlock(block)
+}
+
+// Line represents a single physical line of a hosts file as it was read.
+// Lines are kept so that serialization can reproduce the original file
+// byte for byte and only re-render entries that were actually changed.
+type Line struct {
+	Kind    LineKind // Kind of content on the line
+	Raw     string   // Original text without the line terminator
+	EOL     string   // Line terminator ("\n", "\r\n" or "" for a final unterminated line)
+	EntryID int      // ID of the entry on this line (LineEntry only)
+	Block   string   // Managed block enclosing the line, or the marker's block ("" outside blocks)
+}
+
 // HostsFile represents a complete hosts file with all its entries.
+// Lookups by ID, hostname and IP use indexes that the HostsFile methods keep
+// in sync, so they stay fast for large block lists.
 type HostsFile struct {
 	Entries []Entry `json:"entries" yaml:"entries"` // List of all entries in the file
 	Path    string  `json:"path" yaml:"path"`       // Path to the hosts file
+	Lines   []Line  `json:"-" yaml:"-"`             // Every line of the file in order (nil if not parsed from a file)
+
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty" yaml:"diagnostics,omitempty"` // Problems found while parsing
+
+	idx *entryIndex // Lookup tables over Entries, built on first use
 }
 
 // BackupInfo contains metadata about a hosts file backup.
 type BackupInfo struct {
-	Path      string    `json:"path" yaml:"path"`             // Path to the backup file
-	Original  string    `json:"original" yaml:"original"`     // Path to the original file
-	CreatedAt time.Time `json:"created_at" yaml:"created_at"` // Backup creation time
-	Size      int64     `json:"size" yaml:"size"`             // Size of the backup file in bytes
+	ID         string    `json:"id,omitempty" yaml:"id,omitempty"`                   // Backup identifier used by "backup show" (automatic backups only)
+	Path       string    `json:"path" yaml:"path"`                                   // Path to the backup file
+	Original   string    `json:"original" yaml:"original"`                           // Path to the original file
+	CreatedAt  time.Time `json:"created_at" yaml:"created_at"`                       // Backup creation time
+	Size       int64     `json:"size" yaml:"size"`                                   // Size of the backup file in bytes
+	EntryCount int       `json:"entry_count,omitempty" yaml:"entry_count,omitempty"` // Number of entries in the backup
+	SHA256     string    `json:"sha256,omitempty" yaml:"sha256,omitempty"`           // Checksum of the uncompressed content (snapshots only)
+	Command    string    `json:"command,omitempty" yaml:"command,omitempty"`         // Command that triggered the backup
+	Compressed bool      `json:"compressed,omitempty" yaml:"compressed,omitempty"`   // Whether the backup file is gzip-compressed
 }
 
 // ExportFormat represents the supported export formats for profiles.
@@ -56,8 +110,21 @@ func (e *Entry) IsValid() bool {
 	return e.IP != "" && len(e.Names) > 0
 }
 
+// ToASCIINames converts internationalized hostnames of the entry to the
+// punycode (ACE) form that is written to the hosts file.
+func (e *Entry) ToASCIINames() error {
+	for i, name := range e.Names {
+		ascii, err := pkg.ToASCII(name)
+		if err != nil {
+			return err
+		}
+		e.Names[i] = ascii
+	}
+	return nil
+}
+
 // String returns the string representation of the entry as it would appear in a hosts file.
-// The format is: [# ]IP<tab>hostname1[<tab>hostname2...][<tab># comment]
+// The format is: [# ]IP<tab>hostname1[<tab>hostname2...][<tab># comment [@key=value...]]
 // If the entry is disabled, it will be prefixed with "# ".
 func (e *Entry) String() string {
 	line := e.IP
@@ -65,8 +132,8 @@ func (e *Entry) String() string {
 		line += "\t" + name
 	}
 
-	if e.Comment != "" {
-		line += "\t# " + e.Comment
+	if comment := e.FullComment(); comment != "" {
+		line += "\t# " + comment
 	}
 
 	if e.Disabled {
@@ -76,6 +143,64 @@ func (e *Entry) String() string {
 	return line
 }
 
+// maxStableID bounds entry IDs to positive 31-bit values.
+const maxStableID = 0x7fffffff
+
+// StableID derives an entry identifier from its IP address and hostnames.
+// The ID does not depend on the entry's position in the file, so it survives
+// lines being inserted or removed by other tools, and it is not affected by
+// enabling, disabling or re-commenting the entry.
+func StableID(ip string, names []string) int {
+	sorted := make([]string, len(names))
+	for i, name := range names {
+		sorted[i] = strings.ToLower(name)
+	}
+	sort.Strings(sorted)
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(pkg.NormalizeIP(ip)))
+	for _, name := range sorted {
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(name))
+	}
+
+	id := int(h.Sum32() & maxStableID)
+	if id == 0 {
+		id = 1
+	}
+	return id
+}
+
+// nextFreeID returns id, or the next ID after it that is not in use.
+// Entries with identical IP and hostnames are told apart this way.
+func nextFreeID(id int, inUse func(int) bool) int {
+	for inUse(id) {
+		id = id%maxStableID + 1
+	}
+	return id
+}
+
+// IsManaged reports whether the entry lives inside a hostsctl managed block.
+func (e *Entry) IsManaged() bool {
+	return e.Block != ""
+}
+
+// BlockBeginMarker returns the comment line that opens the named managed block.
+func BlockBeginMarker(block string) string {
+	if block == DefaultBlock {
+		return "# BEGIN hostsctl"
+	}
+	return "# BEGIN hostsctl " + block
+}
+
+// BlockEndMarker returns the comment line that closes the named managed block.
+func BlockEndMarker(block string) string {
+	if block == DefaultBlock {
+		return "# END hostsctl"
+	}
+	return "# END hostsctl " + block
+}
+
 // AddEntry adds a new entry to the profile and updates the modification timestamp.
 func (p *Profile) AddEntry(entry Entry) {
 	p.Entries = append(p.Entries, entry)
@@ -98,56 +223,49 @@ func (p *Profile) RemoveEntry(id int) bool {
 // FindByID searches for an entry by its ID and returns a pointer to it.
 // Returns nil if no entry with the given ID is found.
 func (h *HostsFile) FindByID(id int) *Entry {
-	for i := range h.Entries {
-		if h.Entries[i].ID == id {
-			return &h.Entries[i]
-		}
+	pos, ok := h.index().byID[id]
+	if !ok {
+		return nil
 	}
-	return nil
+	return &h.Entries[pos]
 }
 
 // FindByName searches for entries that contain the given hostname.
 // Returns a slice of pointers to all matching entries.
 func (h *HostsFile) FindByName(name string) []*Entry {
-	var results []*Entry
-	for i := range h.Entries {
-		for _, entryName := range h.Entries[i].Names {
-			if entryName == name {
-				results = append(results, &h.Entries[i])
-				break
-			}
-		}
-	}
-	return results
+	return h.entries(h.index().byName[name])
 }
 
 // AddEntry adds a new entry to the hosts file.
-// Automatically assigns a unique ID to the entry.
+// The entry is assigned its stable ID, derived from its IP address and hostnames.
 func (h *HostsFile) AddEntry(entry Entry) {
-	if len(h.Entries) == 0 {
-		entry.ID = 1
-	} else {
-		maxID := 0
-		for _, e := range h.Entries {
-			if e.ID > maxID {
-				maxID = e.ID
-			}
-		}
-		entry.ID = maxID + 1
-	}
+	idx := h.index()
+	entry.ID = nextFreeID(StableID(entry.IP, entry.Names), func(id int) bool {
+		_, ok := idx.byID[id]
+		return ok
+	})
+	entry.Raw = ""
 	h.Entries = append(h.Entries, entry)
+	idx.insert(&h.Entries[len(h.Entries)-1], len(h.Entries)-1)
+	idx.sync(h.Entries)
 }
 
 // RemoveEntry removes an entry from the hosts file by ID.
 // Returns true if the entry was found and removed, false otherwise.
 func (h *HostsFile) RemoveEntry(id int) bool {
-	for i, entry := range h.Entries {
-		if entry.ID == id {
-			h.Entries = append(h.Entries[:i], h.Entries[i+1:]...)
-			return true
-		}
+	idx := h.index()
+	pos, ok := idx.byID[id]
+	if !ok {
+		return false
 	}
-	return false
+
+	idx.delete(&h.Entries[pos])
+	h.Entries = append(h.Entries[:pos], h.Entries[pos+1:]...)
+	for i := pos; i < len(h.Entries); i++ {
+		idx.byID[h.Entries[i].ID] = i
+	}
+	idx.sync(h.Entries)
+	return true
 }
 
 // EnableEntry enables (uncomments) an entry by ID.
@@ -171,3 +289,73 @@ func (h *HostsFile) DisableEntry(id int) bool {
 	}
 	return false
 }
+
+// Blocks returns the names of the managed blocks present in the file, in file order.
+func (h *HostsFile) Blocks() []string {
+	var blocks []string
+	for _, line := range h.Lines {
+		if line.Kind == LineBlockBegin {
+			blocks = append(blocks, line.Block)
+		}
+	}
+	return blocks
+}
+
+// BlockEntries returns pointers to all entries that belong to the named managed block.
+func (h *HostsFile) BlockEntries(block string) []*Entry {
+	var results []*Entry
+	for i := range h.Entries {
+		if h.Entries[i].Block == block {
+			results = append(results, &h.Entries[i])
+		}
+	}
+	return results
+}
+
+// ReplaceBlock replaces every entry of the named managed block with the given entries.
+// Entries outside the block are left untouched.
+func (h *HostsFile) ReplaceBlock(block string, entries []Entry) {
+	kept := h.Entries[:0]
+	for _, entry := range h.Entries {
+		if entry.Block != block {
+			kept = append(kept, entry)
+		}
+	}
+	h.Entries = kept
+	h.Reindex()
+
+	for _, entry := range entries {
+		entry.Block = block
+		h.AddEntry(entry)
+	}
+}
+
+// RemoveBlock removes the named managed block from the file: its entries, its
+// markers and every other line between them, along with the blank line that
+// separates the block from the preceding content.
+func (h *HostsFile) RemoveBlock(block string) {
+	h.ReplaceBlock(block, nil)
+
+	kept := h.Lines[:0]
+	for _, line := range h.Lines {
+		if line.Block != block {
+			kept = append(kept, line)
+			continue
+		}
+		if line.Kind == LineBlockBegin && len(kept) > 0 && kept[len(kept)-1].Kind == LineBlank {
+			kept = kept[:len(kept)-1]
+		}
+	}
+	h.Lines = kept
+}
+
+// AdoptEntry moves an unmanaged entry into the named managed block.
+// Returns true if the entry was found and adopted, false otherwise.
+func (h *HostsFile) AdoptEntry(id int, block string) bool {
+	entry := h.FindByID(id)
+	if entry == nil || entry.IsManaged() {
+		return false
+	}
+	entry.Block = block
+	return true
+}
@@ -271,8 +271,8 @@ func TestHostsFile_AddEntry(t *testing.T) {
 		t.Errorf("Expected 1 entry, got %d", len(hostsFile.Entries))
 	}
 
-	if hostsFile.Entries[0].ID != 1 {
-		t.Errorf("Expected first entry ID 1, got %d", hostsFile.Entries[0].ID)
+	if want := StableID("127.0.0.1", []string{"localhost"}); hostsFile.Entries[0].ID != want {
+		t.Errorf("Expected first entry ID %d, got %d", want, hostsFile.Entries[0].ID)
 	}
 
 	// Test adding second entry
@@ -283,21 +283,35 @@ func TestHostsFile_AddEntry(t *testing.T) {
 		t.Errorf("Expected 2 entries, got %d", len(hostsFile.Entries))
 	}
 
-	if hostsFile.Entries[1].ID != 2 {
-		t.Errorf("Expected second entry ID 2, got %d", hostsFile.Entries[1].ID)
+	if want := StableID("192.168.1.1", []string{"server"}); hostsFile.Entries[1].ID != want {
+		t.Errorf("Expected second entry ID %d, got %d", want, hostsFile.Entries[1].ID)
 	}
 
-	// Test adding entry when there are gaps in IDs
-	hostsFile.Entries = []Entry{
-		{ID: 1, IP: "127.0.0.1", Names: []string{"localhost"}},
-		{ID: 5, IP: "192.168.1.1", Names: []string{"server"}},
+	// Test adding an identical entry gets a distinct ID
+	hostsFile.AddEntry(entry2)
+
+	if hostsFile.Entries[2].ID == hostsFile.Entries[1].ID {
+		t.Errorf("Duplicate entries should get distinct IDs, both got %d", hostsFile.Entries[2].ID)
+	}
+}
+
+func TestStableID(t *testing.T) {
+	id := StableID("192.168.1.1", []string{"api.local", "web.local"})
+
+	if id <= 0 {
+		t.Errorf("StableID() = %d, want a positive ID", id)
 	}
 
-	entry3 := Entry{IP: "10.0.0.1", Names: []string{"api"}}
-	hostsFile.AddEntry(entry3)
+	if other := StableID("192.168.1.1", []string{"web.local", "API.local"}); other != id {
+		t.Errorf("StableID() should ignore hostname order and case, got %d and %d", id, other)
+	}
 
-	if hostsFile.Entries[2].ID != 6 {
-		t.Errorf("Expected new entry ID 6 (max+1), got %d", hostsFile.Entries[2].ID)
+	if other := StableID("192.168.1.2", []string{"api.local", "web.local"}); other == id {
+		t.Error("StableID() should differ for a different IP")
+	}
+
+	if other := StableID("192.168.1.1", []string{"api.local"}); other == id {
+		t.Error("StableID() should differ for different hostnames")
 	}
 }
 
@@ -431,3 +445,103 @@ func TestProfile_Structure(t *testing.T) {
 		t.Error("Profile.UpdatedAt not set correctly")
 	}
 }
+
+func TestHostsFile_ReplaceBlock(t *testing.T) {
+	hostsFile := &HostsFile{
+		Entries: []Entry{
+			{ID: 1, IP: "127.0.0.1", Names: []string{"localhost"}},
+			{ID: 2, IP: "10.0.0.1", Names: []string{"old.local"}, Block: "dev"},
+			{ID: 3, IP: "10.0.0.2", Names: []string{"other.local"}, Block: DefaultBlock},
+		},
+	}
+
+	hostsFile.ReplaceBlock("dev", []Entry{
+		{IP: "10.0.0.5", Names: []string{"new.local"}},
+	})
+
+	if len(hostsFile.Entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(hostsFile.Entries))
+	}
+
+	devEntries := hostsFile.BlockEntries("dev")
+	if len(devEntries) != 1 || devEntries[0].Names[0] != "new.local" {
+		t.Errorf("Block 'dev' should only contain new.local, got %v", devEntries)
+	}
+
+	if hostsFile.FindByID(1) == nil || hostsFile.FindByID(3) == nil {
+		t.Error("Entries outside the block should be kept")
+	}
+}
+
+func TestHostsFile_AdoptEntry(t *testing.T) {
+	hostsFile := &HostsFile{
+		Entries: []Entry{
+			{ID: 1, IP: "10.0.0.1", Names: []string{"app.local"}},
+			{ID: 2, IP: "10.0.0.2", Names: []string{"managed.local"}, Block: DefaultBlock},
+		},
+	}
+
+	if !hostsFile.AdoptEntry(1, DefaultBlock) {
+		t.Error("AdoptEntry should return true for an unmanaged entry")
+	}
+
+	if !hostsFile.FindByID(1).IsManaged() {
+		t.Error("Entry should be managed after AdoptEntry")
+	}
+
+	if hostsFile.AdoptEntry(2, "dev") {
+		t.Error("AdoptEntry should return false for an already managed entry")
+	}
+
+	if hostsFile.AdoptEntry(999, DefaultBlock) {
+		t.Error("AdoptEntry should return false for non-existent entry")
+	}
+}
+
+func TestHostsFile_IndexStaysInSync(t *testing.T) {
+	h := &HostsFile{
+		Entries: []Entry{
+			{ID: 1, IP: "127.0.0.1", Names: []string{"localhost"}},
+			{ID: 2, IP: "::1", Names: []string{"localhost", "ip6-localhost"}},
+			{ID: 3, IP: "10.0.0.1", Names: []string{"app.local"}},
+		},
+	}
+
+	if got := len(h.FindByName("localhost")); got != 2 {
+		t.Errorf("FindByName(localhost) = %d entries, want 2", got)
+	}
+	if got := h.FindByIP("0:0:0:0:0:0:0:1"); len(got) != 1 || got[0].ID != 2 {
+		t.Errorf("FindByIP() should match equivalent IPv6 spellings, got %v", got)
+	}
+
+	h.RemoveEntry(1)
+	if got := h.FindByName("localhost"); len(got) != 1 || got[0].ID != 2 {
+		t.Errorf("FindByName(localhost) after remove = %v, want entry 2", got)
+	}
+	if entry := h.FindByID(3); entry == nil || entry.Names[0] != "app.local" {
+		t.Errorf("FindByID(3) after remove = %v, want app.local", entry)
+	}
+
+	h.AddEntry(Entry{IP: "10.0.0.2", Names: []string{"app.local"}})
+	if got := len(h.FindByName("app.local")); got != 2 {
+		t.Errorf("FindByName(app.local) after add = %d entries, want 2", got)
+	}
+
+	duplicates := h.DuplicateNames()
+	if len(duplicates) != 1 || len(duplicates["app.local"]) != 2 {
+		t.Errorf("DuplicateNames() = %v, want app.local twice", duplicates)
+	}
+
+	// Appending to Entries directly is picked up on the next lookup
+	h.Entries = append(h.Entries, Entry{ID: 99, IP: "10.0.0.3", Names: []string{"direct.local"}})
+	if entry := h.FindByID(99); entry == nil {
+		t.Error("FindByID() should see entries appended directly to Entries")
+	}
+
+	// Renaming in place requires an explicit Reindex
+	h.FindByID(99).Names = []string{"renamed.local"}
+	h.Reindex()
+	if len(h.FindByName("renamed.local")) != 1 || len(h.FindByName("direct.local")) != 0 {
+		t.Error("Reindex() should pick up in-place renames")
+	}
+}
@@ -5,19 +5,24 @@ import (
 	"fmt"
 	"io"
 	"regexp"
+	"slices"
 	"strings"
+
+	"github.com/vaxvhbe/hostsctl/pkg"
 )
 
 var (
-	// entryRegex matches hosts file entries, capturing disabled prefix, IP, hostnames, and comment
+	// entryRegex matches hosts file entries, capturing disabled prefix, IP, hostnames, and comment.
+	// splitEntry handles the common cases without it.
 	entryRegex = regexp.MustCompile(`^(\s*#\s*)?(\S+)\s+(.+?)(?:\s*#\s*(.*))?$`)
-	// hostnameRegex validates hostname format according to RFC standards
-	hostnameRegex = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9\-]{0,61}[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9\-]{0,61}[a-zA-Z0-9])?)*$`)
+	// blockMarkerRegex matches the comments delimiting a hostsctl managed block
+	blockMarkerRegex = regexp.MustCompile(`^\s*#\s*(BEGIN|END)\s+hostsctl(?:\s+(.+?))?\s*$`)
 )
 
 // ParseError represents an error that occurred during hosts file parsing.
 type ParseError struct {
 	Line    int    // Line number where the error occurred
+	Column  int    // Column (1-based) where the problem starts
 	Content string // Content of the problematic line
 	Reason  string // Human-readable description of the error
 }
@@ -27,6 +32,30 @@ func (e *ParseError) Error() string {
 	return fmt.Sprintf("parse error at line %d: %s (content: %q)", e.Line, e.Reason, e.Content)
 }
 
+// Severity indicates how serious a diagnostic is.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"   // The line could not be understood
+	SeverityWarning Severity = "warning" // The line was understood but is suspicious
+	SeverityInfo    Severity = "info"    // Worth knowing, but not a problem (lint findings only)
+)
+
+// Diagnostic describes a problem found while parsing a hosts file.
+// Lines with error diagnostics are kept verbatim and are not turned into entries.
+type Diagnostic struct {
+	Line     int      `json:"line" yaml:"line"`         // Line number (1-based)
+	Column   int      `json:"column" yaml:"column"`     // Column number (1-based)
+	Severity Severity `json:"severity" yaml:"severity"` // Error or warning
+	Reason   string   `json:"reason" yaml:"reason"`     // Human-readable description of the problem
+	Content  string   `json:"content" yaml:"content"`   // Content of the line
+}
+
+// String returns a one-line description of the diagnostic.
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("line %d, column %d: %s: %s", d.Line, d.Column, d.Severity, d.Reason)
+}
+
 // Parser handles parsing and serialization of hosts files.
 type Parser struct {
 	strict bool // Whether to fail on parse errors or continue with warnings
@@ -34,57 +63,279 @@ type Parser struct {
 
 // NewParser creates a new parser instance.
 // If strict is true, parsing will fail on any error.
-// If strict is false, invalid lines will be skipped with warnings.
+// If strict is false, invalid lines are kept verbatim and reported in
+// HostsFile.Diagnostics.
 func NewParser(strict bool) *Parser {
 	return &Parser{strict: strict}
 }
 
 // Parse reads a hosts file from the provided reader and returns a parsed HostsFile.
 // It processes each line, extracting entries while handling comments and disabled entries.
+// Every physical line is also recorded in HostsFile.Lines so that Serialize can
+// reproduce comments, blank lines and the original formatting. Entries between
+// "# BEGIN hostsctl [name]" and "# END hostsctl [name]" markers are tagged with
+// the name of their managed block. Problems are collected in HostsFile.Diagnostics.
 func (p *Parser) Parse(reader io.Reader) (*HostsFile, error) {
 	hostsFile := &HostsFile{
 		Entries: []Entry{},
+		Lines:   []Line{},
 	}
 
-	scanner := bufio.NewScanner(reader)
+	br := bufio.NewReader(reader)
 	lineNum := 0
-	entryID := 1
+	usedIDs := make(map[int]bool)
+	block := ""
+	blockStart := -1
+
+	for {
+		text, readErr := br.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return nil, fmt.Errorf("error reading input: %w", readErr)
+		}
+		if text == "" && readErr == io.EOF {
+			break
+		}
 
-	for scanner.Scan() {
 		lineNum++
-		line := scanner.Text()
+		line, eol := splitEOL(text)
+		physical := Line{Raw: line, EOL: eol, Block: block}
 
 		trimmed := strings.TrimSpace(line)
-		if trimmed == "" || (strings.HasPrefix(trimmed, "#") && !p.isDisabledEntry(line)) {
-			continue
-		}
-
-		entry, err := p.parseLine(line, lineNum, entryID)
-		if err != nil {
-			if p.strict {
-				return nil, err
+		marker, markerBlock := parseBlockMarker(line)
+		switch {
+		case trimmed == "":
+			physical.Kind = LineBlank
+		case marker == LineBlockBegin && block == "":
+			physical.Kind = LineBlockBegin
+			physical.Block = markerBlock
+			block = markerBlock
+			blockStart = len(hostsFile.Lines)
+		case marker == LineBlockEnd && markerBlock == block:
+			physical.Kind = LineBlockEnd
+			block = ""
+			blockStart = -1
+		case marker == LineBlockBegin:
+			physical.Kind = LineComment
+			hostsFile.warn(lineNum, line, fmt.Sprintf("nested managed block marker for %q ignored", markerBlock))
+		case marker == LineBlockEnd:
+			physical.Kind = LineComment
+			hostsFile.warn(lineNum, line, fmt.Sprintf("END marker for %q has no matching BEGIN marker", markerBlock))
+		case strings.HasPrefix(trimmed, "#") && !p.isDisabledEntry(line):
+			physical.Kind = LineComment
+		default:
+			entry, err := p.parseLine(line, lineNum, 0)
+			switch {
+			case err != nil && strings.HasPrefix(trimmed, "#"):
+				// Commented prose that merely looks like an entry
+				physical.Kind = LineComment
+			case err != nil:
+				if p.strict {
+					return nil, err
+				}
+				physical.Kind = LineInvalid
+				hostsFile.Diagnostics = append(hostsFile.Diagnostics, err.(*ParseError).diagnostic())
+			default:
+				entry.ID = nextFreeID(StableID(entry.IP, entry.Names), func(id int) bool { return usedIDs[id] })
+				usedIDs[entry.ID] = true
+				physical.Kind = LineEntry
+				physical.EntryID = entry.ID
+				entry.Block = block
+				hostsFile.Entries = append(hostsFile.Entries, *entry)
 			}
-			continue
 		}
 
-		if entry != nil {
-			hostsFile.Entries = append(hostsFile.Entries, *entry)
-			entryID++
+		hostsFile.Lines = append(hostsFile.Lines, physical)
+
+		if readErr == io.EOF {
+			break
 		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading input: %w", err)
+	if blockStart >= 0 {
+		start := hostsFile.Lines[blockStart]
+		hostsFile.warn(blockStart+1, start.Raw, fmt.Sprintf("managed block %q is never closed; its lines are treated as unmanaged", start.Block))
+		unterminatedBlock(hostsFile, blockStart)
 	}
 
 	return hostsFile, nil
 }
 
+// diagnostic converts a parse error into an error diagnostic.
+func (e *ParseError) diagnostic() Diagnostic {
+	return Diagnostic{
+		Line:     e.Line,
+		Column:   e.Column,
+		Severity: SeverityError,
+		Reason:   e.Reason,
+		Content:  e.Content,
+	}
+}
+
+// warn records a warning diagnostic for a line.
+func (h *HostsFile) warn(lineNum int, content, reason string) {
+	column := len(content) - len(strings.TrimLeft(content, " \t")) + 1
+	h.Diagnostics = append(h.Diagnostics, Diagnostic{
+		Line:     lineNum,
+		Column:   column,
+		Severity: SeverityWarning,
+		Reason:   reason,
+		Content:  content,
+	})
+}
+
+// parseBlockMarker reports whether a line is a managed block marker and,
+// if so, the name of the block it delimits.
+func parseBlockMarker(line string) (LineKind, string) {
+	if !strings.Contains(line, "hostsctl") {
+		return LineComment, ""
+	}
+
+	matches := blockMarkerRegex.FindStringSubmatch(line)
+	if matches == nil {
+		return LineComment, ""
+	}
+
+	name := matches[2]
+	if name == "" {
+		name = DefaultBlock
+	}
+
+	if matches[1] == "BEGIN" {
+		return LineBlockBegin, name
+	}
+	return LineBlockEnd, name
+}
+
+// unterminatedBlock demotes a BEGIN marker that has no matching END marker to a
+// plain comment, so that the lines following it are treated as unmanaged.
+func unterminatedBlock(hostsFile *HostsFile, start int) {
+	hostsFile.Lines[start].Kind = LineComment
+
+	orphaned := make(map[int]bool)
+	for i := start; i < len(hostsFile.Lines); i++ {
+		hostsFile.Lines[i].Block = ""
+		if hostsFile.Lines[i].Kind == LineEntry {
+			orphaned[hostsFile.Lines[i].EntryID] = true
+		}
+	}
+
+	for i := range hostsFile.Entries {
+		if orphaned[hostsFile.Entries[i].ID] {
+			hostsFile.Entries[i].Block = ""
+		}
+	}
+}
+
+// splitEOL separates a line read from the input from its line terminator.
+func splitEOL(text string) (string, string) {
+	if strings.HasSuffix(text, "\r\n") {
+		return strings.TrimSuffix(text, "\r\n"), "\r\n"
+	}
+	if strings.HasSuffix(text, "\n") {
+		return strings.TrimSuffix(text, "\n"), "\n"
+	}
+	return text, ""
+}
+
 // isDisabledEntry checks if a line represents a disabled (commented) hosts entry.
 // Returns true if the line matches the entry format but is commented out.
 func (p *Parser) isDisabledEntry(line string) bool {
-	matches := entryRegex.FindStringSubmatch(line)
-	return len(matches) >= 4 && strings.TrimSpace(matches[1]) != ""
+	fields, ok := splitEntry(line)
+	return ok && fields.disabled
+}
+
+// entryFields holds the parts of an entry line and the byte offsets of the
+// IP address and hostnames within the line.
+type entryFields struct {
+	disabled   bool
+	ip         string
+	names      string
+	comment    string
+	ipStart    int
+	namesStart int
+}
+
+// isSpace reports whether b is a whitespace character as matched by \s.
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\f' || b == '\r'
+}
+
+// splitEntry splits an entry line into its parts. It scans the line directly
+// for the usual "[# ]IP names [# comment]" layout and only falls back to
+// entryRegex for unusual lines, which keeps parsing large block lists fast.
+func splitEntry(line string) (entryFields, bool) {
+	if fields, ok := scanEntry(line); ok {
+		return fields, true
+	}
+
+	loc := entryRegex.FindStringSubmatchIndex(line)
+	if loc == nil {
+		return entryFields{}, false
+	}
+
+	fields := entryFields{
+		disabled:   loc[2] >= 0 && strings.TrimSpace(line[loc[2]:loc[3]]) != "",
+		ip:         line[loc[4]:loc[5]],
+		names:      line[loc[6]:loc[7]],
+		ipStart:    loc[4],
+		namesStart: loc[6],
+	}
+	if loc[8] >= 0 {
+		fields.comment = line[loc[8]:loc[9]]
+	}
+	return fields, true
+}
+
+// scanEntry is the fast path of splitEntry. It gives up (returning false) on
+// any line where its result could differ from entryRegex.
+func scanEntry(line string) (entryFields, bool) {
+	var fields entryFields
+	i := 0
+	for i < len(line) && isSpace(line[i]) {
+		i++
+	}
+	switch {
+	case i < len(line) && line[i] == '#':
+		fields.disabled = true
+		i++
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+	case i > 0:
+		// Active entries must start at the beginning of the line
+		return entryFields{}, false
+	}
+
+	fields.ipStart = i
+	for i < len(line) && !isSpace(line[i]) {
+		i++
+	}
+	if i == fields.ipStart || i == len(line) {
+		return entryFields{}, false
+	}
+	fields.ip = line[fields.ipStart:i]
+
+	for i < len(line) && isSpace(line[i]) {
+		i++
+	}
+	if i == len(line) || line[i] == '#' {
+		return entryFields{}, false
+	}
+	fields.namesStart = i
+
+	hash := strings.IndexByte(line[i:], '#')
+	if hash < 0 {
+		fields.names = line[i:]
+		return fields, true
+	}
+
+	end := i + hash
+	for end > i && isSpace(line[end-1]) {
+		end--
+	}
+	fields.names = line[i:end]
+	fields.comment = line[i+hash+1:]
+	return fields, true
 }
 
 // parseLine parses a single line from a hosts file into an Entry.
@@ -95,174 +346,220 @@ func (p *Parser) parseLine(line string, lineNum, entryID int) (*Entry, error) {
 		return nil, nil
 	}
 
-	matches := entryRegex.FindStringSubmatch(line)
-	if len(matches) < 4 {
+	fields, ok := splitEntry(line)
+	if !ok {
 		return nil, &ParseError{
 			Line:    lineNum,
+			Column:  len(line) - len(strings.TrimLeft(line, " \t")) + 1,
 			Content: line,
 			Reason:  "invalid line format",
 		}
 	}
 
-	disabled := strings.TrimSpace(matches[1]) != ""
-	ip := strings.TrimSpace(matches[2])
-	hostnames := strings.Fields(strings.TrimSpace(matches[3]))
-	comment := ""
-	if len(matches) > 4 {
-		comment = strings.TrimSpace(matches[4])
-	}
+	ip := fields.ip
+	hostnames := strings.Fields(fields.names)
+	comment := strings.TrimSpace(fields.comment)
 
-	if !p.isValidIP(ip) {
+	if !pkg.IsValidIP(ip) {
 		return nil, &ParseError{
 			Line:    lineNum,
+			Column:  fields.ipStart + 1,
 			Content: line,
 			Reason:  fmt.Sprintf("invalid IP address: %s", ip),
 		}
 	}
 
+	offset := fields.namesStart
 	for _, hostname := range hostnames {
-		if !p.isValidHostname(hostname) {
+		column := offset + strings.Index(line[offset:], hostname)
+		offset = column + len(hostname)
+
+		if !isValidHostname(hostname) {
+			reason := fmt.Sprintf("invalid hostname: %s", hostname)
+			if ascii, err := pkg.ToASCII(hostname); err == nil && ascii != hostname && isValidHostname(ascii) {
+				reason = fmt.Sprintf("internationalized hostname %s must be written in punycode form: %s", hostname, ascii)
+			}
 			return nil, &ParseError{
 				Line:    lineNum,
+				Column:  column + 1,
 				Content: line,
-				Reason:  fmt.Sprintf("invalid hostname: %s", hostname),
+				Reason:  reason,
 			}
 		}
 	}
 
-	return &Entry{
+	entry := &Entry{
 		ID:       entryID,
 		IP:       ip,
 		Names:    hostnames,
-		Comment:  comment,
-		Disabled: disabled,
+		Disabled: fields.disabled,
 		Raw:      line,
-	}, nil
+	}
+	entry.Comment = parseAnnotations(comment, entry)
+
+	return entry, nil
 }
 
-// isValidIP validates an IP address (IPv4 or IPv6).
-// Returns true if the IP address is properly formatted.
-func (p *Parser) isValidIP(ip string) bool {
-	parts := strings.Split(ip, ".")
-	if len(parts) != 4 {
-		return p.isValidIPv6(ip)
+// isValidHostname validates a hostname according to RFC standards.
+// Returns true if the hostname format is valid.
+func isValidHostname(hostname string) bool {
+	if len(hostname) == 0 || len(hostname) > 253 {
+		return false
 	}
 
-	for _, part := range parts {
-		if len(part) == 0 || len(part) > 3 {
-			return false
-		}
-
-		num := 0
-		for _, char := range part {
-			if char < '0' || char > '9' {
-				return false
-			}
-			num = num*10 + int(char-'0')
-		}
+	if hostname == "localhost" {
+		return true
+	}
 
-		if num > 255 {
+	for _, label := range strings.Split(hostname, ".") {
+		if !isValidLabel(label) {
 			return false
 		}
+	}
+	return true
+}
 
-		if len(part) > 1 && part[0] == '0' {
+// isValidLabel validates a single dot-separated hostname label: 1 to 63
+// letters, digits or hyphens, not starting or ending with a hyphen.
+func isValidLabel(label string) bool {
+	if len(label) == 0 || len(label) > 63 {
+		return false
+	}
+	if label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+
+	for i := 0; i < len(label); i++ {
+		c := label[i]
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') && c != '-' {
 			return false
 		}
 	}
-
 	return true
 }
 
-// isValidIPv6 validates an IPv6 address format.
-// Supports both full and compressed IPv6 notation.
-func (p *Parser) isValidIPv6(ip string) bool {
-	if strings.Contains(ip, "::") {
-		parts := strings.Split(ip, "::")
-		if len(parts) != 2 {
-			return false
-		}
+// Serialize converts a HostsFile back to its string representation.
+// Files that were parsed keep every original line: comments, blank lines and
+// unchanged entries are written verbatim, modified entries are re-rendered with
+// Entry.String() and removed entries are dropped. New entries are written at the
+// end of their managed block (creating the block at the end of the file if it
+// does not exist yet), or appended to the file if they are unmanaged.
+func (p *Parser) Serialize(hostsFile *HostsFile) string {
+	byID := hostsFile.index().byID
 
-		leftParts := []string{}
-		rightParts := []string{}
+	out := &lineWriter{eol: "\n"}
+	if len(hostsFile.Lines) > 0 && hostsFile.Lines[0].EOL != "" {
+		out.eol = hostsFile.Lines[0].EOL
+	}
+	out.b.Grow(len(hostsFile.Lines) * 32)
 
-		if parts[0] != "" {
-			leftParts = strings.Split(parts[0], ":")
-		}
-		if parts[1] != "" {
-			rightParts = strings.Split(parts[1], ":")
-		}
+	written := make([]bool, len(hostsFile.Entries))
 
-		totalParts := len(leftParts) + len(rightParts)
-		if totalParts >= 8 {
-			return false
-		}
-
-		allParts := append(leftParts, rightParts...)
-		for _, part := range allParts {
-			if !p.isValidIPv6Part(part) {
-				return false
+	for _, line := range hostsFile.Lines {
+		switch line.Kind {
+		case LineEntry:
+			pos, ok := byID[line.EntryID]
+			if !ok || written[pos] || hostsFile.Entries[pos].Block != line.Block {
+				continue
 			}
+			written[pos] = true
+			out.write(p.renderEntry(&hostsFile.Entries[pos]), line.EOL)
+		case LineBlockEnd:
+			p.writeBlockEntries(out, hostsFile, line.Block, written)
+			out.write(line.Raw, line.EOL)
+		default:
+			out.write(line.Raw, line.EOL)
 		}
-
-		return true
 	}
 
-	parts := strings.Split(ip, ":")
-	if len(parts) != 8 {
-		return false
+	var newBlocks []string
+	for i := range hostsFile.Entries {
+		entry := &hostsFile.Entries[i]
+		if written[i] {
+			continue
+		}
+		if entry.IsManaged() {
+			if !slices.Contains(newBlocks, entry.Block) {
+				newBlocks = append(newBlocks, entry.Block)
+			}
+			continue
+		}
+		written[i] = true
+		out.write(entry.String(), out.eol)
 	}
 
-	for _, part := range parts {
-		if !p.isValidIPv6Part(part) {
-			return false
+	for _, block := range newBlocks {
+		if out.started && strings.TrimSpace(out.last) != "" {
+			out.write("", out.eol)
 		}
+		out.write(BlockBeginMarker(block), out.eol)
+		p.writeBlockEntries(out, hostsFile, block, written)
+		out.write(BlockEndMarker(block), out.eol)
 	}
 
-	return true
+	return out.String()
 }
 
-// isValidIPv6Part validates a single part (segment) of an IPv6 address.
-// Each part should be 1-4 hexadecimal characters.
-func (p *Parser) isValidIPv6Part(part string) bool {
-	if len(part) == 0 || len(part) > 4 {
-		return false
-	}
-
-	for _, char := range part {
-		if (char < '0' || char > '9') &&
-			(char < 'a' || char > 'f') &&
-			(char < 'A' || char > 'F') {
-			return false
+// writeBlockEntries writes every not yet written entry of a managed block.
+func (p *Parser) writeBlockEntries(out *lineWriter, hostsFile *HostsFile, block string, written []bool) {
+	for i := range hostsFile.Entries {
+		entry := &hostsFile.Entries[i]
+		if entry.Block != block || written[i] {
+			continue
 		}
+		written[i] = true
+		out.write(p.renderEntry(entry), out.eol)
 	}
-
-	return true
 }
 
-// isValidHostname validates a hostname according to RFC standards.
-// Returns true if the hostname format is valid.
-func (p *Parser) isValidHostname(hostname string) bool {
-	if len(hostname) == 0 || len(hostname) > 253 {
-		return false
-	}
+// lineWriter accumulates serialized lines, deferring each line terminator so
+// that a final unterminated line stays unterminated unless more lines follow.
+type lineWriter struct {
+	b       strings.Builder
+	eol     string // Terminator used for lines that did not come from the file
+	pending string // Terminator of the last written line
+	last    string // Text of the last written line
+	started bool   // Whether any line has been written
+}
 
-	if hostname == "localhost" {
-		return true
+// write appends a line with the given terminator.
+func (w *lineWriter) write(text, eol string) {
+	if w.started && w.pending == "" {
+		w.pending = w.eol
 	}
+	w.b.WriteString(w.pending)
+	w.b.WriteString(text)
+	w.pending = eol
+	w.last = text
+	w.started = true
+}
 
-	return hostnameRegex.MatchString(hostname)
+// String returns the serialized content.
+func (w *lineWriter) String() string {
+	return w.b.String() + w.pending
 }
 
-// Serialize converts a HostsFile back to its string representation.
-// Each entry is converted to a line using the Entry.String() method.
-func (p *Parser) Serialize(hostsFile *HostsFile) string {
-	var lines []string
+// renderEntry returns the original line of an entry if it is unchanged since
+// it was parsed, or a freshly rendered line otherwise.
+func (p *Parser) renderEntry(entry *Entry) string {
+	if entry.Raw == "" {
+		return entry.String()
+	}
 
-	for _, entry := range hostsFile.Entries {
-		lines = append(lines, entry.String())
+	original, err := p.parseLine(entry.Raw, 0, entry.ID)
+	if err != nil || original == nil || !sameEntry(original, entry) {
+		return entry.String()
 	}
 
-	return strings.Join(lines, "\n") + "\n"
+	return entry.Raw
+}
+
+// sameEntry reports whether two entries have identical file content.
+func sameEntry(a, b *Entry) bool {
+	return pkg.SameIP(a.IP, b.IP) &&
+		strings.Join(a.Names, " ") == strings.Join(b.Names, " ") &&
+		a.FullComment() == b.FullComment() &&
+		a.Disabled == b.Disabled
 }
 
 // ParseFile is a convenience function to parse a hosts file with default settings.
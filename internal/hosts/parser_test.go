@@ -323,6 +323,8 @@ func TestParser_IPv6Addresses(t *testing.T) {
 		{"IPv6 full", "2001:0db8:85a3:0000:0000:8a2e:0370:7334"},
 		{"IPv6 compressed", "2001:db8:85a3::8a2e:370:7334"},
 		{"IPv6 link-local", "fe80::1"},
+		{"IPv6 with zone", "fe80::1%eth0"},
+		{"IPv4-mapped", "::ffff:192.168.1.1"},
 	}
 
 	for _, tt := range tests {
@@ -369,3 +371,306 @@ func TestParser_SerializeEmpty(t *testing.T) {
 		t.Errorf("Parser.Serialize() for empty hosts file = %q, want empty string", output)
 	}
 }
+
+func TestParser_LosslessRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{
+			name: "comments and blank lines",
+			input: `# /etc/hosts: static lookup table for host names
+
+127.0.0.1   localhost
+::1         localhost ip6-localhost   # loopback
+
+# --- development ---
+192.168.1.10	dev.local    api.dev.local
+# 192.168.1.11	old.local	# retired
+`,
+		},
+		{
+			name:  "missing final newline",
+			input: "# header\n127.0.0.1 localhost",
+		},
+		{
+			name:  "CRLF line endings",
+			input: "# header\r\n127.0.0.1\tlocalhost\r\n\r\n",
+		},
+		{
+			name:  "invalid lines kept verbatim",
+			input: "127.0.0.1 localhost\nnot a hosts line\n999.1.1.1 bad.local\n",
+		},
+		{
+			name:  "commented prose",
+			input: "# The following lines are desirable for IPv6 capable hosts\n::1 ip6-localhost\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := NewParser(false)
+			hostsFile, err := parser.Parse(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("Parser.Parse() error = %v", err)
+			}
+
+			output := parser.Serialize(hostsFile)
+			if output != tt.input {
+				t.Errorf("Round trip not lossless:\nInput:  %q\nOutput: %q", tt.input, output)
+			}
+		})
+	}
+}
+
+func TestParser_SerializeOnlyChangedLines(t *testing.T) {
+	input := `# header
+
+127.0.0.1   localhost
+192.168.1.10	dev.local    # dev box
+192.168.1.11	stage.local
+# footer
+`
+
+	parser := NewParser(false)
+	hostsFile, err := parser.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parser.Parse() error = %v", err)
+	}
+
+	if len(hostsFile.Lines) != 6 {
+		t.Fatalf("Expected 6 lines, got %d", len(hostsFile.Lines))
+	}
+
+	hostsFile.DisableEntry(hostsFile.FindByName("dev.local")[0].ID)
+	hostsFile.RemoveEntry(hostsFile.FindByName("stage.local")[0].ID)
+	hostsFile.AddEntry(Entry{IP: "10.0.0.1", Names: []string{"new.local"}})
+
+	expected := `# header
+
+127.0.0.1   localhost
+# 192.168.1.10	dev.local	# dev box
+# footer
+10.0.0.1	new.local
+`
+
+	output := parser.Serialize(hostsFile)
+	if output != expected {
+		t.Errorf("Parser.Serialize() = %q, want %q", output, expected)
+	}
+}
+
+func TestParser_ManagedBlocks(t *testing.T) {
+	input := `127.0.0.1	localhost
+# BEGIN hostsctl
+10.0.0.1	api.local
+# END hostsctl
+# BEGIN hostsctl dev
+10.0.0.2	dev.local
+# END hostsctl dev
+`
+
+	parser := NewParser(false)
+	hostsFile, err := parser.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parser.Parse() error = %v", err)
+	}
+
+	wantBlocks := []string{"", DefaultBlock, "dev"}
+	for i, want := range wantBlocks {
+		if hostsFile.Entries[i].Block != want {
+			t.Errorf("Entry %d block = %q, want %q", i+1, hostsFile.Entries[i].Block, want)
+		}
+	}
+
+	blocks := hostsFile.Blocks()
+	if len(blocks) != 2 || blocks[0] != DefaultBlock || blocks[1] != "dev" {
+		t.Errorf("Blocks() = %v, want [%s dev]", blocks, DefaultBlock)
+	}
+
+	hostsFile.AddEntry(Entry{IP: "10.0.0.3", Names: []string{"new.local"}, Block: DefaultBlock})
+	hostsFile.AddEntry(Entry{IP: "10.0.0.4", Names: []string{"qa.local"}, Block: "qa"})
+
+	expected := `127.0.0.1	localhost
+# BEGIN hostsctl
+10.0.0.1	api.local
+10.0.0.3	new.local
+# END hostsctl
+# BEGIN hostsctl dev
+10.0.0.2	dev.local
+# END hostsctl dev
+
+# BEGIN hostsctl qa
+10.0.0.4	qa.local
+# END hostsctl qa
+`
+
+	output := parser.Serialize(hostsFile)
+	if output != expected {
+		t.Errorf("Parser.Serialize() = %q, want %q", output, expected)
+	}
+}
+
+func TestParser_UnterminatedBlock(t *testing.T) {
+	input := "# BEGIN hostsctl\n10.0.0.1\tapi.local\n"
+
+	parser := NewParser(false)
+	hostsFile, err := parser.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parser.Parse() error = %v", err)
+	}
+
+	if hostsFile.Entries[0].IsManaged() {
+		t.Error("Entry after an unterminated BEGIN marker should not be managed")
+	}
+
+	if len(hostsFile.Blocks()) != 0 {
+		t.Errorf("Expected no blocks, got %v", hostsFile.Blocks())
+	}
+}
+
+func TestParser_StableIDs(t *testing.T) {
+	parser := NewParser(false)
+
+	before, err := parser.Parse(strings.NewReader("127.0.0.1\tlocalhost\n10.0.0.1\tapp.local\n"))
+	if err != nil {
+		t.Fatalf("Parser.Parse() error = %v", err)
+	}
+
+	// Another tool inserts a line above and disables the entry
+	after, err := parser.Parse(strings.NewReader("10.0.0.9\tother.local\n127.0.0.1\tlocalhost\n# 10.0.0.1\tapp.local\t# off\n"))
+	if err != nil {
+		t.Fatalf("Parser.Parse() error = %v", err)
+	}
+
+	id := before.FindByName("app.local")[0].ID
+	entry := after.FindByID(id)
+	if entry == nil || entry.Names[0] != "app.local" {
+		t.Errorf("Entry ID %d should still identify app.local after external edits, got %v", id, entry)
+	}
+
+	dups, err := parser.Parse(strings.NewReader("127.0.0.1\tlocalhost\n127.0.0.1\tlocalhost\n"))
+	if err != nil {
+		t.Fatalf("Parser.Parse() error = %v", err)
+	}
+
+	if dups.Entries[0].ID == dups.Entries[1].ID {
+		t.Errorf("Duplicate entries should get distinct IDs, both got %d", dups.Entries[0].ID)
+	}
+}
+
+func TestParser_Diagnostics(t *testing.T) {
+	input := "127.0.0.1\tlocalhost\n" +
+		"999.1.1.1\tbad-ip.local\n" +
+		"10.0.0.1  good.local  bad_host!\n" +
+		"# END hostsctl\n"
+
+	parser := NewParser(false)
+	hostsFile, err := parser.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parser.Parse() error = %v", err)
+	}
+
+	want := []Diagnostic{
+		{Line: 2, Column: 1, Severity: SeverityError},
+		{Line: 3, Column: 23, Severity: SeverityError},
+		{Line: 4, Column: 1, Severity: SeverityWarning},
+	}
+
+	if len(hostsFile.Diagnostics) != len(want) {
+		t.Fatalf("Expected %d diagnostics, got %v", len(want), hostsFile.Diagnostics)
+	}
+
+	for i, w := range want {
+		got := hostsFile.Diagnostics[i]
+		if got.Line != w.Line || got.Column != w.Column || got.Severity != w.Severity {
+			t.Errorf("Diagnostic %d = %+v, want line %d column %d severity %s", i, got, w.Line, w.Column, w.Severity)
+		}
+	}
+
+	// Unparsable lines are written back verbatim
+	if output := parser.Serialize(hostsFile); output != input {
+		t.Errorf("Serialize() = %q, want %q", output, input)
+	}
+}
+
+func TestParser_StrictColumn(t *testing.T) {
+	parser := NewParser(true)
+	_, err := parser.Parse(strings.NewReader("10.0.0.1\tok.local bad_host!\n"))
+
+	parseErr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("Expected *ParseError, got %v", err)
+	}
+	if parseErr.Column != 19 {
+		t.Errorf("ParseError.Column = %d, want 19", parseErr.Column)
+	}
+}
+
+func TestParser_UnicodeHostnameHint(t *testing.T) {
+	parser := NewParser(true)
+	_, err := parser.Parse(strings.NewReader("10.0.0.1\tbücher.example\n"))
+
+	parseErr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("Expected *ParseError, got %v", err)
+	}
+	if !strings.Contains(parseErr.Reason, "xn--bcher-kva.example") {
+		t.Errorf("ParseError.Reason = %q, want punycode hint", parseErr.Reason)
+	}
+}
+
+func TestSplitEntry_MatchesRegex(t *testing.T) {
+	lines := []string{
+		"127.0.0.1\tlocalhost",
+		"127.0.0.1    localhost   local  \t# Comment",
+		"# 10.0.0.1\tapp.local\t# off",
+		"#10.0.0.1 app.local#tight",
+		"  #  10.0.0.1 app.local",
+		"  \t127.0.0.1\tlocalhost",
+		"127.0.0.1\tlocalhost  \t  ",
+		"1.2.3.4 #comment",
+		"1.2.3.4#x host",
+		"1.2.3.4   ",
+		"# just a comment",
+		"# ",
+		"#",
+		"onlyone",
+		"::1 ip6-localhost ip6-loopback # IPv6 # twice",
+		"0.0.0.0 ads.example.com",
+	}
+
+	for _, line := range lines {
+		got, gotOK := splitEntry(line)
+
+		loc := entryRegex.FindStringSubmatchIndex(line)
+		if (loc != nil) != gotOK {
+			t.Errorf("splitEntry(%q) ok = %v, regex match = %v", line, gotOK, loc != nil)
+			continue
+		}
+		if loc == nil {
+			continue
+		}
+
+		comment := ""
+		if loc[8] >= 0 {
+			comment = line[loc[8]:loc[9]]
+		}
+		want := entryFields{
+			disabled:   loc[2] >= 0 && strings.TrimSpace(line[loc[2]:loc[3]]) != "",
+			ip:         line[loc[4]:loc[5]],
+			names:      line[loc[6]:loc[7]],
+			comment:    comment,
+			ipStart:    loc[4],
+			namesStart: loc[6],
+		}
+
+		got.comment = strings.TrimSpace(got.comment)
+		want.comment = strings.TrimSpace(want.comment)
+		got.names = strings.Join(strings.Fields(got.names), " ")
+		want.names = strings.Join(strings.Fields(want.names), " ")
+		if got != want {
+			t.Errorf("splitEntry(%q) = %+v, want %+v", line, got, want)
+		}
+	}
+}
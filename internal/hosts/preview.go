@@ -0,0 +1,76 @@
+package hosts
+
+import (
+	"fmt"
+	"os"
+)
+
+// Preview describes the change a dry-run Save would have made to a file.
+type Preview struct {
+	Path    string   `json:"path"`    // File that would have been written
+	Added   []string `json:"added"`   // Lines that would be added
+	Removed []string `json:"removed"` // Lines that would be removed
+	Diff    string   `json:"diff"`    // Unified diff from the current to the new content
+}
+
+// HasChanges reports whether the previewed change modifies the file.
+func (p *Preview) HasChanges() bool {
+	return p.Diff != ""
+}
+
+// newPreview compares the current and new content of the file at path.
+func newPreview(path, current, content string) *Preview {
+	preview := &Preview{
+		Path:    path,
+		Added:   []string{},
+		Removed: []string{},
+	}
+	if current == content {
+		return preview
+	}
+
+	ops := diffLines(splitLines(current), splitLines(content))
+	preview.Diff = formatUnifiedDiff(path, path+" (new)", ops)
+	for _, op := range ops {
+		switch op.kind {
+		case '+':
+			preview.Added = append(preview.Added, op.line)
+		case '-':
+			preview.Removed = append(preview.Removed, op.line)
+		}
+	}
+	return preview
+}
+
+// previewFile builds the preview of replacing the file at path with content.
+// A missing file counts as empty.
+func previewFile(path, content string) (*Preview, error) {
+	current, err := os.ReadFile(path) // #nosec G304 -- path of a hosts file or fragment
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return newPreview(path, string(current), content), nil
+}
+
+// SetDryRun makes Save compute the new content of the hosts file without
+// writing anything: no backup is taken, the file is left untouched and old
+// backups are not pruned. The change is available from Preview instead.
+func (s *Store) SetDryRun(dryRun bool) {
+	s.dryRun = dryRun
+}
+
+// Preview returns the change computed by the last dry-run Save, or nil if
+// there was none.
+func (s *Store) Preview() *Preview {
+	return s.preview
+}
+
+// Preview returns the change saving hostsFile as the named fragment would
+// make, without writing it.
+func (d *FragmentDir) Preview(name string, hostsFile *HostsFile) (*Preview, error) {
+	path, err := d.Path(name)
+	if err != nil {
+		return nil, err
+	}
+	return previewFile(path, d.parser.Serialize(hostsFile))
+}
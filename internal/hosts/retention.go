@@ -0,0 +1,108 @@
+package hosts
+
+import (
+	"path/filepath"
+	"time"
+)
+
+// RetentionPolicy controls which automatic backups are kept.
+// A zero value for a field disables that limit; the most recent backup is
+// always kept.
+type RetentionPolicy struct {
+	KeepLast     int           `json:"keep_last" yaml:"keep_last"`           // Number of most recent backups to keep
+	MaxAge       time.Duration `json:"max_age" yaml:"max_age"`               // Backups older than this are removed
+	MaxTotalSize int64         `json:"max_total_size" yaml:"max_total_size"` // Oldest backups are removed until the rest fit in this many bytes
+}
+
+// DefaultRetentionPolicy is the policy applied unless configured otherwise.
+var DefaultRetentionPolicy = RetentionPolicy{KeepLast: 10}
+
+// IsZero reports whether the policy sets no limit at all.
+func (r RetentionPolicy) IsZero() bool {
+	return r.KeepLast <= 0 && r.MaxAge <= 0 && r.MaxTotalSize <= 0
+}
+
+// expired returns the backups the policy would remove. Backups must be sorted
+// from oldest to newest, as returned by Store.ListBackups.
+func (r RetentionPolicy) expired(backups []BackupInfo, now time.Time) []BackupInfo {
+	if len(backups) <= 1 || r.IsZero() {
+		return nil
+	}
+
+	remove := make([]bool, len(backups))
+	newest := len(backups) - 1
+
+	if r.KeepLast > 0 {
+		for i := 0; i < len(backups)-r.KeepLast; i++ {
+			remove[i] = true
+		}
+	}
+
+	if r.MaxAge > 0 {
+		for i := 0; i < newest; i++ {
+			if now.Sub(backups[i].CreatedAt) > r.MaxAge {
+				remove[i] = true
+			}
+		}
+	}
+
+	if r.MaxTotalSize > 0 {
+		var total int64
+		for i := newest; i >= 0; i-- {
+			if remove[i] {
+				continue
+			}
+			total += backups[i].Size
+			if total > r.MaxTotalSize && i != newest {
+				remove[i] = true
+			}
+		}
+	}
+
+	var expired []BackupInfo
+	for i, backup := range backups {
+		if remove[i] {
+			expired = append(expired, backup)
+		}
+	}
+	return expired
+}
+
+// SetRetention sets the retention policy applied to automatic backups after
+// every successful Save and by PruneBackups.
+func (s *Store) SetRetention(policy RetentionPolicy) {
+	s.retention = policy
+}
+
+// PruneBackups removes the backups that fall outside the store's retention
+// policy and returns them. Snapshots referenced by the operation journal are
+// kept regardless, so that every recorded operation can still be undone.
+// With dryRun set, nothing is removed.
+func (s *Store) PruneBackups(dryRun bool) ([]BackupInfo, error) {
+	backups, err := s.ListBackups()
+	if err != nil {
+		return nil, err
+	}
+
+	referenced, err := s.journalSnapshots()
+	if err != nil {
+		return nil, err
+	}
+
+	var expired []BackupInfo
+	for _, backup := range s.retention.expired(backups, time.Now()) {
+		if !referenced[filepath.Base(backup.Path)] {
+			expired = append(expired, backup)
+		}
+	}
+	if dryRun {
+		return expired, nil
+	}
+
+	for i, backup := range expired {
+		if err := s.removeBackup(backup); err != nil {
+			return expired[:i], err
+		}
+	}
+	return expired, nil
+}
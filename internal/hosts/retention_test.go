@@ -0,0 +1,97 @@
+package hosts
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRetentionPolicy_expired(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	// Five backups of 100 bytes, one per day, oldest first
+	var backups []BackupInfo
+	for i := 4; i >= 0; i-- {
+		backups = append(backups, BackupInfo{
+			Path:      fmt.Sprintf("backup-%d", i),
+			CreatedAt: now.Add(-time.Duration(i) * 24 * time.Hour),
+			Size:      100,
+		})
+	}
+
+	tests := []struct {
+		name   string
+		policy RetentionPolicy
+		want   []string
+	}{
+		{"no limits", RetentionPolicy{}, nil},
+		{"keep last", RetentionPolicy{KeepLast: 3}, []string{"backup-4", "backup-3"}},
+		{"max age", RetentionPolicy{MaxAge: 36 * time.Hour}, []string{"backup-4", "backup-3", "backup-2"}},
+		{"max total size", RetentionPolicy{MaxTotalSize: 250}, []string{"backup-4", "backup-3", "backup-2"}},
+		{"combined", RetentionPolicy{KeepLast: 4, MaxAge: 72 * time.Hour}, []string{"backup-4"}},
+		{"newest is always kept", RetentionPolicy{MaxAge: time.Minute, MaxTotalSize: 10}, []string{"backup-4", "backup-3", "backup-2", "backup-1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, backup := range tt.policy.expired(backups, now) {
+				got = append(got, backup.Path)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("expired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStore_SavePrunesBackups(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-retention-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsPath := filepath.Join(tmpDir, "hosts")
+	if err := os.WriteFile(hostsPath, []byte("127.0.0.1\tlocalhost\n"), 0644); err != nil {
+		t.Fatalf("Failed to write hosts file: %v", err)
+	}
+
+	// Pre-existing backups from earlier saves, oldest first
+	for i := 0; i < 4; i++ {
+		path := fmt.Sprintf("%s.hostsctl.2026010%d-120000.bak", hostsPath, i+1)
+		if err := os.WriteFile(path, []byte("127.0.0.1\tlocalhost\n"), 0644); err != nil {
+			t.Fatalf("Failed to write backup: %v", err)
+		}
+		modTime := time.Now().Add(-time.Duration(4-i) * time.Hour)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("Failed to set backup time: %v", err)
+		}
+	}
+
+	store := NewStore(hostsPath, false)
+	store.SetRetention(RetentionPolicy{KeepLast: 2})
+
+	hostsFile, err := store.Load()
+	if err != nil {
+		t.Fatalf("Store.Load() error = %v", err)
+	}
+	hostsFile.AddEntry(Entry{IP: "10.0.0.1", Names: []string{"app.local"}, Block: DefaultBlock})
+
+	if err := store.Save(hostsFile); err != nil {
+		t.Fatalf("Store.Save() error = %v", err)
+	}
+
+	backups, err := store.ListBackups()
+	if err != nil {
+		t.Fatalf("Store.ListBackups() error = %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups after pruning, got %d", len(backups))
+	}
+	if filepath.Base(backups[0].Path) != "hosts.hostsctl.20260104-120000.bak" {
+		t.Errorf("Oldest kept backup = %s, want the most recent pre-existing one", backups[0].Path)
+	}
+}
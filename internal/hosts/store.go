@@ -1,266 +1,294 @@
 package hosts
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
-	"io"
 	"os"
-	"path/filepath"
+	"slices"
 	"time"
-
-	"github.com/vaxvhbe/hostsctl/pkg"
 )
 
 // Store handles atomic reading and writing of hosts files with safety features.
 // It provides backup creation, atomic writes, and permission checking.
 type Store struct {
-	path   string  // Path to the hosts file
-	parser *Parser // Parser instance for reading/writing
+	path      string          // Path to the hosts file
+	parser    *Parser         // Parser instance for reading/writing
+	retention RetentionPolicy // Which automatic backups to keep
+	backupDir string          // Directory for automatic backups ("" for next to the hosts file)
+
+	compressBackups bool          // Whether new snapshots are gzip-compressed
+	command         string        // Command line recorded in the backup manifest
+	operation       string        // Operation recorded in the journal, e.g. "add"
+	arguments       []string      // Arguments of the operation recorded in the journal
+	strategy        WriteStrategy // How the last Save wrote the hosts file
+	fingerprint     *Fingerprint  // State of the hosts file as of the last Load or Save
+	entryCount      int           // Number of entries in the hosts file as of the last Load or Save
+	loaded          []Entry       // Copy of those entries, kept only when auditing
+	auditLog        *AuditLog     // Log every change is recorded in (nil to disable)
+	dryRun          bool          // Whether Save only computes a Preview
+	preview         *Preview      // Change computed by the last dry-run Save
+	hostsFileLocked bool          // Whether the caller holds an exclusive flock on the hosts file itself
+	lockTimeout     time.Duration // How long in-place writes wait for the flock on the hosts file
 }
 
+// defaultLockTimeout is how long in-place writes wait by default for another
+// program to release its flock on the hosts file.
+const defaultLockTimeout = 5 * time.Second
+
 // NewStore creates a new Store instance for the specified hosts file path.
 // The strict parameter controls whether parsing errors should fail or be skipped.
+// Automatic backups are pruned according to DefaultRetentionPolicy.
 func NewStore(path string, strict bool) *Store {
 	return &Store{
-		path:   path,
-		parser: NewParser(strict),
+		path:        path,
+		parser:      NewParser(strict),
+		retention:   DefaultRetentionPolicy,
+		lockTimeout: defaultLockTimeout,
 	}
 }
 
 // Load reads and parses the hosts file from disk.
-// Returns a HostsFile containing all parsed entries.
+// Returns a HostsFile containing all parsed entries. The fingerprint of the
+// file is remembered so that Save can detect changes made by other programs.
 func (s *Store) Load() (*HostsFile, error) {
-	file, err := os.Open(s.path)
+	return s.LoadContext(context.Background())
+}
+
+// LoadContext is like Load but fails without reading the file if ctx is
+// already canceled.
+func (s *Store) LoadContext(ctx context.Context) (*HostsFile, error) {
+	if err := interrupted(ctx); err != nil {
+		return nil, err
+	}
+
+	data, fingerprint, err := readFingerprint(s.path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open hosts file: %w", err)
 	}
-	defer func() { _ = file.Close() }()
 
-	hostsFile, err := s.parser.Parse(file)
+	hostsFile, err := s.parser.Parse(bytes.NewReader(data))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse hosts file: %w", err)
 	}
 
 	hostsFile.Path = s.path
+	s.fingerprint = &fingerprint
+	s.remember(hostsFile)
 	return hostsFile, nil
 }
 
 // Save writes a HostsFile to disk atomically with automatic backup.
-// It checks permissions, creates a backup, writes to a temporary file,
-// and then atomically renames it to replace the original.
+// It checks permissions, refuses to modify lines outside the hostsctl managed
+// blocks, creates a backup, writes to a temporary file, and then atomically
+// renames it to replace the original. Symlinked hosts files are written
+// through to their target, and bind-mounted ones, which cannot be renamed
+// over, are rewritten in place (see WriteStrategy). The change is recorded in
+// the operation journal so that it can be undone and appended to the audit
+// log, if one is set (see SetAuditLog). Old backups are then pruned according
+// to the retention policy.
+//
+// If the file was loaded through this store and has been modified by another
+// program since, Save returns ErrConcurrentModification instead of
+// overwriting that change; Update retries the operation on the new content.
+// In dry-run mode (see SetDryRun), Save stops after computing the Preview.
 func (s *Store) Save(hostsFile *HostsFile) error {
-	if err := s.requiresRoot(); err != nil {
-		return err
-	}
-
-	content := s.parser.Serialize(hostsFile)
-
-	if err := s.createBackup(); err != nil {
-		return fmt.Errorf("failed to create backup: %w", err)
-	}
-
-	tempPath := s.path + ".tmp"
-	if err := s.writeTemp(tempPath, content); err != nil {
-		return fmt.Errorf("failed to write temporary file: %w", err)
-	}
-
-	if err := os.Rename(tempPath, s.path); err != nil {
-		_ = os.Remove(tempPath)
-		return fmt.Errorf("failed to atomically replace hosts file: %w", err)
-	}
-
-	return nil
+	return s.SaveContext(context.Background(), hostsFile)
 }
 
-// createBackup creates a timestamped backup of the current hosts file.
-// The backup file is named with the current timestamp.
-func (s *Store) createBackup() error {
-	backupPath := fmt.Sprintf("%s.hostsctl.%s.bak", s.path, time.Now().Format("20060102-150405"))
-
-	sourceFile, err := os.Open(s.path)
-	if err != nil {
-		return err
-	}
-	defer func() { _ = sourceFile.Close() }()
-
-	// Validate file path to prevent directory traversal
-	if err := pkg.ValidateSecurePath(backupPath); err != nil {
-		return fmt.Errorf("invalid backup path: %w", err)
-	}
-
-	backupFile, err := os.Create(backupPath) // #nosec G304 -- path validated above
-	if err != nil {
+// SaveContext is like Save but gives up if ctx is canceled before the hosts
+// file is replaced, removing its temporary file and leaving the hosts file
+// untouched. Once the new content is in place, the save is completed.
+func (s *Store) SaveContext(ctx context.Context, hostsFile *HostsFile) error {
+	backup, err := s.save(ctx, hostsFile)
+	if err != nil || backup == nil {
 		return err
 	}
-	defer func() { _ = backupFile.Close() }()
 
-	_, err = io.Copy(backupFile, sourceFile)
-	return err
+	// The hosts file is already written: failing to journal or prune must not report the save as failed.
+	_ = s.recordOperation(context.WithoutCancel(ctx), backup)
+	_, _ = s.PruneBackups(false)
+	return nil
 }
 
-// writeTemp writes content to a temporary file with fsync for durability.
-func (s *Store) writeTemp(tempPath, content string) error {
-	// Validate file path to prevent directory traversal
-	if err := pkg.ValidateSecurePath(tempPath); err != nil {
-		return fmt.Errorf("invalid temp path: %w", err)
+// save writes hostsFile as described for SaveContext, without journaling it
+// or pruning backups. It returns the backup of the previous content, or nil in
+// dry-run mode.
+func (s *Store) save(ctx context.Context, hostsFile *HostsFile) (*BackupInfo, error) {
+	if err := interrupted(ctx); err != nil {
+		return nil, err
 	}
 
-	tempFile, err := os.Create(tempPath) // #nosec G304 -- path validated above
-	if err != nil {
-		return err
+	if err := s.requiresRoot(); err != nil {
+		return nil, err
 	}
-	defer func() { _ = tempFile.Close() }()
 
-	if _, err := tempFile.WriteString(content); err != nil {
-		return err
+	if err := s.checkBoundaries(hostsFile); err != nil {
+		return nil, err
 	}
 
-	return tempFile.Sync()
-}
+	content := s.parser.Serialize(hostsFile)
 
-// requiresRoot checks if the operation requires root privileges.
-// Returns an error if trying to modify /etc/hosts without root access.
-func (s *Store) requiresRoot() error {
-	if s.path == "/etc/hosts" && os.Geteuid() != 0 {
-		return fmt.Errorf("modifying /etc/hosts requires root privileges (run with sudo)")
+	if s.dryRun {
+		preview, err := previewFile(s.path, content)
+		if err != nil {
+			return nil, err
+		}
+		s.preview = preview
+		return nil, nil
 	}
-	return nil
-}
 
-// Backup creates a manual backup of the hosts file to the specified path.
-// If outputPath is empty, generates a timestamped filename.
-// Returns BackupInfo with metadata about the created backup.
-func (s *Store) Backup(outputPath string) (*BackupInfo, error) {
-	if outputPath == "" {
-		outputPath = fmt.Sprintf("%s.hostsctl.%s.bak", s.path, time.Now().Format("20060102-150405"))
+	// A change that will be refused must not leave a snapshot behind
+	if err := s.checkUnmodified(); err != nil {
+		return nil, err
 	}
 
-	sourceFile, err := os.Open(s.path)
+	backup, err := s.createBackup()
 	if err != nil {
-		return nil, fmt.Errorf("failed to open source file: %w", err)
+		return nil, fmt.Errorf("failed to create backup: %w", err)
 	}
-	defer func() { _ = sourceFile.Close() }()
 
-	stat, err := sourceFile.Stat()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get source file info: %w", err)
+	// Open the audit log first: a change that cannot be audited is not written
+	var auditFile *os.File
+	if s.auditLog != nil {
+		if auditFile, err = s.auditLog.open(); err != nil {
+			return nil, err
+		}
+		defer func() { _ = auditFile.Close() }()
 	}
 
-	// Validate file path to prevent directory traversal
-	if err := pkg.ValidateSecurePath(outputPath); err != nil {
-		return nil, fmt.Errorf("invalid output path: %w", err)
+	var previous []Entry
+	if auditFile != nil {
+		previous = s.previousEntries(backup)
 	}
 
-	backupFile, err := os.Create(outputPath) // #nosec G304 -- path validated above
+	strategy, err := s.write(ctx, content, backup)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create backup file: %w", err)
-	}
-	defer func() { _ = backupFile.Close() }()
-
-	_, err = io.Copy(backupFile, sourceFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to copy file contents: %w", err)
+		return nil, err
 	}
+	s.strategy = strategy
 
-	return &BackupInfo{
-		Path:      outputPath,
-		Original:  s.path,
-		CreatedAt: time.Now(),
-		Size:      stat.Size(),
-	}, nil
-}
-
-// Restore replaces the current hosts file with content from a backup.
-// The backup file is validated before restoration.
-func (s *Store) Restore(backupPath string) error {
-	if err := s.requiresRoot(); err != nil {
-		return err
+	if _, fingerprint, err := readFingerprint(s.path); err == nil {
+		s.fingerprint = &fingerprint
+		s.remember(hostsFile)
 	}
 
-	// Validate file path to prevent directory traversal
-	if err := pkg.ValidateSecurePath(backupPath); err != nil {
-		return fmt.Errorf("invalid backup path: %w", err)
+	if auditFile != nil {
+		sum := sha256.Sum256([]byte(content))
+		record := s.auditRecord(previous, hostsFile, hex.EncodeToString(sum[:]))
+		if err := s.auditLog.append(auditFile, record); err != nil {
+			return nil, fmt.Errorf("hosts file was written but not audited: %w", err)
+		}
 	}
+	return backup, nil
+}
 
-	backupFile, err := os.Open(backupPath) // #nosec G304 -- path validated above
-	if err != nil {
-		return fmt.Errorf("failed to open backup file: %w", err)
+// remember records the entries of hostsFile as those of the hosts file on
+// disk: their number for the backup manifest and, when auditing, a copy to
+// compare the next save against. The copy, down to the names and tags of each
+// entry, is taken because the caller goes on modifying hostsFile.
+func (s *Store) remember(hostsFile *HostsFile) {
+	s.entryCount = len(hostsFile.Entries)
+	s.loaded = nil
+	if s.auditLog != nil {
+		s.loaded = make([]Entry, len(hostsFile.Entries))
+		for i, entry := range hostsFile.Entries {
+			entry.Names = slices.Clone(entry.Names)
+			entry.Tags = slices.Clone(entry.Tags)
+			s.loaded[i] = entry
+		}
 	}
-	defer func() { _ = backupFile.Close() }()
+}
 
-	hostsFile, err := s.parser.Parse(backupFile)
-	if err != nil {
-		return fmt.Errorf("failed to parse backup file: %w", err)
+// interrupted returns an error wrapping the error of ctx if it is done. It is
+// checked wherever an operation can still be abandoned without leaving
+// anything behind.
+func interrupted(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("interrupted: %w", err)
 	}
+	return nil
+}
 
-	hostsFile.Path = s.path
-	return s.Save(hostsFile)
+// SetHostsFileLocked tells the store whether the caller holds an exclusive
+// flock on the hosts file itself, as with lock.ModeHostsFile. Save then
+// always rewrites the file in place (see WriteInPlace), so that the locked
+// inode stays the hosts file, and relies on that lock instead of locking the
+// file again, which would deadlock.
+func (s *Store) SetHostsFileLocked(locked bool) {
+	s.hostsFileLocked = locked
 }
 
-// ListBackups finds and returns information about all backup files.
-// Searches for files matching the hostsctl backup naming pattern.
-func (s *Store) ListBackups() ([]BackupInfo, error) {
-	dir := filepath.Dir(s.path)
-	base := filepath.Base(s.path)
-	pattern := base + ".hostsctl.*.bak"
+// SetLockTimeout sets how long an in-place write waits for other programs
+// that flock the hosts file to release it.
+func (s *Store) SetLockTimeout(timeout time.Duration) {
+	s.lockTimeout = timeout
+}
 
-	matches, err := filepath.Glob(filepath.Join(dir, pattern))
-	if err != nil {
-		return nil, fmt.Errorf("failed to list backups: %w", err)
-	}
+// WriteStrategy returns how the last successful Save wrote the hosts file,
+// or "" if nothing was saved yet.
+func (s *Store) WriteStrategy() WriteStrategy {
+	return s.strategy
+}
 
-	var backups []BackupInfo
-	for _, match := range matches {
-		stat, err := os.Stat(match)
-		if err != nil {
+// checkBoundaries ensures that saving the HostsFile only changes lines inside
+// managed blocks. Unmanaged entries may be adopted into a block, but they must
+// not be modified or removed.
+func (s *Store) checkBoundaries(hostsFile *HostsFile) error {
+	for i, line := range hostsFile.Lines {
+		if line.Kind != LineEntry || line.Block != "" {
 			continue
 		}
 
-		backups = append(backups, BackupInfo{
-			Path:      match,
-			Original:  s.path,
-			CreatedAt: stat.ModTime(),
-			Size:      stat.Size(),
-		})
+		entry := hostsFile.FindByID(line.EntryID)
+		if entry == nil {
+			return fmt.Errorf("line %d is not managed by hostsctl and cannot be removed (run 'hostsctl adopt' first)", i+1)
+		}
+
+		if !entry.IsManaged() && s.parser.renderEntry(entry) != entry.Raw {
+			return fmt.Errorf("entry %d on line %d is not managed by hostsctl and cannot be modified (run 'hostsctl adopt' first)", entry.ID, i+1)
+		}
 	}
 
-	return backups, nil
+	return nil
 }
 
-// Verify checks the hosts file for syntax errors and inconsistencies.
-// Returns a list of issues found, or an empty slice if the file is valid.
+// requiresRoot checks if the operation requires root privileges.
+// Returns an error if trying to modify /etc/hosts without root access.
+// Dry runs write nothing and are allowed for everyone.
+func (s *Store) requiresRoot() error {
+	if s.dryRun {
+		return nil
+	}
+	if s.path == "/etc/hosts" && os.Geteuid() != 0 {
+		return fmt.Errorf("modifying /etc/hosts requires root privileges (run with sudo)")
+	}
+	return nil
+}
+
+// Verify checks the hosts file for syntax errors and inconsistencies with
+// the default lint rules (see Lint). Returns a description of each finding,
+// or an empty slice if the file is valid.
 func (s *Store) Verify() ([]string, error) {
-	hostsFile, err := s.Load()
+	findings, err := s.Lint(NewLinter())
 	if err != nil {
 		return nil, err
 	}
 
 	var issues []string
-	seenNames := make(map[string][]int)
-
-	for _, entry := range hostsFile.Entries {
-		if !entry.IsValid() {
-			issues = append(issues, fmt.Sprintf("entry %d: invalid entry (missing IP or names)", entry.ID))
-			continue
-		}
-
-		if !s.parser.isValidIP(entry.IP) {
-			issues = append(issues, fmt.Sprintf("entry %d: invalid IP address: %s", entry.ID, entry.IP))
-		}
-
-		for _, name := range entry.Names {
-			if !s.parser.isValidHostname(name) {
-				issues = append(issues, fmt.Sprintf("entry %d: invalid hostname: %s", entry.ID, name))
-			}
-
-			seenNames[name] = append(seenNames[name], entry.ID)
-		}
+	for _, finding := range findings {
+		issues = append(issues, finding.String())
 	}
+	return issues, nil
+}
 
-	for name, ids := range seenNames {
-		if len(ids) > 1 {
-			issues = append(issues, fmt.Sprintf("duplicate hostname '%s' found in entries: %v", name, ids))
-		}
+// Lint loads the hosts file and runs the rules of linter on it.
+func (s *Store) Lint(linter *Linter) ([]Finding, error) {
+	hostsFile, err := s.Load()
+	if err != nil {
+		return nil, err
 	}
-
-	return issues, nil
+	return linter.Lint(hostsFile), nil
 }
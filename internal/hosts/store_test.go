@@ -195,6 +195,18 @@ func TestStore_Verify(t *testing.T) {
 			wantIssues:  1,
 			description: "Empty hostname should be detected",
 		},
+		{
+			name:        "internationalized hostname",
+			content:     "127.0.0.1\txn--bcher-kva.example",
+			wantIssues:  0,
+			description: "Punycode hostnames should be accepted",
+		},
+		{
+			name:        "confusable hostname",
+			content:     "127.0.0.1\txn--pple-43d.com\n127.0.0.1\txn--80ak6aa92e.com",
+			wantIssues:  2,
+			description: "Mixed-script and whole-script confusable labels should be flagged",
+		},
 	}
 
 	for _, tt := range tests {
@@ -430,3 +442,146 @@ func TestStore_LoadCorruptedFile(t *testing.T) {
 		t.Errorf("Corrupted file should result in 0 entries, got %d", len(hostsData.Entries))
 	}
 }
+
+func TestStore_SaveUnchangedIsIdentical(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-store-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	content := "# Managed by hand\n\n127.0.0.1   localhost\t# loopback\n\n# 10.0.0.1 old.local\nbogus line\n"
+
+	if err := os.WriteFile(hostsFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create hosts file: %v", err)
+	}
+
+	store := NewStore(hostsFile, false)
+
+	hostsData, err := store.Load()
+	if err != nil {
+		t.Fatalf("Store.Load() error = %v", err)
+	}
+
+	if err := store.Save(hostsData); err != nil {
+		t.Fatalf("Store.Save() error = %v", err)
+	}
+
+	savedContent, err := os.ReadFile(hostsFile)
+	if err != nil {
+		t.Fatalf("Failed to read saved file: %v", err)
+	}
+
+	if string(savedContent) != content {
+		t.Errorf("Load/Save changed file:\nBefore: %q\nAfter:  %q", content, string(savedContent))
+	}
+}
+
+func TestStore_SaveRespectsBlockBoundaries(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-store-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	content := "127.0.0.1\tlocalhost\n10.0.0.1\tapp.local\n# BEGIN hostsctl\n10.0.0.2\tapi.local\n# END hostsctl\n"
+
+	tests := []struct {
+		name    string
+		mutate  func(h *HostsFile)
+		wantErr bool
+	}{
+		{"modify managed entry", func(h *HostsFile) { h.DisableEntry(h.FindByName("api.local")[0].ID) }, false},
+		{"remove managed entry", func(h *HostsFile) { h.RemoveEntry(h.FindByName("api.local")[0].ID) }, false},
+		{"modify unmanaged entry", func(h *HostsFile) { h.DisableEntry(h.FindByName("app.local")[0].ID) }, true},
+		{"remove unmanaged entry", func(h *HostsFile) { h.RemoveEntry(h.FindByName("app.local")[0].ID) }, true},
+		{"adopt unmanaged entry", func(h *HostsFile) { h.AdoptEntry(h.FindByName("app.local")[0].ID, DefaultBlock) }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(hostsFile, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to create hosts file: %v", err)
+			}
+
+			store := NewStore(hostsFile, false)
+			hostsData, err := store.Load()
+			if err != nil {
+				t.Fatalf("Store.Load() error = %v", err)
+			}
+
+			tt.mutate(hostsData)
+
+			err = store.Save(hostsData)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Store.Save() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Adopted entries move into the managed block
+	savedContent, err := os.ReadFile(hostsFile)
+	if err != nil {
+		t.Fatalf("Failed to read saved file: %v", err)
+	}
+
+	want := "127.0.0.1\tlocalhost\n# BEGIN hostsctl\n10.0.0.2\tapi.local\n10.0.0.1\tapp.local\n# END hostsctl\n"
+	if string(savedContent) != want {
+		t.Errorf("Adopted file = %q, want %q", string(savedContent), want)
+	}
+}
+
+func TestStore_BackupDir(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-store-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	if err := os.WriteFile(hostsFile, []byte("127.0.0.1\tlocalhost\n::1\tlocalhost\n"), 0644); err != nil {
+		t.Fatalf("Failed to create hosts file: %v", err)
+	}
+
+	backupDir := filepath.Join(tmpDir, "var", "backups")
+	store := NewStore(hostsFile, false)
+	store.SetBackupDir(backupDir)
+
+	backup, err := store.Backup("")
+	if err != nil {
+		t.Fatalf("Store.Backup() error = %v", err)
+	}
+	if filepath.Dir(backup.Path) != backupDir {
+		t.Errorf("Backup written to %s, want directory %s", backup.Path, backupDir)
+	}
+	if backup.ID == "" {
+		t.Error("Automatic backup should have an ID")
+	}
+
+	backups, err := store.ListBackups()
+	if err != nil {
+		t.Fatalf("Store.ListBackups() error = %v", err)
+	}
+	if len(backups) != 1 || backups[0].ID != backup.ID || backups[0].EntryCount != 2 {
+		t.Fatalf("ListBackups() = %+v, want one backup %s with 2 entries", backups, backup.ID)
+	}
+
+	found, err := store.FindBackup(backup.ID)
+	if err != nil {
+		t.Fatalf("Store.FindBackup() error = %v", err)
+	}
+	if found.Path != backup.Path {
+		t.Errorf("FindBackup() path = %s, want %s", found.Path, backup.Path)
+	}
+
+	if _, err := store.FindBackup("19700101-000000"); err == nil {
+		t.Error("FindBackup() should fail for an unknown ID")
+	}
+
+	// Nothing is written next to the hosts file
+	if matches, _ := filepath.Glob(hostsFile + ".hostsctl.*"); len(matches) != 0 {
+		t.Errorf("Unexpected backups next to the hosts file: %v", matches)
+	}
+}
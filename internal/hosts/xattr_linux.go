@@ -0,0 +1,87 @@
+//go:build linux
+
+package hosts
+
+import (
+	"bytes"
+	"errors"
+	"syscall"
+)
+
+// copyXattrs copies the extended attributes of src, such as SELinux labels,
+// onto dst. Attributes that cannot be read or set by the current user are
+// skipped, and file systems without xattr support are ignored.
+func copyXattrs(src, dst string) error {
+	names, err := listXattrs(src)
+	if err != nil {
+		if isXattrUnsupported(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, name := range names {
+		value, err := getXattr(src, name)
+		if err != nil {
+			if isXattrUnsupported(err) {
+				continue
+			}
+			return err
+		}
+
+		if err := syscall.Setxattr(dst, name, value, 0); err != nil {
+			if isXattrUnsupported(err) {
+				continue
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// listXattrs returns the names of the extended attributes of path.
+func listXattrs(path string) ([]string, error) {
+	size, err := syscall.Listxattr(path, nil)
+	if err != nil || size == 0 {
+		return nil, err
+	}
+
+	buf := make([]byte, size)
+	size, err = syscall.Listxattr(path, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, name := range bytes.Split(buf[:size], []byte{0}) {
+		if len(name) > 0 {
+			names = append(names, string(name))
+		}
+	}
+	return names, nil
+}
+
+// getXattr returns the value of the extended attribute name of path.
+func getXattr(path, name string) ([]byte, error) {
+	size, err := syscall.Getxattr(path, name, nil)
+	if err != nil || size == 0 {
+		return nil, err
+	}
+
+	value := make([]byte, size)
+	size, err = syscall.Getxattr(path, name, value)
+	if err != nil {
+		return nil, err
+	}
+	return value[:size], nil
+}
+
+// isXattrUnsupported reports whether err means that extended attributes are
+// not supported or not accessible, rather than a real I/O failure.
+func isXattrUnsupported(err error) bool {
+	return errors.Is(err, syscall.ENOTSUP) ||
+		errors.Is(err, syscall.EPERM) ||
+		errors.Is(err, syscall.EACCES) ||
+		errors.Is(err, syscall.ENODATA)
+}
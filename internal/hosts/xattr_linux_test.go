@@ -0,0 +1,49 @@
+//go:build linux
+
+package hosts
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestReplaceFile_PreservesXattrs(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "hostsctl-atomic-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	hostsFile := filepath.Join(tmpDir, "hosts")
+	if err := os.WriteFile(hostsFile, []byte("127.0.0.1\tlocalhost\n"), 0644); err != nil {
+		t.Fatalf("Failed to create hosts file: %v", err)
+	}
+
+	if err := syscall.Setxattr(hostsFile, "user.hostsctl.test", []byte("label"), 0); err != nil {
+		t.Skipf("extended attributes not supported here: %v", err)
+	}
+
+	if err := replaceFile(context.Background(), hostsFile, "10.0.0.1\tapi.local\n"); err != nil {
+		t.Fatalf("replaceFile() error = %v", err)
+	}
+
+	names, err := listXattrs(hostsFile)
+	if err != nil {
+		t.Fatalf("listXattrs() error = %v", err)
+	}
+	if !strings.Contains(strings.Join(names, ","), "user.hostsctl.test") {
+		t.Fatalf("Expected xattr to be preserved, got %v", names)
+	}
+
+	value, err := getXattr(hostsFile, "user.hostsctl.test")
+	if err != nil {
+		t.Fatalf("getXattr() error = %v", err)
+	}
+	if string(value) != "label" {
+		t.Errorf("Expected xattr value 'label', got %q", value)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package hosts
+
+// copyXattrs is a no-op on platforms without Linux extended attribute support.
+func copyXattrs(src, dst string) error {
+	return nil
+}
@@ -3,26 +3,37 @@
 package lock
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"syscall"
 	"time"
 )
 
+// errLocked is returned by acquire when another process held a conflicting
+// lock until the deadline of its context.
+var errLocked = errors.New("lock is held by another process")
+
 // FileLock represents a file lock that prevents concurrent access to a file.
-// It creates a separate .lock file and uses flock system calls for locking.
+// By default it creates a separate .lock file and uses flock system calls for
+// locking (see Mode for the alternatives). The lock is either exclusive, for
+// writers, or shared, for readers that do not block each other. Exclusive
+// holders of a lock file record their PID, command line, user and start time
+// in it so that other processes can tell who holds it (see Inspect).
 type FileLock struct {
 	file     *os.File // Lock file handle
 	path     string   // Path to the file being locked
+	lockFile string   // Path of the file that is flocked ("" for ModeNone)
+	mode     Mode     // Which file is flocked
 	acquired bool     // Whether the lock is currently held
+	shared   bool     // Whether the lock is held shared, for reading
 }
 
 // NewFileLock creates a new FileLock for the specified file path.
 // The actual lock file will be created with a .lock extension.
 func NewFileLock(path string) *FileLock {
-	return &FileLock{
-		path: path,
-	}
+	return Options{}.NewFileLock(path)
 }
 
 // Lock acquires the file lock with a default timeout of 30 seconds.
@@ -31,94 +42,206 @@ func (fl *FileLock) Lock() error {
 	return fl.LockWithTimeout(30 * time.Second)
 }
 
-// LockWithTimeout attempts to acquire the file lock within the specified timeout.
+// LockWithTimeout attempts to acquire the exclusive file lock within the specified timeout.
 // It will retry periodically until the lock is acquired or timeout is reached.
 func (fl *FileLock) LockWithTimeout(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return fl.LockContext(ctx)
+}
+
+// LockContext attempts to acquire the exclusive file lock until ctx is done.
+// It returns as soon as ctx is canceled, for example when the user interrupts
+// the command, instead of waiting for a deadline.
+func (fl *FileLock) LockContext(ctx context.Context) error {
+	err := fl.acquire(ctx, syscall.LOCK_EX)
+	if err == errLocked {
+		return fmt.Errorf("timeout waiting for lock held by %s", fl.describeHolder())
+	}
+	return err
+}
+
+// RLock acquires the shared file lock with a default timeout of 30 seconds.
+// It's a convenience method that calls RLockWithTimeout.
+func (fl *FileLock) RLock() error {
+	return fl.RLockWithTimeout(30 * time.Second)
+}
+
+// RLockWithTimeout attempts to acquire the shared file lock within the
+// specified timeout. Any number of processes can hold the shared lock at
+// once; it only waits while a process holds the exclusive lock.
+func (fl *FileLock) RLockWithTimeout(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return fl.RLockContext(ctx)
+}
+
+// RLockContext is the shared counterpart of LockContext.
+func (fl *FileLock) RLockContext(ctx context.Context) error {
+	err := fl.acquire(ctx, syscall.LOCK_SH)
+	if err == errLocked {
+		return fmt.Errorf("timeout waiting for lock held by %s", fl.describeHolder())
+	}
+	return err
+}
+
+// TryLock attempts to acquire the exclusive lock immediately without waiting.
+// Returns an error if the lock cannot be acquired right away.
+func (fl *FileLock) TryLock() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+
+	err := fl.acquire(ctx, syscall.LOCK_EX)
+	if err == errLocked {
+		return fmt.Errorf("lock is already held by %s", fl.describeHolder())
+	}
+	return err
+}
+
+// acquire takes the lock in the given flock mode, retrying until ctx is done.
+// It returns errLocked if a conflicting lock was still held at the deadline
+// of ctx, and the error of ctx if it was canceled.
+func (fl *FileLock) acquire(ctx context.Context, how int) error {
 	if fl.acquired {
 		return fmt.Errorf("lock already acquired")
 	}
 
-	file, err := os.OpenFile(fl.path+".lock", os.O_CREATE|os.O_WRONLY, 0600)
-	if err != nil {
-		return fmt.Errorf("failed to create lock file: %w", err)
+	if fl.mode == ModeNone {
+		fl.acquired = true
+		fl.shared = how == syscall.LOCK_SH
+		return nil
 	}
 
-	deadline := time.Now().Add(timeout)
 	for {
-		err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		file, err := fl.open(how)
+		if err != nil {
+			return err
+		}
+
+		err = syscall.Flock(int(file.Fd()), how|syscall.LOCK_NB)
 		if err == nil {
+			// The previous holder may have removed or replaced the file after we opened it
+			if !isCurrent(file) {
+				_ = file.Close()
+				continue
+			}
+
 			fl.file = file
 			fl.acquired = true
+			fl.shared = how == syscall.LOCK_SH
 
-			pid := fmt.Sprintf("%d\n", os.Getpid())
-			_, _ = file.WriteString(pid)
-			_ = file.Sync()
+			if !fl.shared && fl.mode == ModeSidecar {
+				writeHolder(file)
+			}
 
 			return nil
 		}
 
+		_ = file.Close()
 		if err != syscall.EAGAIN && err != syscall.EACCES {
-			_ = file.Close()
 			return fmt.Errorf("failed to acquire lock: %w", err)
 		}
 
-		if time.Now().After(deadline) {
-			_ = file.Close()
-			return fmt.Errorf("timeout waiting for lock")
+		if err := wait(ctx, 100*time.Millisecond); err != nil {
+			return err
 		}
-
-		time.Sleep(100 * time.Millisecond)
 	}
 }
 
-// TryLock attempts to acquire the lock immediately without waiting.
-// Returns an error if the lock cannot be acquired right away.
-func (fl *FileLock) TryLock() error {
-	if fl.acquired {
-		return fmt.Errorf("lock already acquired")
+// wait sleeps for the given delay before the next attempt to take the lock.
+// It returns early with errLocked once the deadline of ctx has passed, or
+// with an error wrapping ctx.Err() if ctx was canceled.
+func wait(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+		return nil
 	}
 
-	file, err := os.OpenFile(fl.path+".lock", os.O_CREATE|os.O_WRONLY, 0600)
-	if err != nil {
-		return fmt.Errorf("failed to create lock file: %w", err)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return errLocked
 	}
+	return fmt.Errorf("interrupted while waiting for lock: %w", ctx.Err())
+}
 
-	err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
-	if err != nil {
-		_ = file.Close()
-		if err == syscall.EAGAIN || err == syscall.EACCES {
-			return fmt.Errorf("lock is already held by another process")
+// open opens the file to flock. A sidecar lock file is created if needed:
+// writers need to record themselves in it, while readers only open it for
+// reading, which works for lock files created by another user too. With
+// ModeHostsFile, the locked file itself is opened for reading.
+func (fl *FileLock) open(how int) (*os.File, error) {
+	path := fl.lockFile
+	if fl.mode == ModeHostsFile {
+		file, err := os.Open(path) // #nosec G304 -- locked path
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s for locking: %w", path, err)
 		}
-		return fmt.Errorf("failed to acquire lock: %w", err)
+		return file, nil
 	}
 
-	fl.file = file
-	fl.acquired = true
+	if how == syscall.LOCK_SH {
+		file, err := os.Open(path) // #nosec G304 -- lock file of the locked path
+		if err == nil {
+			return file, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to open lock file: %w", err)
+		}
+	}
 
-	pid := fmt.Sprintf("%d\n", os.Getpid())
-	_, _ = file.WriteString(pid)
-	_ = file.Sync()
+	flags := os.O_CREATE | os.O_WRONLY
+	if how == syscall.LOCK_SH {
+		flags = os.O_CREATE | os.O_RDONLY
+	}
 
-	return nil
+	file, err := os.OpenFile(path, flags, 0644) // #nosec G304 -- lock file of the locked path
+	if err != nil {
+		return nil, fmt.Errorf("failed to create lock file: %w", err)
+	}
+	return file, nil
 }
 
-// Unlock releases the file lock and cleans up the lock file.
-// The lock file is removed after the lock is released.
+// isCurrent reports whether the opened lock file is still the one at its path.
+func isCurrent(file *os.File) bool {
+	opened, err := file.Stat()
+	if err != nil {
+		return false
+	}
+
+	current, err := os.Stat(file.Name())
+	if err != nil {
+		return false
+	}
+	return os.SameFile(opened, current)
+}
+
+// Unlock releases the file lock. The last holder removes a sidecar lock file
+// before releasing it, so that waiting processes notice the removal and open
+// a new one. A reader is the last holder if it can upgrade to the exclusive
+// lock; otherwise the lock file is left for the other readers.
 func (fl *FileLock) Unlock() error {
 	if !fl.acquired {
 		return fmt.Errorf("lock not acquired")
 	}
 
-	err := syscall.Flock(int(fl.file.Fd()), syscall.LOCK_UN)
-	if err != nil {
-		return fmt.Errorf("failed to release lock: %w", err)
-	}
+	if fl.file != nil {
+		if fl.mode == ModeSidecar && (!fl.shared || syscall.Flock(int(fl.file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB) == nil) {
+			_ = os.Remove(fl.lockFile)
+		}
+
+		err := syscall.Flock(int(fl.file.Fd()), syscall.LOCK_UN)
+		if err != nil {
+			return fmt.Errorf("failed to release lock: %w", err)
+		}
 
-	_ = fl.file.Close()
-	_ = os.Remove(fl.path + ".lock")
+		_ = fl.file.Close()
+	}
 
 	fl.file = nil
 	fl.acquired = false
+	fl.shared = false
 
 	return nil
 }
@@ -128,21 +251,30 @@ func (fl *FileLock) IsLocked() bool {
 	return fl.acquired
 }
 
+// IsShared returns true if the lock is currently held shared by this instance.
+func (fl *FileLock) IsShared() bool {
+	return fl.acquired && fl.shared
+}
+
 // WithLock is a convenience function that acquires a lock, executes a function,
 // and automatically releases the lock when done.
 func WithLock(path string, timeout time.Duration, fn func() error) error {
-	lock := NewFileLock(path)
-
-	if err := lock.LockWithTimeout(timeout); err != nil {
-		return err
-	}
-	defer func() { _ = lock.Unlock() }()
+	return Options{Timeout: timeout}.WithLock(context.Background(), path, fn)
+}
 
-	return fn()
+// WithReadLock is the shared counterpart of WithLock (see Options.WithReadLock).
+func WithReadLock(path string, timeout time.Duration, fn func() error) error {
+	return Options{Timeout: timeout}.WithReadLock(context.Background(), path, fn)
 }
 
 // WithQuickLock is a convenience function for short operations with a 5-second timeout.
 // It's equivalent to WithLock with a 5-second timeout.
 func WithQuickLock(path string, fn func() error) error {
-	return WithLock(path, 5*time.Second, fn)
+	return WithLock(path, QuickTimeout, fn)
+}
+
+// WithQuickReadLock is the shared counterpart of WithQuickLock, with a
+// 5-second timeout.
+func WithQuickReadLock(path string, fn func() error) error {
+	return WithReadLock(path, QuickTimeout, fn)
 }
@@ -1,6 +1,8 @@
 package lock
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"sync"
@@ -148,6 +150,45 @@ func TestFileLock_LockWithTimeout(t *testing.T) {
 	_ = lock1.Unlock()
 }
 
+func TestFileLock_LockContext_Canceled(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "flock-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	testFile := filepath.Join(tmpDir, "test.txt")
+
+	lock1 := NewFileLock(testFile)
+	lock2 := NewFileLock(testFile)
+
+	if err := lock1.Lock(); err != nil {
+		t.Fatalf("Failed to acquire first lock: %v", err)
+	}
+	defer func() { _ = lock1.Unlock() }()
+
+	// Cancel the wait long before the 30-second timeout, as an interrupt would
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	go func() {
+		time.Sleep(150 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	err = lock2.LockContext(ctx)
+	duration := time.Since(start)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("LockContext() error = %v, want context.Canceled", err)
+	}
+	if duration > 2*time.Second {
+		t.Errorf("LockContext() returned after %v, should stop waiting when canceled", duration)
+	}
+	if lock2.IsLocked() {
+		t.Error("LockContext() should not hold the lock after being canceled")
+	}
+}
+
 func TestFileLock_DoubleOperations(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "flock-test")
 	if err != nil {
@@ -388,3 +429,122 @@ func TestNewFileLock(t *testing.T) {
 		t.Error("New lock should not have file handle")
 	}
 }
+
+func TestFileLock_Shared(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "flock-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	testFile := filepath.Join(tmpDir, "test.txt")
+	lockFile := testFile + ".lock"
+
+	reader1 := NewFileLock(testFile)
+	reader2 := NewFileLock(testFile)
+	writer := NewFileLock(testFile)
+
+	// Readers do not block each other
+	if err := reader1.RLockWithTimeout(100 * time.Millisecond); err != nil {
+		t.Fatalf("Failed to acquire first read lock: %v", err)
+	}
+	if err := reader2.RLockWithTimeout(100 * time.Millisecond); err != nil {
+		t.Fatalf("Failed to acquire second read lock: %v", err)
+	}
+	if !reader1.IsShared() || !reader2.IsShared() {
+		t.Error("Read locks should be shared")
+	}
+
+	// But they block writers
+	if err := writer.LockWithTimeout(100 * time.Millisecond); err == nil {
+		t.Fatal("Write lock should time out while readers hold the lock")
+	}
+
+	if err := reader1.Unlock(); err != nil {
+		t.Fatalf("Failed to release read lock: %v", err)
+	}
+	if _, err := os.Stat(lockFile); err != nil {
+		t.Error("Lock file should be kept while another reader holds it")
+	}
+	if err := reader2.Unlock(); err != nil {
+		t.Fatalf("Failed to release read lock: %v", err)
+	}
+	if _, err := os.Stat(lockFile); !os.IsNotExist(err) {
+		t.Error("Lock file should be removed by the last reader")
+	}
+
+	// Writers block readers
+	if err := writer.LockWithTimeout(100 * time.Millisecond); err != nil {
+		t.Fatalf("Failed to acquire write lock: %v", err)
+	}
+	if err := reader1.RLockWithTimeout(100 * time.Millisecond); err == nil {
+		t.Error("Read lock should time out while a writer holds the lock")
+	}
+	_ = writer.Unlock()
+}
+
+func TestWithReadLock(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "flock-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	testFile := filepath.Join(tmpDir, "test.txt")
+
+	// Writers always hold the lock alone, even when the lock file they waited
+	// on was removed by its previous holder
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	readers, writing := 0, false
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			if i%4 == 0 {
+				err := WithLock(testFile, 5*time.Second, func() error {
+					mu.Lock()
+					if readers > 0 || writing {
+						t.Error("Writer should hold the lock alone")
+					}
+					writing = true
+					mu.Unlock()
+
+					time.Sleep(20 * time.Millisecond)
+
+					mu.Lock()
+					writing = false
+					mu.Unlock()
+					return nil
+				})
+				if err != nil {
+					t.Errorf("WithLock() error = %v", err)
+				}
+				return
+			}
+
+			err := WithReadLock(testFile, 5*time.Second, func() error {
+				mu.Lock()
+				if writing {
+					t.Error("Reader should not run while a writer holds the lock")
+				}
+				readers++
+				mu.Unlock()
+
+				time.Sleep(50 * time.Millisecond)
+
+				mu.Lock()
+				readers--
+				mu.Unlock()
+				return nil
+			})
+			if err != nil {
+				t.Errorf("WithReadLock() error = %v", err)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,253 @@
+package lock
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Holder describes the process holding a lock, as recorded in the lock file.
+type Holder struct {
+	PID     int       `json:"pid"`               // Process ID of the holder
+	Command string    `json:"command,omitempty"` // Command line of the holder
+	User    string    `json:"user,omitempty"`    // User running the holder, from SUDO_USER when run through sudo
+	Started time.Time `json:"started"`           // When the lock was acquired
+}
+
+// Status describes the lock of a file as seen from outside the holder.
+type Status struct {
+	Mode   Mode    `json:"mode"`             // Which file is flocked
+	Path   string  `json:"path"`             // Path of the flocked file ("" for ModeNone)
+	Exists bool    `json:"exists"`           // Whether the lock file exists
+	Held   bool    `json:"held"`             // Whether a process holds the lock
+	Holder *Holder `json:"holder,omitempty"` // Holder recorded in the lock file, if any
+	Stale  bool    `json:"stale"`            // Whether the lock is not held and its recorded holder is no longer running
+}
+
+// currentHolder describes the current process.
+func currentHolder() Holder {
+	name := os.Getenv("SUDO_USER")
+	if name == "" {
+		if current, err := user.Current(); err == nil {
+			name = current.Username
+		} else {
+			name = strconv.Itoa(os.Getuid())
+		}
+	}
+
+	command := strings.Join(append([]string{filepath.Base(os.Args[0])}, os.Args[1:]...), " ")
+	return Holder{
+		PID:     os.Getpid(),
+		Command: command,
+		User:    name,
+		Started: time.Now(),
+	}
+}
+
+// writeHolder records the current process in the lock file it just locked.
+func writeHolder(file *os.File) {
+	data, err := json.Marshal(currentHolder())
+	if err != nil {
+		return
+	}
+
+	_ = file.Truncate(0)
+	_, _ = file.WriteAt(append(data, '\n'), 0)
+	_ = file.Sync()
+}
+
+// ReadHolder returns the holder recorded in the sidecar lock file of path, or
+// nil if there is no lock file or it records no holder.
+func ReadHolder(path string) (*Holder, error) {
+	return readHolder(Options{}.LockFile(path))
+}
+
+// readHolder returns the holder recorded in lockFile. Lock files written by
+// older versions, which only hold a PID, are understood too.
+func readHolder(lockFile string) (*Holder, error) {
+	data, err := os.ReadFile(lockFile) // #nosec G304 -- lock file of the locked path
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock file: %w", err)
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	holder := &Holder{}
+	if err := json.Unmarshal(data, holder); err == nil {
+		return holder, nil
+	}
+
+	pid, err := strconv.Atoi(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("lock file %s does not record a holder", lockFile)
+	}
+	return &Holder{PID: pid}, nil
+}
+
+// Alive reports whether the holder's process is still running.
+func (h *Holder) Alive() bool {
+	if h.PID <= 0 {
+		return false
+	}
+
+	err := syscall.Kill(h.PID, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// String describes the holder for messages, e.g.
+// "PID 1234 (hostsctl add ..., user root, since 2024-01-02T15:04:05Z)".
+func (h *Holder) String() string {
+	var details []string
+	if h.Command != "" {
+		details = append(details, h.Command)
+	}
+	if h.User != "" {
+		details = append(details, "user "+h.User)
+	}
+	if !h.Started.IsZero() {
+		details = append(details, "since "+h.Started.Format(time.RFC3339))
+	}
+
+	if len(details) == 0 {
+		return fmt.Sprintf("PID %d", h.PID)
+	}
+	return fmt.Sprintf("PID %d (%s)", h.PID, strings.Join(details, ", "))
+}
+
+// Inspect returns the status of the sidecar lock of path without acquiring it.
+func Inspect(path string) (*Status, error) {
+	return Options{}.Inspect(path)
+}
+
+// Break removes the sidecar lock file of path (see Options.Break).
+func Break(path string) (*Status, error) {
+	return Options{}.Break(path)
+}
+
+// Inspect returns the status of the lock of path without acquiring it.
+func (o Options) Inspect(path string) (*Status, error) {
+	lock := o.NewFileLock(path)
+	status := &Status{Mode: lock.mode, Path: lock.lockFile}
+	if lock.mode == ModeNone {
+		return status, nil
+	}
+
+	file, err := os.Open(status.Path) // #nosec G304 -- lock file of the locked path
+	if os.IsNotExist(err) {
+		return status, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+	status.Exists = true
+
+	switch err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err {
+	case nil:
+		_ = syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+	case syscall.EAGAIN, syscall.EACCES:
+		status.Held = true
+	default:
+		return nil, fmt.Errorf("failed to test lock: %w", err)
+	}
+
+	if lock.mode == ModeSidecar {
+		if status.Holder, err = readHolder(status.Path); err != nil {
+			return nil, err
+		}
+		// A held lock is never stale: the recorded holder may be gone, but
+		// another process, such as a reader, still has the file locked
+		status.Stale = !status.Held && status.Holder != nil && !status.Holder.Alive()
+	}
+	return status, nil
+}
+
+// Break removes the lock file of path, left behind by a holder that is no
+// longer running. It refuses to break a lock that is held by any process,
+// whatever holder is recorded, or whose recorded holder is still running. The
+// returned status describes the lock as it was before breaking it.
+func (o Options) Break(path string) (*Status, error) {
+	status, err := o.Inspect(path)
+	if err != nil || !status.Exists {
+		return status, err
+	}
+
+	if err := status.Breakable(); err != nil {
+		return status, err
+	}
+
+	// The file is removed while holding its lock, so that no process can
+	// take the lock between the check above and the removal
+	file, err := os.Open(status.Path) // #nosec G304 -- lock file of the locked path
+	if os.IsNotExist(err) {
+		return status, nil
+	}
+	if err != nil {
+		return status, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	switch err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err {
+	case nil:
+		defer func() { _ = syscall.Flock(int(file.Fd()), syscall.LOCK_UN) }()
+	case syscall.EAGAIN, syscall.EACCES:
+		return status, fmt.Errorf("lock was taken by another process while breaking it")
+	default:
+		return status, fmt.Errorf("failed to lock lock file: %w", err)
+	}
+
+	if !isCurrent(file) {
+		if _, err := os.Stat(status.Path); os.IsNotExist(err) {
+			return status, nil
+		}
+		return status, fmt.Errorf("lock file %s was replaced while breaking it", status.Path)
+	}
+
+	if err := os.Remove(status.Path); err != nil && !os.IsNotExist(err) {
+		return status, fmt.Errorf("failed to remove lock file: %w", err)
+	}
+	return status, nil
+}
+
+// Breakable returns an error explaining why Break would refuse to remove the
+// lock file, or nil if it may be removed.
+func (s *Status) Breakable() error {
+	if s.Mode != ModeSidecar {
+		return fmt.Errorf("there is no lock file to break with lock mode %s", s.Mode)
+	}
+	if s.Holder != nil && s.Holder.Alive() {
+		return fmt.Errorf("lock is held by %s, which is still running", s.Holder)
+	}
+	if s.Held {
+		return fmt.Errorf("lock is held by a process that did not record itself in %s", s.Path)
+	}
+	return nil
+}
+
+// describeHolder names the holder of the lock for error messages.
+func (fl *FileLock) describeHolder() string {
+	if fl.mode != ModeSidecar {
+		return "another process"
+	}
+
+	// The lock is held: a recorded holder that is no longer running was
+	// replaced by a process that did not record itself, such as a reader
+	holder, err := readHolder(fl.lockFile)
+	if err != nil || holder == nil || !holder.Alive() {
+		return "another process"
+	}
+	return holder.String()
+}
@@ -0,0 +1,148 @@
+package lock
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// deadPID returns the PID of a process that has already exited.
+func deadPID(t *testing.T) int {
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("Cannot run a child process: %v", err)
+	}
+	return cmd.Process.Pid
+}
+
+func TestFileLock_RecordsHolder(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "flock-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	testFile := filepath.Join(tmpDir, "test.txt")
+
+	lock1 := NewFileLock(testFile)
+	if err := lock1.Lock(); err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+	defer func() { _ = lock1.Unlock() }()
+
+	holder, err := ReadHolder(testFile)
+	if err != nil {
+		t.Fatalf("ReadHolder() error = %v", err)
+	}
+	if holder == nil || holder.PID != os.Getpid() || holder.Command == "" || holder.Started.IsZero() {
+		t.Fatalf("ReadHolder() = %+v, want the current process", holder)
+	}
+	if !holder.Alive() {
+		t.Error("The current process should be alive")
+	}
+
+	status, err := Inspect(testFile)
+	if err != nil {
+		t.Fatalf("Inspect() error = %v", err)
+	}
+	if !status.Exists || !status.Held || status.Stale {
+		t.Errorf("Inspect() = %+v, want a held lock", status)
+	}
+
+	lock2 := NewFileLock(testFile)
+	err = lock2.LockWithTimeout(100 * time.Millisecond)
+	if err == nil {
+		t.Fatal("Second lock should time out")
+	}
+	if !strings.Contains(err.Error(), "PID "+strconv.Itoa(os.Getpid())) {
+		t.Errorf("Timeout error should name the holder, got %v", err)
+	}
+
+	if _, err := Break(testFile); err == nil {
+		t.Error("Break() should refuse to break a lock whose holder is running")
+	}
+}
+
+func TestBreak_StaleLock(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "flock-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	testFile := filepath.Join(tmpDir, "test.txt")
+	lockFile := testFile + ".lock"
+
+	status, err := Break(testFile)
+	if err != nil || status.Exists {
+		t.Fatalf("Break() without lock file = %+v, %v", status, err)
+	}
+
+	// Lock files of older versions only record the PID
+	pid := deadPID(t)
+	if err := os.WriteFile(lockFile, []byte(strconv.Itoa(pid)+"\n"), 0600); err != nil {
+		t.Fatalf("Failed to write lock file: %v", err)
+	}
+
+	status, err = Inspect(testFile)
+	if err != nil {
+		t.Fatalf("Inspect() error = %v", err)
+	}
+	if status.Held || !status.Stale || status.Holder == nil || status.Holder.PID != pid {
+		t.Errorf("Inspect() = %+v, want a stale lock of PID %d", status, pid)
+	}
+
+	if _, err := Break(testFile); err != nil {
+		t.Fatalf("Break() error = %v", err)
+	}
+	if _, err := os.Stat(lockFile); !os.IsNotExist(err) {
+		t.Error("Break() should remove the stale lock file")
+	}
+}
+
+func TestBreak_RefusesHeldLockWithDeadHolder(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "flock-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	testFile := filepath.Join(tmpDir, "test.txt")
+	lockFile := testFile + ".lock"
+
+	// A killed writer left its record behind, and a reader now holds the lock
+	pid := deadPID(t)
+	if err := os.WriteFile(lockFile, []byte(strconv.Itoa(pid)+"\n"), 0600); err != nil {
+		t.Fatalf("Failed to write lock file: %v", err)
+	}
+
+	reader := NewFileLock(testFile)
+	if err := reader.RLock(); err != nil {
+		t.Fatalf("RLock() error = %v", err)
+	}
+	defer func() { _ = reader.Unlock() }()
+
+	status, err := Inspect(testFile)
+	if err != nil {
+		t.Fatalf("Inspect() error = %v", err)
+	}
+	if !status.Held || status.Stale {
+		t.Errorf("Inspect() = %+v, want a held lock that is not stale", status)
+	}
+
+	if _, err := Break(testFile); err == nil {
+		t.Error("Break() should refuse to break a held lock")
+	}
+	if _, err := os.Stat(lockFile); err != nil {
+		t.Errorf("Lock file should be kept: %v", err)
+	}
+
+	err = NewFileLock(testFile).TryLock()
+	if err == nil || strings.Contains(err.Error(), "lock break") {
+		t.Errorf("TryLock() error = %v, want a held lock without a hint to break it", err)
+	}
+}
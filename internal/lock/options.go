@@ -0,0 +1,119 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// Mode selects which file is flocked to lock a file.
+type Mode string
+
+const (
+	// ModeSidecar locks a separate "<name>.lock" file, next to the locked
+	// file or in Options.Dir. It is the default.
+	ModeSidecar Mode = "sidecar"
+	// ModeHostsFile locks the file itself, which interoperates with other
+	// tools that flock /etc/hosts directly. No holder is recorded. Since the
+	// flock belongs to the file's inode, the file must then be rewritten in
+	// place rather than replaced by a new file.
+	ModeHostsFile Mode = "hosts-file"
+	// ModeNone disables locking.
+	ModeNone Mode = "none"
+)
+
+// QuickTimeout is the timeout of WithQuickLock and WithQuickReadLock.
+const QuickTimeout = 5 * time.Second
+
+// ParseMode parses a lock mode. An empty string selects ModeSidecar.
+func ParseMode(mode string) (Mode, error) {
+	switch Mode(mode) {
+	case "", ModeSidecar:
+		return ModeSidecar, nil
+	case ModeHostsFile, ModeNone:
+		return Mode(mode), nil
+	default:
+		return "", fmt.Errorf("unknown lock mode '%s' (expected %s, %s or %s)", mode, ModeSidecar, ModeHostsFile, ModeNone)
+	}
+}
+
+// Options configure how files are locked. The zero value locks a sidecar
+// file next to the locked file and tries only once.
+type Options struct {
+	Mode    Mode          // Which file is flocked ("" for ModeSidecar)
+	Dir     string        // Directory of sidecar lock files ("" for next to the locked file)
+	Timeout time.Duration // How long to wait for the lock
+}
+
+// LockFile returns the file that is flocked to lock path, or "" if locking
+// is disabled.
+func (o Options) LockFile(path string) string {
+	switch o.Mode {
+	case ModeNone:
+		return ""
+	case ModeHostsFile:
+		return path
+	}
+
+	if o.Dir == "" {
+		return path + ".lock"
+	}
+	return filepath.Join(o.Dir, filepath.Base(path)+".lock")
+}
+
+// NewFileLock creates a FileLock for path that locks according to the options.
+func (o Options) NewFileLock(path string) *FileLock {
+	mode := o.Mode
+	if mode == "" {
+		mode = ModeSidecar
+	}
+
+	return &FileLock{
+		path:     path,
+		lockFile: o.LockFile(path),
+		mode:     mode,
+	}
+}
+
+// WithLock acquires the exclusive lock of path within the timeout, executes
+// fn, and releases the lock when done. Waiting for the lock stops early if
+// ctx is canceled.
+func (o Options) WithLock(ctx context.Context, path string, fn func() error) error {
+	lock := o.NewFileLock(path)
+
+	if err := o.acquire(ctx, lock.LockContext); err != nil {
+		return err
+	}
+	defer func() { _ = lock.Unlock() }()
+
+	return fn()
+}
+
+// WithReadLock is like WithLock but takes the shared lock, so that readers do
+// not block each other but wait for a writer holding the exclusive lock.
+// If the lock file cannot be opened or created, for example because an
+// unprivileged user reads /etc/hosts, fn is run without the lock.
+func (o Options) WithReadLock(ctx context.Context, path string, fn func() error) error {
+	lock := o.NewFileLock(path)
+
+	if err := o.acquire(ctx, lock.RLockContext); err != nil {
+		if errors.Is(err, os.ErrPermission) || errors.Is(err, syscall.EROFS) {
+			return fn()
+		}
+		return err
+	}
+	defer func() { _ = lock.Unlock() }()
+
+	return fn()
+}
+
+// acquire takes a lock with lockContext, waiting at most for the timeout.
+func (o Options) acquire(ctx context.Context, lockContext func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, o.Timeout)
+	defer cancel()
+	return lockContext(ctx)
+}
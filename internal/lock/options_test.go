@@ -0,0 +1,111 @@
+package lock
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Mode
+		wantErr bool
+	}{
+		{"", ModeSidecar, false},
+		{"sidecar", ModeSidecar, false},
+		{"hosts-file", ModeHostsFile, false},
+		{"none", ModeNone, false},
+		{"flock", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseMode(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseMode(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParseMode(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestOptions_LockFile(t *testing.T) {
+	tests := []struct {
+		options Options
+		want    string
+	}{
+		{Options{}, "/etc/hosts.lock"},
+		{Options{Mode: ModeSidecar, Dir: "/run/lock"}, "/run/lock/hosts.lock"},
+		{Options{Mode: ModeHostsFile, Dir: "/run/lock"}, "/etc/hosts"},
+		{Options{Mode: ModeNone}, ""},
+	}
+
+	for _, tt := range tests {
+		if got := tt.options.LockFile("/etc/hosts"); got != tt.want {
+			t.Errorf("%+v.LockFile() = %q, want %q", tt.options, got, tt.want)
+		}
+	}
+}
+
+func TestOptions_ModeHostsFile(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "flock-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	testFile := filepath.Join(tmpDir, "hosts")
+	if err := os.WriteFile(testFile, []byte("127.0.0.1\tlocalhost\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	// Another tool flocks the hosts file directly
+	other, err := os.Open(testFile)
+	if err != nil {
+		t.Fatalf("Failed to open test file: %v", err)
+	}
+	defer func() { _ = other.Close() }()
+	if err := syscall.Flock(int(other.Fd()), syscall.LOCK_EX); err != nil {
+		t.Fatalf("Failed to flock test file: %v", err)
+	}
+
+	options := Options{Mode: ModeHostsFile, Timeout: 100 * time.Millisecond}
+	if err := options.WithLock(context.Background(), testFile, func() error { return nil }); err == nil {
+		t.Error("WithLock() should time out while another tool flocks the hosts file")
+	}
+
+	status, err := options.Inspect(testFile)
+	if err != nil {
+		t.Fatalf("Inspect() error = %v", err)
+	}
+	if !status.Held || status.Path != testFile {
+		t.Errorf("Inspect() = %+v, want the hosts file held", status)
+	}
+	if _, err := options.Break(testFile); err == nil {
+		t.Error("Break() should refuse to break the lock of the hosts file itself")
+	}
+
+	_ = syscall.Flock(int(other.Fd()), syscall.LOCK_UN)
+
+	if err := options.WithLock(context.Background(), testFile, func() error { return nil }); err != nil {
+		t.Errorf("WithLock() error = %v", err)
+	}
+	if _, err := os.Stat(testFile + ".lock"); !os.IsNotExist(err) {
+		t.Error("No lock file should be created with lock mode hosts-file")
+	}
+	if _, err := os.Stat(testFile); err != nil {
+		t.Errorf("The hosts file must be kept: %v", err)
+	}
+
+	// Locking is disabled altogether with ModeNone
+	if err := syscall.Flock(int(other.Fd()), syscall.LOCK_EX); err != nil {
+		t.Fatalf("Failed to flock test file: %v", err)
+	}
+	if err := (Options{Mode: ModeNone}).WithLock(context.Background(), testFile, func() error { return nil }); err != nil {
+		t.Errorf("WithLock() with lock mode none error = %v", err)
+	}
+}
@@ -80,6 +80,11 @@ func (m *Manager) SaveProfile(profile *hosts.Profile) error {
 		return err
 	}
 
+	// Profiles are applied to a managed block named after them
+	if hosts.IsReservedBlock(profile.Name) {
+		return fmt.Errorf("profile name is reserved: %s", profile.Name)
+	}
+
 	profile.UpdatedAt = time.Now()
 	if profile.CreatedAt.IsZero() {
 		profile.CreatedAt = time.Now()
@@ -322,6 +327,12 @@ func (m *Manager) ImportProfile(filePath, format string, overwrite bool) (*hosts
 		profile.Name = strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
 	}
 
+	for i := range profile.Entries {
+		if err := profile.Entries[i].ToASCIINames(); err != nil {
+			return nil, fmt.Errorf("invalid entry %d in import file: %w", i+1, err)
+		}
+	}
+
 	if !overwrite && m.ExistsProfile(profile.Name) {
 		return nil, fmt.Errorf("profile '%s' already exists (use --overwrite to replace)", profile.Name)
 	}
@@ -282,6 +282,7 @@ func TestManager_ProfileValidation(t *testing.T) {
 		"test>bad",  // contains greater than
 		"test|bad",  // contains pipe
 		"test\nbad", // contains newline
+		"default",   // name of the default managed block
 	}
 
 	for _, name := range invalidNames {
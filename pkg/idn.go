@@ -0,0 +1,147 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// acePrefix marks a hostname label encoded with punycode (the ACE form).
+const acePrefix = "xn--"
+
+// ToASCII converts a hostname to the ASCII-compatible (ACE) form that resolvers
+// match against, with the UTS #46 lookup profile of IDNA: labels are mapped
+// (lowercased and normalized, so that decomposed and composed spellings of a
+// name give the same result), validated and punycode-encoded with the "xn--"
+// prefix. ASCII hostnames are returned unchanged.
+func ToASCII(hostname string) (string, error) {
+	if isASCII(hostname) {
+		return hostname, nil
+	}
+
+	ascii, err := idna.Lookup.ToASCII(hostname)
+	if err != nil {
+		return "", fmt.Errorf("invalid internationalized hostname %q: %w", hostname, err)
+	}
+	return ascii, nil
+}
+
+// ToUnicode converts the punycode labels of a hostname back to Unicode for
+// display. Hostnames that do not decode to a valid internationalized name,
+// which could put control or bidi override characters on the terminal, are
+// returned unchanged in their ACE form.
+func ToUnicode(hostname string) string {
+	if !strings.Contains(strings.ToLower(hostname), acePrefix) {
+		return hostname
+	}
+
+	unicodeName, err := idna.Lookup.ToUnicode(hostname)
+	if err != nil {
+		return hostname
+	}
+	return unicodeName
+}
+
+// IsIDN reports whether a hostname contains internationalized labels, either
+// as Unicode or in punycode form.
+func IsIDN(hostname string) bool {
+	return !isASCII(hostname) || ToUnicode(hostname) != hostname
+}
+
+// CheckConfusable inspects the Unicode form of a hostname for labels that could
+// be used to impersonate another name: labels mixing letters from several
+// scripts, and labels written entirely with non-Latin letters that look like
+// Latin ones (such as Cyrillic "аpple"). It returns one message per problem.
+func CheckConfusable(hostname string) []string {
+	var problems []string
+	for _, label := range strings.Split(ToUnicode(hostname), ".") {
+		if isASCII(label) {
+			continue
+		}
+
+		scripts := labelScripts(label)
+		switch {
+		case len(scripts) > 1 && !allowedScriptMix(scripts):
+			problems = append(problems, fmt.Sprintf("label %q mixes %s scripts", label, strings.Join(scripts, " and ")))
+		case len(scripts) == 1 && scripts[0] != "Latin" && latinLookalike(label):
+			problems = append(problems, fmt.Sprintf("label %q is written in %s but looks like a Latin name", label, scripts[0]))
+		}
+	}
+	return problems
+}
+
+// isASCII reports whether s only contains ASCII characters.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// checkedScripts are the scripts considered when looking for mixed-script labels.
+var checkedScripts = []string{
+	"Latin", "Cyrillic", "Greek", "Armenian", "Hebrew", "Arabic", "Georgian",
+	"Cherokee", "Han", "Hiragana", "Katakana", "Hangul", "Thai", "Devanagari",
+}
+
+// labelScripts returns the scripts of the letters in a label, in checkedScripts order.
+func labelScripts(label string) []string {
+	var scripts []string
+	for _, name := range checkedScripts {
+		table := unicode.Scripts[name]
+		for _, r := range label {
+			if unicode.Is(table, r) {
+				scripts = append(scripts, name)
+				break
+			}
+		}
+	}
+	return scripts
+}
+
+// allowedScriptMix reports whether a combination of scripts is commonly used
+// together, such as Japanese names mixing Han, Hiragana, Katakana and Latin.
+func allowedScriptMix(scripts []string) bool {
+	for _, script := range scripts {
+		switch script {
+		case "Latin", "Han", "Hiragana", "Katakana", "Hangul":
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// latinConfusables are non-Latin letters that are visually identical or very
+// close to a lowercase Latin letter.
+var latinConfusables = map[rune]bool{
+	// Cyrillic
+	'а': true, 'в': true, 'е': true, 'к': true, 'м': true, 'н': true, 'о': true, 'р': true,
+	'с': true, 'т': true, 'у': true, 'х': true, 'ѕ': true, 'і': true, 'ј': true, 'ԁ': true,
+	'ԛ': true, 'ԝ': true, 'һ': true, 'ӏ': true, 'ү': true, 'ɡ': true,
+	// Greek
+	'α': true, 'ο': true, 'ρ': true, 'ν': true, 'τ': true, 'υ': true, 'ι': true, 'κ': true,
+	'χ': true, 'ε': true,
+	// Armenian
+	'օ': true, 'հ': true, 'ո': true, 'ս': true, 'ց': true,
+}
+
+// latinLookalike reports whether every letter of a label has a Latin lookalike.
+func latinLookalike(label string) bool {
+	letters := 0
+	for _, r := range label {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		if !latinConfusables[r] {
+			return false
+		}
+		letters++
+	}
+	return letters > 0
+}
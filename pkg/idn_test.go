@@ -0,0 +1,90 @@
+package pkg
+
+import "testing"
+
+func TestToASCII(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"example.com", "example.com"},
+		{"bücher.example", "xn--bcher-kva.example"},
+		{"BÜCHER.example", "xn--bcher-kva.example"},
+		{"münchen.de", "xn--mnchen-3ya.de"},
+		{"例え.テスト", "xn--r8jz45g.xn--zckzah"},
+		{"例え。テスト", "xn--r8jz45g.xn--zckzah"},
+		{"straße.de", "xn--strae-oqa.de"},
+		{"café.dev", "xn--caf-dma.dev"},
+		{"cafe\u0301.dev", "xn--caf-dma.dev"}, // Decomposed form
+		{"CAFE\u0301.dev", "xn--caf-dma.dev"},
+		{"ｅｘａｍｐｌｅ.com", "example.com"}, // Full-width letters
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := ToASCII(tt.input)
+			if err != nil {
+				t.Fatalf("ToASCII(%q) error = %v", tt.input, err)
+			}
+			if result != tt.expected {
+				t.Errorf("ToASCII(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestToUnicode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"example.com", "example.com"},
+		{"xn--bcher-kva.example", "bücher.example"},
+		{"XN--BCHER-KVA.example", "bücher.example"},
+		{"xn--r8jz45g.xn--zckzah", "例え.テスト"},
+		{"xn--!!invalid.example", "xn--!!invalid.example"}, // Left unchanged
+		{"xn--mocgoogle-ih0e.com", "xn--mocgoogle-ih0e.com"}, // Decodes to a bidi override
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result := ToUnicode(tt.input)
+			if result != tt.expected {
+				t.Errorf("ToUnicode(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIsIDN(t *testing.T) {
+	if IsIDN("example.com") {
+		t.Error("IsIDN(example.com) = true, want false")
+	}
+	if !IsIDN("bücher.example") || !IsIDN("xn--bcher-kva.example") {
+		t.Error("IsIDN() should detect Unicode and punycode names")
+	}
+}
+
+func TestCheckConfusable(t *testing.T) {
+	tests := []struct {
+		hostname string
+		want     int
+	}{
+		{"example.com", 0},
+		{"bücher.example", 0},
+		{"例え.テスト", 0},
+		{"日本語とenglish.jp", 0},     // Han, Hiragana and Latin are commonly mixed
+		{"аpple.com", 1},          // Cyrillic "а" followed by Latin letters
+		{"xn--80ak6aa92e.com", 1}, // "аррӏе" written entirely in Cyrillic
+		{"пример.рф", 0},          // Ordinary Cyrillic name
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.hostname, func(t *testing.T) {
+			problems := CheckConfusable(tt.hostname)
+			if len(problems) != tt.want {
+				t.Errorf("CheckConfusable(%q) = %v, want %d problem(s)", tt.hostname, problems, tt.want)
+			}
+		})
+	}
+}
@@ -4,7 +4,7 @@ package pkg
 
 import (
 	"fmt"
-	"net"
+	"net/netip"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -13,8 +13,6 @@ import (
 var (
 	// hostnameRegex validates hostname format according to RFC standards
 	hostnameRegex = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9\-]{0,61}[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9\-]{0,61}[a-zA-Z0-9])?)*$`)
-	// ipv4Regex validates IPv4 address format with proper octet ranges
-	ipv4Regex = regexp.MustCompile(`^((25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.){3}(25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)$`)
 )
 
 // ValidationError represents a validation failure with context information.
@@ -29,30 +27,39 @@ func (e *ValidationError) Error() string {
 	return e.Message
 }
 
-// ValidateIP validates an IP address (IPv4 or IPv6) using Go's net package.
-// Returns a ValidationError if the IP address format is invalid.
-func ValidateIP(ip string) *ValidationError {
+// ParseIP parses an IPv4 or IPv6 address. IPv6 addresses may carry a zone
+// (fe80::1%eth0) or embed an IPv4 address (::ffff:192.0.2.1). This is the single
+// parsing path behind every IP validation and normalization in hostsctl.
+func ParseIP(ip string) (netip.Addr, *ValidationError) {
 	if ip == "" {
-		return &ValidationError{
+		return netip.Addr{}, &ValidationError{
 			Field:   "ip",
 			Value:   ip,
 			Message: "IP address cannot be empty",
 		}
 	}
 
-	if net.ParseIP(ip) == nil {
-		return &ValidationError{
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return netip.Addr{}, &ValidationError{
 			Field:   "ip",
 			Value:   ip,
 			Message: "invalid IP address format",
 		}
 	}
 
-	return nil
+	return addr, nil
+}
+
+// ValidateIP validates an IP address (IPv4 or IPv6).
+// Returns a ValidationError if the IP address format is invalid.
+func ValidateIP(ip string) *ValidationError {
+	_, err := ParseIP(ip)
+	return err
 }
 
 // ValidateIPv4 specifically validates IPv4 address format.
-// Uses regex to ensure proper octet ranges (0-255).
+// Octets must be in the 0-255 range and written without leading zeros.
 func ValidateIPv4(ip string) *ValidationError {
 	if ip == "" {
 		return &ValidationError{
@@ -62,7 +69,8 @@ func ValidateIPv4(ip string) *ValidationError {
 		}
 	}
 
-	if !ipv4Regex.MatchString(ip) {
+	addr, err := ParseIP(ip)
+	if err != nil || !addr.Is4() {
 		return &ValidationError{
 			Field:   "ip",
 			Value:   ip,
@@ -74,7 +82,7 @@ func ValidateIPv4(ip string) *ValidationError {
 }
 
 // ValidateIPv6 specifically validates IPv6 address format.
-// Ensures the address is valid IPv6 and not IPv4-mapped.
+// Zones and IPv4-mapped addresses written in IPv6 notation are accepted.
 func ValidateIPv6(ip string) *ValidationError {
 	if ip == "" {
 		return &ValidationError{
@@ -84,8 +92,8 @@ func ValidateIPv6(ip string) *ValidationError {
 		}
 	}
 
-	parsed := net.ParseIP(ip)
-	if parsed == nil || parsed.To4() != nil {
+	addr, err := ParseIP(ip)
+	if err != nil || !addr.Is6() {
 		return &ValidationError{
 			Field:   "ip",
 			Value:   ip,
@@ -98,6 +106,7 @@ func ValidateIPv6(ip string) *ValidationError {
 
 // ValidateHostname validates a hostname according to RFC 1123 standards.
 // Checks length limits, character restrictions, and label format rules.
+// Internationalized hostnames are accepted and validated in their punycode form.
 func ValidateHostname(hostname string) *ValidationError {
 	if hostname == "" {
 		return &ValidationError{
@@ -107,6 +116,16 @@ func ValidateHostname(hostname string) *ValidationError {
 		}
 	}
 
+	original := hostname
+	hostname, err := ToASCII(hostname)
+	if err != nil {
+		return &ValidationError{
+			Field:   "hostname",
+			Value:   original,
+			Message: err.Error(),
+		}
+	}
+
 	if len(hostname) > 253 {
 		return &ValidationError{
 			Field:   "hostname",
@@ -247,14 +266,21 @@ func IsValidComment(comment string) bool {
 	return ValidateComment(comment) == nil
 }
 
-// NormalizeIP normalizes an IP address to its canonical string representation.
-// Uses Go's net.ParseIP for consistent formatting.
+// NormalizeIP normalizes an IP address to its canonical string representation:
+// IPv6 addresses are compressed and lowercased (RFC 5952), keeping their zone and
+// IPv4-mapped form. Unparseable addresses are returned unchanged.
 func NormalizeIP(ip string) string {
-	parsed := net.ParseIP(ip)
-	if parsed != nil {
-		return parsed.String()
+	addr, err := ParseIP(ip)
+	if err != nil {
+		return ip
 	}
-	return ip
+	return addr.String()
+}
+
+// SameIP reports whether two strings spell the same IP address, such as
+// "::1" and "0:0:0:0:0:0:0:1". Unparseable addresses are compared as written.
+func SameIP(a, b string) bool {
+	return a == b || NormalizeIP(a) == NormalizeIP(b)
 }
 
 // NormalizeHostname normalizes a hostname to lowercase and trims whitespace.
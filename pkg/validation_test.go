@@ -92,7 +92,7 @@ func TestValidateIPv4(t *testing.T) {
 		{"192.168.1.", true},
 		{"192.168.1.256", true},
 		{"-1.0.0.1", true},
-		{"192.168.001.1", false}, // Leading zeros are allowed by regex
+		{"192.168.001.1", true}, // Leading zeros are ambiguous (octal) and rejected
 	}
 
 	for _, tt := range tests {
@@ -114,8 +114,11 @@ func TestValidateIPv6(t *testing.T) {
 		{"2001:0db8:85a3:0000:0000:8a2e:0370:7334", false},
 		{"2001:db8:85a3::8a2e:370:7334", false},
 		{"fe80::1", false},
+		{"fe80::1%eth0", false},       // Zone IDs are allowed
+		{"::ffff:192.168.1.1", false}, // IPv4-mapped addresses are IPv6
 		{"", true},
 		{"127.0.0.1", true}, // IPv4 should fail
+		{"127.0.0.1%eth0", true},
 		{"invalid", true},
 		{"gggg::1", true},
 		{"2001:0db8:85a3:0000:0000:8a2e:0370:7334:extra", true},
@@ -154,6 +157,9 @@ func TestValidateHostname(t *testing.T) {
 		{"test_underscore.com", true},            // Underscores not allowed
 		{"test space.com", true},                 // Spaces not allowed
 		{"test.com.", true},                      // Trailing dot not allowed
+		{"bücher.example", false},                // Internationalized names are allowed
+		{"例え.テスト", false},
+		{"bü_cher.example", true},
 	}
 
 	for _, tt := range tests {
@@ -272,6 +278,8 @@ func TestNormalizeIP(t *testing.T) {
 		{"2001:0db8:85a3:0000:0000:8a2e:0370:7334", "2001:db8:85a3::8a2e:370:7334"},
 		{"invalid", "invalid"}, // Returns original if unparseable
 		{"0000:0000:0000:0000:0000:0000:0000:0001", "::1"},
+		{"FE80::0001%eth0", "fe80::1%eth0"},
+		{"::ffff:192.168.1.1", "::ffff:192.168.1.1"}, // Mapped form is kept
 	}
 
 	for _, tt := range tests {
@@ -284,6 +292,18 @@ func TestNormalizeIP(t *testing.T) {
 	}
 }
 
+func TestSameIP(t *testing.T) {
+	if !SameIP("::1", "0:0:0:0:0:0:0:1") {
+		t.Error("SameIP should match equivalent IPv6 spellings")
+	}
+	if SameIP("fe80::1%eth0", "fe80::1%eth1") {
+		t.Error("SameIP should tell zones apart")
+	}
+	if SameIP("::ffff:127.0.0.1", "127.0.0.1") {
+		t.Error("SameIP should tell IPv4-mapped addresses from IPv4 ones")
+	}
+}
+
 func TestNormalizeHostname(t *testing.T) {
 	tests := []struct {
 		input    string